@@ -7,6 +7,7 @@ toolchain go1.23.4
 require (
 	github.com/antchfx/htmlquery v1.3.0
 	github.com/dop251/goja v0.0.0-20241024094426-79f3a7efcdbd
+	github.com/evanw/esbuild v0.24.2
 	github.com/fogleman/gg v1.3.0
 	github.com/gorilla/websocket v1.5.0
 	github.com/mattn/go-sqlite3 v1.14.17
@@ -42,6 +43,6 @@ require (
 	golang.org/x/mod v0.22.0 // indirect
 	golang.org/x/net v0.33.0
 	golang.org/x/sys v0.28.0 // indirect
-	golang.org/x/text v0.21.0 // indirect
+	golang.org/x/text v0.21.0
 	golang.org/x/tools v0.28.0 // indirect
 )
@@ -0,0 +1,156 @@
+package builtin
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+var (
+	abortSignalSymbol = goja.NewSymbol("AbortSignal") // 用于从 js 对象中获取 AbortSignal
+	errAborted        = errors.New("aborted")
+)
+
+func init() {
+	Builtins = append(Builtins, func(worker Worker) {
+		runtime := worker.Runtime()
+
+		runtime.Set("AbortController", func(call goja.ConstructorCall) *goja.Object {
+			signal := NewAbortSignal(worker)
+
+			o := runtime.NewObject()
+			o.SetPrototype(call.This.Prototype())
+			o.Set("signal", signal.object)
+			o.Set("abort", func(reason goja.Value) {
+				signal.Abort(reason)
+			})
+			return o
+		})
+
+		o := runtime.ToValue(func(call goja.ConstructorCall) *goja.Object {
+			panic(runtime.NewTypeError("Illegal constructor"))
+		}).(*goja.Object)
+		o.Set("abort", func(reason goja.Value) *goja.Object {
+			signal := NewAbortSignal(worker)
+			signal.Abort(reason)
+			return signal.object
+		})
+		o.Set("timeout", func(ms int64) *goja.Object { // 超时后中断，注：超时不会触发 abort 事件的监听器
+			signal := NewAbortSignal(worker)
+			ctx, cancel := context.WithTimeoutCause(signal.ctx, time.Duration(ms)*time.Millisecond, context.DeadlineExceeded)
+			worker.AddDefer(cancel)
+			signal.ctx = ctx
+			return signal.object
+		})
+		runtime.Set("AbortSignal", o)
+	})
+}
+
+type AbortSignal struct {
+	worker    Worker
+	ctx       context.Context
+	cancel    context.CancelCauseFunc
+	reason    goja.Value
+	listeners []goja.Value
+	object    *goja.Object
+}
+
+func NewAbortSignal(worker Worker) *AbortSignal {
+	runtime := worker.Runtime()
+
+	s := &AbortSignal{worker: worker}
+	s.ctx, s.cancel = context.WithCancelCause(context.Background())
+	worker.AddDefer(func() {
+		s.cancel(nil)
+	})
+
+	o := runtime.NewObject()
+	if c, ok := runtime.Get("AbortSignal").(*goja.Object); ok {
+		o.SetPrototype(c.Get("prototype").ToObject(runtime))
+	}
+	o.DefineAccessorProperty("aborted", runtime.ToValue(func() bool {
+		return s.ctx.Err() != nil
+	}), nil, goja.FLAG_FALSE, goja.FLAG_TRUE)
+	o.DefineAccessorProperty("reason", runtime.ToValue(func() goja.Value {
+		return s.Reason()
+	}), nil, goja.FLAG_FALSE, goja.FLAG_TRUE)
+	o.Set("onabort", goja.Null())
+	o.Set("throwIfAborted", func() {
+		if s.ctx.Err() != nil {
+			panic(s.Reason())
+		}
+	})
+	o.Set("addEventListener", func(typ string, listener goja.Value) {
+		if typ == "abort" {
+			s.listeners = append(s.listeners, listener)
+		}
+	})
+	o.Set("removeEventListener", func(typ string, listener goja.Value) {
+		for i, l := range s.listeners {
+			if typ == "abort" && l.StrictEquals(listener) {
+				s.listeners = append(s.listeners[:i], s.listeners[i+1:]...)
+				break
+			}
+		}
+	})
+	o.DefineDataPropertySymbol(abortSignalSymbol, runtime.ToValue(s), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_FALSE)
+	s.object = o
+
+	return s
+}
+
+// 从 js 对象中获取 AbortSignal，如果不是 AbortSignal 则返回 nil
+func ExportAbortSignal(value goja.Value) *AbortSignal {
+	s, _ := exportSymbol(value, abortSignalSymbol).(*AbortSignal)
+	return s
+}
+
+// 中断，并依次调用 onabort 和 abort 事件的监听器
+func (s *AbortSignal) Abort(reason goja.Value) {
+	if s.ctx.Err() != nil {
+		return
+	}
+
+	runtime := s.worker.Runtime()
+	if reason == nil || goja.IsUndefined(reason) {
+		reason = NewDOMException(runtime, "AbortError", "This operation was aborted")
+	}
+	s.reason = reason
+	s.cancel(errAborted)
+
+	event := runtime.NewObject()
+	event.Set("type", "abort")
+	event.Set("target", s.object)
+	if fn, ok := goja.AssertFunction(s.object.Get("onabort")); ok {
+		fn(s.object, event)
+	}
+	for _, l := range s.listeners {
+		if fn, ok := goja.AssertFunction(l); ok {
+			fn(s.object, event)
+		}
+	}
+}
+
+func (s *AbortSignal) Reason() goja.Value {
+	if s.reason != nil {
+		return s.reason
+	}
+	if errors.Is(context.Cause(s.ctx), context.DeadlineExceeded) {
+		return NewDOMException(s.worker.Runtime(), "TimeoutError", "The operation was aborted due to timeout")
+	}
+	return goja.Undefined()
+}
+
+// 获取中断信号的上下文，可用于取消协程中的操作
+func (s *AbortSignal) Context() context.Context {
+	return s.ctx
+}
+
+// 创建 DOMException 风格的异常，即 name 属性为指定值的 Error 对象
+func NewDOMException(runtime *goja.Runtime, name string, message string) *goja.Object {
+	e, _ := runtime.New(runtime.Get("Error"), runtime.ToValue(message))
+	e.Set("name", name)
+	return e
+}
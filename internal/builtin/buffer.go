@@ -1,10 +1,17 @@
 package builtin
 
 import (
+	"bytes"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf16"
 
 	"github.com/dop251/goja"
 )
@@ -17,19 +24,128 @@ func init() {
 			return runtime.ToValue(&Buffer{}).ToObject(runtime)
 		}).ToObject(runtime)
 
-		o.Set("from", func(input []byte, encoding string) (*Buffer, error) {
-			dat, err := decode(input, encoding)
-			return (*Buffer)(&dat), err
+		// 从字符串（按指定的编码解码）、字节数组、Uint8Array、ArrayBuffer 或数字数组创建，其中 ArrayBuffer 与 Buffer 共享内存，其他均为复制
+		o.Set("from", func(value goja.Value, encodingOrOffset goja.Value, length goja.Value) (*Buffer, error) {
+			if s, ok := value.Export().(string); ok {
+				encoding := ""
+				if !absent(encodingOrOffset) {
+					encoding = encodingOrOffset.String()
+				}
+				data, err := decode(s, encoding)
+				return (*Buffer)(&data), err
+			}
+			if ab, ok := value.Export().(goja.ArrayBuffer); ok {
+				data := ab.Bytes()
+				start, end := clamp(encodingOrOffset, 0, len(data)), len(data)
+				if !absent(length) {
+					end = clamp(length, 0, len(data)-start) + start
+				}
+				data = data[start:end]
+				return (*Buffer)(&data), nil
+			}
+			data, err := toBytes(value)
+			if err != nil {
+				return nil, err
+			}
+			data = append([]byte{}, data...)
+			return (*Buffer)(&data), nil
+		})
+
+		o.Set("alloc", func(size int, fill goja.Value, encoding string) (*Buffer, error) {
+			if size < 0 {
+				return nil, errors.New("The argument 'size' is invalid. Received " + strconv.Itoa(size))
+			}
+			b := make(Buffer, size)
+			if !absent(fill) {
+				if _, err := b.Fill(fill, goja.Undefined(), goja.Undefined(), encoding); err != nil {
+					return nil, err
+				}
+			}
+			return &b, nil
+		})
+
+		o.Set("allocUnsafe", func(size int) (*Buffer, error) {
+			if size < 0 {
+				return nil, errors.New("The argument 'size' is invalid. Received " + strconv.Itoa(size))
+			}
+			b := make(Buffer, size)
+			return &b, nil
+		})
+
+		o.Set("concat", func(list []goja.Value, totalLength goja.Value) (*Buffer, error) {
+			var b Buffer
+			for _, item := range list {
+				data, ok := ExportBytes(item)
+				if !ok {
+					return nil, errors.New("The \"list\" argument must be an array of Buffer or Uint8Array")
+				}
+				b = append(b, data...)
+			}
+			if !absent(totalLength) { // 超出时截断，不足时以 0 填充
+				size := int(totalLength.ToInteger())
+				if size < len(b) {
+					b = b[:size]
+				} else {
+					b = append(b, make([]byte, size-len(b))...)
+				}
+			}
+			if b == nil {
+				b = Buffer{}
+			}
+			return &b, nil
+		})
+
+		o.Set("compare", func(a goja.Value, b goja.Value) (int, error) {
+			x, ok1 := ExportBytes(a)
+			y, ok2 := ExportBytes(b)
+			if !ok1 || !ok2 {
+				return 0, errors.New("The arguments must be one of type Buffer or Uint8Array")
+			}
+			return bytes.Compare(x, y), nil
+		})
+
+		o.Set("isBuffer", func(value goja.Value) bool {
+			if value == nil {
+				return false
+			}
+			switch value.Export().(type) {
+			case Buffer, *Buffer:
+				return true
+			}
+			return false
+		})
+
+		o.Set("isEncoding", func(encoding string) bool {
+			_, err := encode(nil, encoding)
+			return err == nil
+		})
+
+		o.Set("byteLength", func(value goja.Value, encoding string) (int, error) {
+			if s, ok := value.Export().(string); ok {
+				data, err := decode(s, encoding)
+				return len(data), err
+			}
+			data, ok := ExportBytes(value)
+			if !ok {
+				return 0, errors.New("The \"string\" argument must be of type string or an instance of Buffer or ArrayBuffer")
+			}
+			return len(data), nil
 		})
 
 		runtime.Set("Buffer", o)
 	})
 }
 
+// 与 Node.js 的 Buffer 相兼容的字节数组，在 goja 中为宿主切片对象，可通过下标读写，且可作为 Uint8Array 使用的地方的参数
 type Buffer []byte
 
-func (b *Buffer) ToString(encoding string) (string, error) {
-	return encode(*b, encoding)
+//#region 编码
+
+// 按指定的编码转换为字符串，可选的参数 start 和 end 指定转换的范围
+func (b *Buffer) ToString(encoding string, bounds ...goja.Value) (string, error) {
+	bounds = append(bounds, nil, nil)
+	s, e := b.bounds(bounds[0], bounds[1])
+	return encode((*b)[s:e], encoding)
 }
 
 func (b *Buffer) ToJson() (obj interface{}, err error) {
@@ -37,9 +153,43 @@ func (b *Buffer) ToJson() (obj interface{}, err error) {
 	return
 }
 
+// 按指定的编码写入字符串，参数为 (string[, offset[, length]][, encoding])，返回写入的字节数
+func (b *Buffer) Write(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.write(call.Argument(0).String(), call.Argument(1), call.Argument(2), call.Argument(3)))
+}
+
+func (b *Buffer) write(s string, offset goja.Value, length goja.Value, encoding goja.Value) (int, error) {
+	args := []goja.Value{offset, length, encoding}
+	enc := ""
+	for i, arg := range args {
+		if arg != nil {
+			if v, ok := arg.Export().(string); ok {
+				enc, args = v, args[:i]
+				break
+			}
+		}
+	}
+	o, n := 0, len(*b)
+	if len(args) > 0 && !absent(args[0]) {
+		o = int(args[0].ToInteger())
+	}
+	if o < 0 || o > len(*b) {
+		return 0, outOfRange("offset", o, 0, len(*b))
+	}
+	n -= o
+	if len(args) > 1 && !absent(args[1]) {
+		n = min(n, max(int(args[1].ToInteger()), 0))
+	}
+	data, err := decode(s, enc)
+	if err != nil {
+		return 0, err
+	}
+	return copy((*b)[o:o+n], data), nil
+}
+
 func encode(input []byte, encoding string) (string, error) {
-	switch encoding {
-	case "", "utf8":
+	switch strings.ToLower(encoding) {
+	case "", "utf8", "utf-8":
 		return string(input), nil
 	case "hex":
 		return hex.EncodeToString(input), nil
@@ -47,20 +197,796 @@ func encode(input []byte, encoding string) (string, error) {
 		return base64.StdEncoding.EncodeToString(input), nil
 	case "base64url":
 		return base64.URLEncoding.EncodeToString(input), nil
+	case "latin1", "binary":
+		r := make([]rune, len(input))
+		for i, c := range input {
+			r[i] = rune(c)
+		}
+		return string(r), nil
+	case "ascii":
+		r := make([]rune, len(input))
+		for i, c := range input {
+			r[i] = rune(c & 0x7f)
+		}
+		return string(r), nil
+	case "ucs2", "ucs-2", "utf16le", "utf-16le":
+		u := make([]uint16, len(input)/2)
+		for i := range u {
+			u[i] = binary.LittleEndian.Uint16(input[i*2:])
+		}
+		return string(utf16.Decode(u)), nil
 	}
 	return "", errors.New("unsupported encoding: " + encoding)
 }
 
-func decode(input []byte, encoding string) ([]byte, error) {
-	switch encoding {
-	case "", "utf8":
-		return input, nil
+func decode(input string, encoding string) ([]byte, error) {
+	switch strings.ToLower(encoding) {
+	case "", "utf8", "utf-8":
+		return []byte(input), nil
 	case "hex":
-		return hex.DecodeString(string(input))
+		return hex.DecodeString(input)
 	case "base64":
-		return base64.StdEncoding.DecodeString(string(input))
+		return base64.StdEncoding.DecodeString(input)
 	case "base64url":
-		return base64.URLEncoding.DecodeString(string(input))
+		return base64.URLEncoding.DecodeString(input)
+	case "latin1", "binary", "ascii": // 仅保留每个 UTF-16 码元的低 8 位
+		u := utf16.Encode([]rune(input))
+		b := make([]byte, len(u))
+		for i, c := range u {
+			b[i] = byte(c)
+		}
+		return b, nil
+	case "ucs2", "ucs-2", "utf16le", "utf-16le":
+		u := utf16.Encode([]rune(input))
+		b := make([]byte, len(u)*2)
+		for i, c := range u {
+			binary.LittleEndian.PutUint16(b[i*2:], c)
+		}
+		return b, nil
 	}
 	return nil, errors.New("unsupported encoding: " + encoding)
 }
+
+//#endregion
+
+//#region 切片、比较、查找
+
+// 返回与原 Buffer 共享内存的切片，支持负数下标
+func (b *Buffer) Slice(start goja.Value, end goja.Value) Buffer {
+	s, e := b.bounds(start, end)
+	return (*b)[s:e:e]
+}
+
+func (b *Buffer) Subarray(start goja.Value, end goja.Value) Buffer {
+	return b.Slice(start, end)
+}
+
+func (b *Buffer) Equals(other goja.Value) (bool, error) {
+	data, ok := ExportBytes(other)
+	if !ok {
+		return false, errors.New("The \"otherBuffer\" argument must be an instance of Buffer or Uint8Array")
+	}
+	return bytes.Equal(*b, data), nil
+}
+
+func (b *Buffer) Compare(target goja.Value) (int, error) {
+	data, ok := ExportBytes(target)
+	if !ok {
+		return 0, errors.New("The \"target\" argument must be an instance of Buffer or Uint8Array")
+	}
+	return bytes.Compare(*b, data), nil
+}
+
+// 查找字符串、字节数组或单个字节（0~255）首次出现的位置，未找到时返回 -1
+func (b *Buffer) IndexOf(value goja.Value, byteOffset goja.Value, encoding string) (int, error) {
+	needle, err := b.needle(value, encoding)
+	if err != nil {
+		return -1, err
+	}
+	offset := 0
+	if !absent(byteOffset) {
+		if offset = int(byteOffset.ToInteger()); offset < 0 {
+			offset = max(len(*b)+offset, 0)
+		}
+	}
+	if offset > len(*b) {
+		return -1, nil
+	}
+	i := bytes.Index((*b)[offset:], needle)
+	if i < 0 {
+		return -1, nil
+	}
+	return i + offset, nil
+}
+
+func (b *Buffer) LastIndexOf(value goja.Value, byteOffset goja.Value, encoding string) (int, error) {
+	needle, err := b.needle(value, encoding)
+	if err != nil {
+		return -1, err
+	}
+	end := len(*b)
+	if !absent(byteOffset) {
+		offset := int(byteOffset.ToInteger())
+		if offset < 0 {
+			offset += len(*b)
+		}
+		if offset < 0 {
+			return -1, nil
+		}
+		end = min(offset+len(needle), len(*b))
+	}
+	return bytes.LastIndex((*b)[:end], needle), nil
+}
+
+func (b *Buffer) Includes(value goja.Value, byteOffset goja.Value, encoding string) (bool, error) {
+	i, err := b.IndexOf(value, byteOffset, encoding)
+	return i >= 0, err
+}
+
+func (b *Buffer) needle(value goja.Value, encoding string) ([]byte, error) {
+	switch v := value.Export().(type) {
+	case string:
+		return decode(v, encoding)
+	case int64:
+		return []byte{byte(v)}, nil
+	case float64:
+		return []byte{byte(int64(v))}, nil
+	}
+	if data, ok := ExportBytes(value); ok {
+		return data, nil
+	}
+	return nil, errors.New("The \"value\" argument must be one of type number or string or an instance of Buffer or Uint8Array")
+}
+
+//#endregion
+
+//#region 填充、复制
+
+// 以字符串、字节数组或单个字节循环填充，返回当前 Buffer
+func (b *Buffer) Fill(value goja.Value, offset goja.Value, end goja.Value, encoding string) (*Buffer, error) {
+	if offset != nil {
+		if s, ok := offset.Export().(string); ok { // fill(value, encoding)
+			offset, encoding = goja.Undefined(), s
+		}
+	}
+	s, e := b.bounds(offset, end)
+	pattern, err := b.needle(value, encoding)
+	if err != nil {
+		return nil, err
+	}
+	if len(pattern) == 0 {
+		pattern = []byte{0}
+	}
+	for i := s; i < e; i += len(pattern) {
+		copy((*b)[i:e], pattern)
+	}
+	return b, nil
+}
+
+// 复制到目标 Buffer 或 Uint8Array 中，返回复制的字节数
+func (b *Buffer) Copy(target goja.Value, targetStart goja.Value, sourceStart goja.Value, sourceEnd goja.Value) (int, error) {
+	dst, ok := ExportBytes(target)
+	if !ok {
+		return 0, errors.New("The \"target\" argument must be an instance of Buffer or Uint8Array")
+	}
+	t := clamp(targetStart, 0, len(dst))
+	s, e := b.bounds(sourceStart, sourceEnd)
+	return copy(dst[t:], (*b)[s:e]), nil
+}
+
+//#endregion
+
+//#region 读写数值
+
+func (b *Buffer) readUInt8(offset int) (uint8, error) {
+	if err := b.check(offset, 1); err != nil {
+		return 0, err
+	}
+	return (*b)[offset], nil
+}
+
+func (b *Buffer) readInt8(offset int) (int8, error) {
+	v, err := b.readUInt8(offset)
+	return int8(v), err
+}
+
+func (b *Buffer) readUInt16LE(offset int) (uint16, error) {
+	if err := b.check(offset, 2); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint16((*b)[offset:]), nil
+}
+
+func (b *Buffer) readUInt16BE(offset int) (uint16, error) {
+	if err := b.check(offset, 2); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint16((*b)[offset:]), nil
+}
+
+func (b *Buffer) readInt16LE(offset int) (int16, error) {
+	v, err := b.readUInt16LE(offset)
+	return int16(v), err
+}
+
+func (b *Buffer) readInt16BE(offset int) (int16, error) {
+	v, err := b.readUInt16BE(offset)
+	return int16(v), err
+}
+
+func (b *Buffer) readUInt32LE(offset int) (uint32, error) {
+	if err := b.check(offset, 4); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32((*b)[offset:]), nil
+}
+
+func (b *Buffer) readUInt32BE(offset int) (uint32, error) {
+	if err := b.check(offset, 4); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32((*b)[offset:]), nil
+}
+
+func (b *Buffer) readInt32LE(offset int) (int32, error) {
+	v, err := b.readUInt32LE(offset)
+	return int32(v), err
+}
+
+func (b *Buffer) readInt32BE(offset int) (int32, error) {
+	v, err := b.readUInt32BE(offset)
+	return int32(v), err
+}
+
+func (b *Buffer) readBigUInt64LE(offset int) (*big.Int, error) {
+	if err := b.check(offset, 8); err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetUint64(binary.LittleEndian.Uint64((*b)[offset:])), nil
+}
+
+func (b *Buffer) readBigUInt64BE(offset int) (*big.Int, error) {
+	if err := b.check(offset, 8); err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetUint64(binary.BigEndian.Uint64((*b)[offset:])), nil
+}
+
+func (b *Buffer) readBigInt64LE(offset int) (*big.Int, error) {
+	if err := b.check(offset, 8); err != nil {
+		return nil, err
+	}
+	return big.NewInt(int64(binary.LittleEndian.Uint64((*b)[offset:]))), nil
+}
+
+func (b *Buffer) readBigInt64BE(offset int) (*big.Int, error) {
+	if err := b.check(offset, 8); err != nil {
+		return nil, err
+	}
+	return big.NewInt(int64(binary.BigEndian.Uint64((*b)[offset:]))), nil
+}
+
+func (b *Buffer) readFloatLE(offset int) (float32, error) {
+	v, err := b.readUInt32LE(offset)
+	return math.Float32frombits(v), err
+}
+
+func (b *Buffer) readFloatBE(offset int) (float32, error) {
+	v, err := b.readUInt32BE(offset)
+	return math.Float32frombits(v), err
+}
+
+func (b *Buffer) readDoubleLE(offset int) (float64, error) {
+	if err := b.check(offset, 8); err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64((*b)[offset:])), nil
+}
+
+func (b *Buffer) readDoubleBE(offset int) (float64, error) {
+	if err := b.check(offset, 8); err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(binary.BigEndian.Uint64((*b)[offset:])), nil
+}
+
+// 读取 1~6 个字节的无符号整数，如 RTMP 协议中 3 个字节的时间戳
+func (b *Buffer) readUIntLE(offset int, byteLength int) (int64, error) {
+	return b.readUint(offset, byteLength, true)
+}
+
+func (b *Buffer) readUIntBE(offset int, byteLength int) (int64, error) {
+	return b.readUint(offset, byteLength, false)
+}
+
+func (b *Buffer) readIntLE(offset int, byteLength int) (int64, error) {
+	v, err := b.readUint(offset, byteLength, true)
+	return signExtend(v, byteLength), err
+}
+
+func (b *Buffer) readIntBE(offset int, byteLength int) (int64, error) {
+	v, err := b.readUint(offset, byteLength, false)
+	return signExtend(v, byteLength), err
+}
+
+func (b *Buffer) writeUInt8(value float64, offset int) (int, error) {
+	return b.writeUint(value, offset, 1, false, 0, math.MaxUint8)
+}
+
+func (b *Buffer) writeInt8(value float64, offset int) (int, error) {
+	return b.writeUint(value, offset, 1, false, math.MinInt8, math.MaxInt8)
+}
+
+func (b *Buffer) writeUInt16LE(value float64, offset int) (int, error) {
+	return b.writeUint(value, offset, 2, true, 0, math.MaxUint16)
+}
+
+func (b *Buffer) writeUInt16BE(value float64, offset int) (int, error) {
+	return b.writeUint(value, offset, 2, false, 0, math.MaxUint16)
+}
+
+func (b *Buffer) writeInt16LE(value float64, offset int) (int, error) {
+	return b.writeUint(value, offset, 2, true, math.MinInt16, math.MaxInt16)
+}
+
+func (b *Buffer) writeInt16BE(value float64, offset int) (int, error) {
+	return b.writeUint(value, offset, 2, false, math.MinInt16, math.MaxInt16)
+}
+
+func (b *Buffer) writeUInt32LE(value float64, offset int) (int, error) {
+	return b.writeUint(value, offset, 4, true, 0, math.MaxUint32)
+}
+
+func (b *Buffer) writeUInt32BE(value float64, offset int) (int, error) {
+	return b.writeUint(value, offset, 4, false, 0, math.MaxUint32)
+}
+
+func (b *Buffer) writeInt32LE(value float64, offset int) (int, error) {
+	return b.writeUint(value, offset, 4, true, math.MinInt32, math.MaxInt32)
+}
+
+func (b *Buffer) writeInt32BE(value float64, offset int) (int, error) {
+	return b.writeUint(value, offset, 4, false, math.MinInt32, math.MaxInt32)
+}
+
+func (b *Buffer) writeUIntLE(value float64, offset int, byteLength int) (int, error) {
+	return b.writeUint(value, offset, byteLength, true, 0, math.Exp2(float64(byteLength*8))-1)
+}
+
+func (b *Buffer) writeUIntBE(value float64, offset int, byteLength int) (int, error) {
+	return b.writeUint(value, offset, byteLength, false, 0, math.Exp2(float64(byteLength*8))-1)
+}
+
+func (b *Buffer) writeIntLE(value float64, offset int, byteLength int) (int, error) {
+	return b.writeUint(value, offset, byteLength, true, -math.Exp2(float64(byteLength*8-1)), math.Exp2(float64(byteLength*8-1))-1)
+}
+
+func (b *Buffer) writeIntBE(value float64, offset int, byteLength int) (int, error) {
+	return b.writeUint(value, offset, byteLength, false, -math.Exp2(float64(byteLength*8-1)), math.Exp2(float64(byteLength*8-1))-1)
+}
+
+func (b *Buffer) writeBigUInt64LE(value *big.Int, offset int) (int, error) {
+	return b.writeBigInt(value, offset, true, false)
+}
+
+func (b *Buffer) writeBigUInt64BE(value *big.Int, offset int) (int, error) {
+	return b.writeBigInt(value, offset, false, false)
+}
+
+func (b *Buffer) writeBigInt64LE(value *big.Int, offset int) (int, error) {
+	return b.writeBigInt(value, offset, true, true)
+}
+
+func (b *Buffer) writeBigInt64BE(value *big.Int, offset int) (int, error) {
+	return b.writeBigInt(value, offset, false, true)
+}
+
+func (b *Buffer) writeFloatLE(value float64, offset int) (int, error) {
+	if err := b.check(offset, 4); err != nil {
+		return 0, err
+	}
+	binary.LittleEndian.PutUint32((*b)[offset:], math.Float32bits(float32(value)))
+	return offset + 4, nil
+}
+
+func (b *Buffer) writeFloatBE(value float64, offset int) (int, error) {
+	if err := b.check(offset, 4); err != nil {
+		return 0, err
+	}
+	binary.BigEndian.PutUint32((*b)[offset:], math.Float32bits(float32(value)))
+	return offset + 4, nil
+}
+
+func (b *Buffer) writeDoubleLE(value float64, offset int) (int, error) {
+	if err := b.check(offset, 8); err != nil {
+		return 0, err
+	}
+	binary.LittleEndian.PutUint64((*b)[offset:], math.Float64bits(value))
+	return offset + 8, nil
+}
+
+func (b *Buffer) writeDoubleBE(value float64, offset int) (int, error) {
+	if err := b.check(offset, 8); err != nil {
+		return 0, err
+	}
+	binary.BigEndian.PutUint64((*b)[offset:], math.Float64bits(value))
+	return offset + 8, nil
+}
+
+// 以下为在 js 中调用的方法，越界时抛出 RangeError
+
+func (b *Buffer) ReadUInt8(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.readUInt8(toInt(call.Argument(0))))
+}
+
+func (b *Buffer) ReadInt8(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.readInt8(toInt(call.Argument(0))))
+}
+
+func (b *Buffer) ReadUInt16LE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.readUInt16LE(toInt(call.Argument(0))))
+}
+
+func (b *Buffer) ReadUInt16BE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.readUInt16BE(toInt(call.Argument(0))))
+}
+
+func (b *Buffer) ReadInt16LE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.readInt16LE(toInt(call.Argument(0))))
+}
+
+func (b *Buffer) ReadInt16BE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.readInt16BE(toInt(call.Argument(0))))
+}
+
+func (b *Buffer) ReadUInt32LE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.readUInt32LE(toInt(call.Argument(0))))
+}
+
+func (b *Buffer) ReadUInt32BE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.readUInt32BE(toInt(call.Argument(0))))
+}
+
+func (b *Buffer) ReadInt32LE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.readInt32LE(toInt(call.Argument(0))))
+}
+
+func (b *Buffer) ReadInt32BE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.readInt32BE(toInt(call.Argument(0))))
+}
+
+func (b *Buffer) ReadBigUInt64LE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.readBigUInt64LE(toInt(call.Argument(0))))
+}
+
+func (b *Buffer) ReadBigUInt64BE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.readBigUInt64BE(toInt(call.Argument(0))))
+}
+
+func (b *Buffer) ReadBigInt64LE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.readBigInt64LE(toInt(call.Argument(0))))
+}
+
+func (b *Buffer) ReadBigInt64BE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.readBigInt64BE(toInt(call.Argument(0))))
+}
+
+func (b *Buffer) ReadFloatLE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.readFloatLE(toInt(call.Argument(0))))
+}
+
+func (b *Buffer) ReadFloatBE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.readFloatBE(toInt(call.Argument(0))))
+}
+
+func (b *Buffer) ReadDoubleLE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.readDoubleLE(toInt(call.Argument(0))))
+}
+
+func (b *Buffer) ReadDoubleBE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.readDoubleBE(toInt(call.Argument(0))))
+}
+
+func (b *Buffer) ReadUIntLE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.readUIntLE(toInt(call.Argument(0)), toInt(call.Argument(1))))
+}
+
+func (b *Buffer) ReadUIntBE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.readUIntBE(toInt(call.Argument(0)), toInt(call.Argument(1))))
+}
+
+func (b *Buffer) ReadIntLE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.readIntLE(toInt(call.Argument(0)), toInt(call.Argument(1))))
+}
+
+func (b *Buffer) ReadIntBE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.readIntBE(toInt(call.Argument(0)), toInt(call.Argument(1))))
+}
+
+func (b *Buffer) WriteUInt8(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.writeUInt8(call.Argument(0).ToFloat(), toInt(call.Argument(1))))
+}
+
+func (b *Buffer) WriteInt8(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.writeInt8(call.Argument(0).ToFloat(), toInt(call.Argument(1))))
+}
+
+func (b *Buffer) WriteUInt16LE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.writeUInt16LE(call.Argument(0).ToFloat(), toInt(call.Argument(1))))
+}
+
+func (b *Buffer) WriteUInt16BE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.writeUInt16BE(call.Argument(0).ToFloat(), toInt(call.Argument(1))))
+}
+
+func (b *Buffer) WriteInt16LE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.writeInt16LE(call.Argument(0).ToFloat(), toInt(call.Argument(1))))
+}
+
+func (b *Buffer) WriteInt16BE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.writeInt16BE(call.Argument(0).ToFloat(), toInt(call.Argument(1))))
+}
+
+func (b *Buffer) WriteUInt32LE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.writeUInt32LE(call.Argument(0).ToFloat(), toInt(call.Argument(1))))
+}
+
+func (b *Buffer) WriteUInt32BE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.writeUInt32BE(call.Argument(0).ToFloat(), toInt(call.Argument(1))))
+}
+
+func (b *Buffer) WriteInt32LE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.writeInt32LE(call.Argument(0).ToFloat(), toInt(call.Argument(1))))
+}
+
+func (b *Buffer) WriteInt32BE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.writeInt32BE(call.Argument(0).ToFloat(), toInt(call.Argument(1))))
+}
+
+func (b *Buffer) WriteUIntLE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.writeUIntLE(call.Argument(0).ToFloat(), toInt(call.Argument(1)), toInt(call.Argument(2))))
+}
+
+func (b *Buffer) WriteUIntBE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.writeUIntBE(call.Argument(0).ToFloat(), toInt(call.Argument(1)), toInt(call.Argument(2))))
+}
+
+func (b *Buffer) WriteIntLE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.writeIntLE(call.Argument(0).ToFloat(), toInt(call.Argument(1)), toInt(call.Argument(2))))
+}
+
+func (b *Buffer) WriteIntBE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.writeIntBE(call.Argument(0).ToFloat(), toInt(call.Argument(1)), toInt(call.Argument(2))))
+}
+
+func (b *Buffer) WriteBigUInt64LE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.writeBigUInt64LE(toBigInt(call.Argument(0)), toInt(call.Argument(1))))
+}
+
+func (b *Buffer) WriteBigUInt64BE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.writeBigUInt64BE(toBigInt(call.Argument(0)), toInt(call.Argument(1))))
+}
+
+func (b *Buffer) WriteBigInt64LE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.writeBigInt64LE(toBigInt(call.Argument(0)), toInt(call.Argument(1))))
+}
+
+func (b *Buffer) WriteBigInt64BE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.writeBigInt64BE(toBigInt(call.Argument(0)), toInt(call.Argument(1))))
+}
+
+func (b *Buffer) WriteFloatLE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.writeFloatLE(call.Argument(0).ToFloat(), toInt(call.Argument(1))))
+}
+
+func (b *Buffer) WriteFloatBE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.writeFloatBE(call.Argument(0).ToFloat(), toInt(call.Argument(1))))
+}
+
+func (b *Buffer) WriteDoubleLE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.writeDoubleLE(call.Argument(0).ToFloat(), toInt(call.Argument(1))))
+}
+
+func (b *Buffer) WriteDoubleBE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(b.writeDoubleBE(call.Argument(0).ToFloat(), toInt(call.Argument(1))))
+}
+
+// Node.js 中 UInt 与 Uint 两种命名的方法互为别名
+
+func (b *Buffer) ReadUint8(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return b.ReadUInt8(call, runtime)
+}
+func (b *Buffer) ReadUint16LE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return b.ReadUInt16LE(call, runtime)
+}
+func (b *Buffer) ReadUint16BE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return b.ReadUInt16BE(call, runtime)
+}
+func (b *Buffer) ReadUint32LE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return b.ReadUInt32LE(call, runtime)
+}
+func (b *Buffer) ReadUint32BE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return b.ReadUInt32BE(call, runtime)
+}
+func (b *Buffer) ReadUintLE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return b.ReadUIntLE(call, runtime)
+}
+func (b *Buffer) ReadUintBE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return b.ReadUIntBE(call, runtime)
+}
+func (b *Buffer) ReadBigUint64LE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return b.ReadBigUInt64LE(call, runtime)
+}
+func (b *Buffer) ReadBigUint64BE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return b.ReadBigUInt64BE(call, runtime)
+}
+func (b *Buffer) WriteUint8(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return b.WriteUInt8(call, runtime)
+}
+func (b *Buffer) WriteUint16LE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return b.WriteUInt16LE(call, runtime)
+}
+func (b *Buffer) WriteUint16BE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return b.WriteUInt16BE(call, runtime)
+}
+func (b *Buffer) WriteUint32LE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return b.WriteUInt32LE(call, runtime)
+}
+func (b *Buffer) WriteUint32BE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return b.WriteUInt32BE(call, runtime)
+}
+func (b *Buffer) WriteUintLE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return b.WriteUIntLE(call, runtime)
+}
+func (b *Buffer) WriteUintBE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return b.WriteUIntBE(call, runtime)
+}
+func (b *Buffer) WriteBigUint64LE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return b.WriteBigUInt64LE(call, runtime)
+}
+func (b *Buffer) WriteBigUint64BE(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return b.WriteBigUInt64BE(call, runtime)
+}
+
+func (b *Buffer) check(offset int, size int) error {
+	if len(*b) < size { // 与 Node.js 一致，长度不足时不存在合法的 offset
+		return &rangeError{"ERR_BUFFER_OUT_OF_BOUNDS", "Attempt to access memory outside buffer bounds"}
+	}
+	if offset < 0 || offset+size > len(*b) {
+		return outOfRange("offset", offset, 0, len(*b)-size)
+	}
+	return nil
+}
+
+func (b *Buffer) readUint(offset int, byteLength int, littleEndian bool) (int64, error) {
+	if byteLength < 1 || byteLength > 6 {
+		return 0, outOfRange("byteLength", byteLength, 1, 6)
+	}
+	if err := b.check(offset, byteLength); err != nil {
+		return 0, err
+	}
+	var v int64
+	for i := 0; i < byteLength; i++ {
+		j := offset + i
+		if littleEndian {
+			j = offset + byteLength - 1 - i
+		}
+		v = v<<8 | int64((*b)[j])
+	}
+	return v, nil
+}
+
+func (b *Buffer) writeUint(value float64, offset int, byteLength int, littleEndian bool, lower float64, upper float64) (int, error) {
+	if byteLength < 1 || byteLength > 6 {
+		return 0, outOfRange("byteLength", byteLength, 1, 6)
+	}
+	if value < lower || value > upper || math.IsNaN(value) {
+		return 0, &rangeError{"ERR_OUT_OF_RANGE", "The value of \"value\" is out of range. It must be >= " + strconv.FormatFloat(lower, 'f', -1, 64) + " and <= " + strconv.FormatFloat(upper, 'f', -1, 64) + ". Received " + strconv.FormatFloat(value, 'f', -1, 64)}
+	}
+	if err := b.check(offset, byteLength); err != nil {
+		return 0, err
+	}
+	v := uint64(int64(value)) // 负数按补码写入
+	for i := 0; i < byteLength; i++ {
+		j := offset + byteLength - 1 - i
+		if littleEndian {
+			j = offset + i
+		}
+		(*b)[j] = byte(v >> (8 * i))
+	}
+	return offset + byteLength, nil
+}
+
+func (b *Buffer) writeBigInt(value *big.Int, offset int, littleEndian bool, signed bool) (int, error) {
+	if value == nil {
+		return 0, errors.New("The \"value\" argument must be of type bigint")
+	}
+	if signed && !value.IsInt64() || !signed && !value.IsUint64() {
+		return 0, &rangeError{"ERR_OUT_OF_RANGE", "The value of \"value\" is out of range. Received " + value.String() + "n"}
+	}
+	if err := b.check(offset, 8); err != nil {
+		return 0, err
+	}
+	v := value.Uint64()
+	if signed {
+		v = uint64(value.Int64())
+	}
+	if littleEndian {
+		binary.LittleEndian.PutUint64((*b)[offset:], v)
+	} else {
+		binary.BigEndian.PutUint64((*b)[offset:], v)
+	}
+	return offset + 8, nil
+}
+
+func signExtend(v int64, byteLength int) int64 {
+	shift := 64 - byteLength*8
+	return v << shift >> shift
+}
+
+// 与 Node.js 一致，越界的异常以包含 code 属性 ERR_OUT_OF_RANGE 的 RangeError 抛出
+func outOfRange(name string, value int, lower int, upper int) error {
+	return &rangeError{"ERR_OUT_OF_RANGE", "The value of \"" + name + "\" is out of range. It must be >= " + strconv.Itoa(lower) + " and <= " + strconv.Itoa(upper) + ". Received " + strconv.Itoa(value)}
+}
+
+// 获取 BigInt 参数，不是 BigInt 时返回 nil
+func toBigInt(value goja.Value) *big.Int {
+	v, _ := value.Export().(*big.Int)
+	return v
+}
+
+//#endregion
+
+// 计算 [start, end) 的范围，支持负数下标，缺省时为整个 Buffer
+func (b *Buffer) bounds(start goja.Value, end goja.Value) (int, int) {
+	n := len(*b)
+	s, e := 0, n
+	if !absent(start) {
+		if s = int(start.ToInteger()); s < 0 {
+			s += n
+		}
+		s = min(max(s, 0), n)
+	}
+	if !absent(end) {
+		if e = int(end.ToInteger()); e < 0 {
+			e += n
+		}
+		e = min(max(e, 0), n)
+	}
+	if e < s {
+		e = s
+	}
+	return s, e
+}
+
+// 参数缺省或为 undefined
+func absent(value goja.Value) bool {
+	return value == nil || goja.IsUndefined(value)
+}
+
+func clamp(value goja.Value, lower int, upper int) int {
+	if absent(value) {
+		return lower
+	}
+	return min(max(int(value.ToInteger()), lower), upper)
+}
+
+// 将字节数组、Uint8Array、ArrayBuffer 或数字数组转换为字节切片
+func toBytes(value goja.Value) ([]byte, error) {
+	if data, ok := ExportBytes(value); ok {
+		return data, nil
+	}
+	if values, ok := value.Export().([]interface{}); ok {
+		data := make([]byte, len(values))
+		for i, v := range values {
+			switch n := v.(type) {
+			case int64:
+				data[i] = byte(n)
+			case float64:
+				data[i] = byte(int64(n))
+			}
+		}
+		return data, nil
+	}
+	return nil, errors.New("The first argument must be of type string or an instance of Buffer, ArrayBuffer, or Array or an Array-like Object")
+}
@@ -1,6 +1,10 @@
 package builtin
 
-import "github.com/dop251/goja"
+import (
+	"cube/internal/util"
+
+	"github.com/dop251/goja"
+)
 
 var Builtins = make([]func(worker Worker), 0)
 
@@ -9,4 +13,65 @@ type Worker interface {
 	Id() int
 	Runtime() *goja.Runtime
 	EventLoop() *EventLoop
+	NewLogEntry(level string) *util.LogEntry       // 创建日志，其来源为 vm 实例正在执行的任务
+	StartSpan(name string, kind string) *util.Span // 创建当前 span 的子 span，不在调用链路中时返回 nil
+}
+
+// 参数类型的异常，在 js 中以 TypeError 抛出
+type typeError string
+
+func (e typeError) Error() string {
+	return string(e)
+}
+
+// 参数超出取值范围的异常，在 js 中以 RangeError 抛出，code 不为空时设置为其 code 属性，如 Node.js 的 ERR_OUT_OF_RANGE
+type rangeError struct {
+	code    string
+	message string
+}
+
+func (e *rangeError) Error() string {
+	return e.message
+}
+
+// 宿主对象的方法返回的异常默认以 GoError 抛出，需要抛出 TypeError 或 RangeError 的方法声明为
+// func(goja.FunctionCall, *goja.Runtime) goja.Value，并通过该方法返回执行结果
+func valueOrThrow(runtime *goja.Runtime) func(value interface{}, err error) goja.Value {
+	return func(value interface{}, err error) goja.Value {
+		switch e := err.(type) {
+		case nil:
+			return runtime.ToValue(value)
+		case typeError:
+			panic(runtime.NewTypeError(string(e)))
+		case *rangeError:
+			o := newRangeError(runtime, e.message)
+			if e.code != "" {
+				o.Set("code", e.code)
+			}
+			panic(o)
+		}
+		panic(runtime.NewGoError(err))
+	}
+}
+
+func toInt(value goja.Value) int {
+	return int(value.ToInteger())
+}
+
+// 获取可选的字符串参数，缺省时为空字符串
+func toString(value goja.Value) string {
+	if value == nil || goja.IsUndefined(value) || goja.IsNull(value) {
+		return ""
+	}
+	return value.String()
+}
+
+// 获取 js 对象中通过 symbol 关联的 go 对象，不存在时返回 nil
+func exportSymbol(value goja.Value, symbol *goja.Symbol) interface{} {
+	if o, ok := value.(*goja.Object); ok {
+		if v := o.GetSymbol(symbol); v != nil {
+			return v.Export()
+		}
+	}
+	return nil
 }
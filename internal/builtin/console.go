@@ -1,8 +1,12 @@
 package builtin
 
 import (
-	"log"
-	"time"
+	"encoding/json"
+	"strings"
+
+	"cube/internal/util"
+
+	"github.com/dop251/goja"
 )
 
 func init() {
@@ -11,26 +15,101 @@ func init() {
 	})
 }
 
+// 以 JSON lines 格式写入日志，其中 console.log 的等级为 info
 type ConsoleClient struct {
 	worker Worker
 }
 
-func (c *ConsoleClient) Log(a ...interface{}) {
-	log.Println(append([]interface{}{time.Now().Format("2006-01-02 15:04:05.000"), c.worker.Id(), "Log"}, a...)...)
+func (c *ConsoleClient) Log(a ...goja.Value) {
+	c.write("info", a)
+}
+
+func (c *ConsoleClient) Debug(a ...goja.Value) {
+	c.write("debug", a)
+}
+
+func (c *ConsoleClient) Info(a ...goja.Value) {
+	c.write("info", a)
+}
+
+func (c *ConsoleClient) Warn(a ...goja.Value) {
+	c.write("warn", a)
+}
+
+func (c *ConsoleClient) Error(a ...goja.Value) {
+	c.write("error", a)
+}
+
+// 参数以空格拼接为消息，当第一个参数为字符串且最后一个参数为普通对象时，最后一个参数作为结构化的字段，如 console.info("paid", { orderId, amount })
+func (c *ConsoleClient) write(level string, a []goja.Value) {
+	e := c.worker.NewLogEntry(level)
+	if !util.MyLogger.Enabled(e.Source, level) {
+		return
+	}
+
+	if n := len(a); n > 1 && isString(a[0]) && isPlainObject(a[n-1]) {
+		if s, ok := c.stringify(a[n-1]); ok {
+			e.Fields, a = json.RawMessage(s), a[:n-1]
+		}
+	}
+
+	messages := make([]string, 0, len(a))
+	for _, v := range a {
+		messages = append(messages, c.format(v))
+	}
+	e.Message = strings.Join(messages, " ")
+
+	util.MyLogger.Write(e)
 }
 
-func (c *ConsoleClient) Debug(a ...interface{}) {
-	log.Println(append(append([]interface{}{"\033[1;30m" + time.Now().Format("2006-01-02 15:04:05.000"), c.worker.Id(), "Debug"}, a...), "\033[m")...)
+// 字符串原样输出，异常输出其调用栈，其他对象序列化为 JSON
+func (c *ConsoleClient) format(v goja.Value) string {
+	if v == nil {
+		return "undefined"
+	}
+	if isString(v) {
+		return v.String()
+	}
+	if o, ok := v.(*goja.Object); ok {
+		if o.ClassName() == "Error" {
+			if stack := o.Get("stack"); stack != nil && !goja.IsUndefined(stack) {
+				return stack.String()
+			}
+			return o.String()
+		}
+		if _, ok := goja.AssertFunction(o); !ok {
+			if s, ok := c.stringify(o); ok {
+				return s
+			}
+		}
+	}
+	return v.String()
 }
 
-func (c *ConsoleClient) Info(a ...interface{}) {
-	log.Println(append(append([]interface{}{"\033[0;34m" + time.Now().Format("2006-01-02 15:04:05.000"), c.worker.Id(), "Info"}, a...), "\033[m")...)
+func (c *ConsoleClient) stringify(v goja.Value) (string, bool) {
+	runtime := c.worker.Runtime()
+	stringify, _ := goja.AssertFunction(runtime.Get("JSON").ToObject(runtime).Get("stringify"))
+	s, err := stringify(nil, v)
+	if err != nil || goja.IsUndefined(s) {
+		return "", false
+	}
+	return s.String(), true
 }
 
-func (c *ConsoleClient) Warn(a ...interface{}) {
-	log.Println(append(append([]interface{}{"\033[0;33m" + time.Now().Format("2006-01-02 15:04:05.000"), c.worker.Id(), "Warn"}, a...), "\033[m")...)
+func isString(v goja.Value) bool {
+	if v == nil {
+		return false
+	}
+	_, ok := v.Export().(string)
+	return ok
 }
 
-func (c *ConsoleClient) Error(a ...interface{}) {
-	log.Println(append(append([]interface{}{"\033[0;31m" + time.Now().Format("2006-01-02 15:04:05.000"), c.worker.Id(), "Error"}, a...), "\033[m")...)
+// 判断是否为普通对象，如 { k: v }，不包括数组、日期、Map 等
+func isPlainObject(v goja.Value) bool {
+	o, ok := v.(*goja.Object)
+	if !ok || o.ClassName() != "Object" {
+		return false
+	}
+	_, ok = o.Export().(map[string]interface{})
+	return ok
 }
@@ -1,11 +1,14 @@
 package builtin
 
 import (
+	"errors"
 	"fmt"
-	"math"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
+	_ "time/tzdata" // 内置时区数据库，使得指定的时区在缺少时区数据的系统上也可用
+	"unicode/utf8"
 
 	"github.com/dop251/goja"
 )
@@ -20,17 +23,20 @@ func init() {
 				panic(runtime.NewTypeError("Method Date.prototype.toString is called on incompatible receiver"))
 			}
 
-			layout := call.Argument(0).String()
-			if layout == "undefined" {
-				return runtime.ToValue(t.Format("Mon Jan 02 2006 15:04:05 GMT-0700 (MST)"))
+			options := exportDateOptions(runtime, call.Argument(1))
+
+			layout := call.Argument(0)
+			if goja.IsUndefined(layout) {
+				return runtime.ToValue(t.In(options.location(runtime)).Format("Mon Jan 02 2006 15:04:05 GMT-0700 (MST)"))
 			}
 
-			return runtime.ToValue(timeToString(t, layout))
+			return runtime.ToValue(timeToString(t.In(options.location(runtime)), layout.String(), options.locale()))
 		})
 
-		runtime.Get("Date").ToObject(runtime).Set("toDate", func(value string, layout string) (*goja.Object, error) {
+		runtime.Get("Date").ToObject(runtime).Set("toDate", func(value string, layout string, options goja.Value) (*goja.Object, error) {
+			o := exportDateOptions(runtime, options)
 			c, _ := goja.AssertConstructor(runtime.Get("Date"))
-			t, err := stringToTime(value, layout)
+			t, err := stringToTime(value, layout, o.location(runtime), o.locale())
 			if err != nil {
 				return nil, err
 			}
@@ -39,53 +45,428 @@ func init() {
 	})
 }
 
-func timeToString(t time.Time, layout string) string {
-	r, _ := regexp.Compile("y{1,4}|M{1,2}|d{1,2}|H{1,2}|m{1,2}|s{1,2}|S{1,3}")
-	return r.ReplaceAllStringFunc(layout, func(s string) string {
-		switch s[0] {
+// toString 和 toDate 的选项，未指定时区时使用本地时区，未指定语言时使用英文
+type dateOptions struct {
+	TimeZone string
+	Locale   string
+}
+
+func exportDateOptions(runtime *goja.Runtime, value goja.Value) *dateOptions {
+	options := &dateOptions{}
+	if value != nil && !goja.IsUndefined(value) && !goja.IsNull(value) {
+		if err := runtime.ExportTo(value, options); err != nil {
+			panic(runtime.NewTypeError(err.Error()))
+		}
+	}
+	return options
+}
+
+func (o *dateOptions) location(runtime *goja.Runtime) *time.Location {
+	if o.TimeZone == "" {
+		return time.Local
+	}
+	loc, err := loadLocation(o.TimeZone)
+	if err != nil {
+		panic(newRangeError(runtime, err.Error()))
+	}
+	return loc
+}
+
+func (o *dateOptions) locale() *dateLocale {
+	_, l := resolveLocale(o.Locale)
+	return l
+}
+
+// 加载时区，支持 IANA 时区名称（如 Asia/Shanghai）、UTC 以及固定偏移（如 +08:00）
+func loadLocation(name string) (*time.Location, error) {
+	switch {
+	case strings.EqualFold(name, "utc"), strings.EqualFold(name, "gmt"), name == "Z":
+		return time.UTC, nil
+	case strings.EqualFold(name, "local"):
+		return time.Local, nil
+	case name != "" && (name[0] == '+' || name[0] == '-'):
+		if offset, n := parseOffset(name); n == len(name) {
+			return time.FixedZone(name, offset), nil
+		}
+	case name != "":
+		if loc, err := time.LoadLocation(name); err == nil {
+			return loc, nil
+		}
+	}
+	return nil, errors.New("Invalid time zone specified: " + name)
+}
+
+// 解析 ±hh、±hhmm 或 ±hh:mm 格式的时区偏移，返回偏移的秒数和读取的字节数，格式错误时读取的字节数为 0
+func parseOffset(value string) (int, int) {
+	if len(value) < 3 || value[0] != '+' && value[0] != '-' {
+		return 0, 0
+	}
+	hh, err := strconv.Atoi(value[1:3])
+	if err != nil || hh > 23 {
+		return 0, 0
+	}
+	offset, n := hh*3600, 3
+	rest := value[3:]
+	if strings.HasPrefix(rest, ":") {
+		rest = rest[1:]
+		n++
+		if len(rest) < 2 {
+			return 0, 0
+		}
+	}
+	if len(rest) >= 2 {
+		if mm, err := strconv.Atoi(rest[:2]); err == nil && mm < 60 {
+			offset += mm * 60
+			n += 2
+		} else if n == 4 {
+			return 0, 0
+		}
+	}
+	if value[0] == '-' {
+		offset = -offset
+	}
+	return offset, n
+}
+
+func newRangeError(runtime *goja.Runtime, message string) *goja.Object {
+	e, _ := runtime.New(runtime.Get("RangeError"), runtime.ToValue(message))
+	return e
+}
+
+//#region 格式化与解析
+
+// 与 Unicode LDML 的日期格式相一致，如 yyyy-MM-dd HH:mm:ss.SSS、EEEE, MMMM d, yyyy h:mm a，单引号中的内容原样输出
+var dateTokenPattern = regexp.MustCompile(`'(?:[^']|'')*'|y+|M+|d+|E+|a+|H+|h+|m+|s+|S+|G+|Z+|X+|z+|V+`)
+
+func timeToString(t time.Time, layout string, l *dateLocale) string {
+	return dateTokenPattern.ReplaceAllStringFunc(layout, func(s string) string {
+		return formatDateToken(t, s, l)
+	})
+}
+
+func formatDateToken(t time.Time, token string, l *dateLocale) string {
+	n := len(token)
+	switch token[0] {
+	case '\'':
+		return quotedLiteral(token)
+	case 'y':
+		if n == 2 {
+			return fmt.Sprintf("%02d", t.Year()%100)
+		}
+		return fmt.Sprintf("%0*d", n, t.Year())
+	case 'M':
+		switch {
+		case n >= 5:
+			return firstRune(l.months[t.Month()-1])
+		case n == 4:
+			return l.months[t.Month()-1]
+		case n == 3:
+			return l.shortMonths[t.Month()-1]
+		}
+		return fmt.Sprintf("%0*d", n, int(t.Month()))
+	case 'd':
+		return fmt.Sprintf("%0*d", n, t.Day())
+	case 'E':
+		switch {
+		case n >= 5:
+			return firstRune(l.weekdays[t.Weekday()])
+		case n == 4:
+			return l.weekdays[t.Weekday()]
+		}
+		return l.shortWeekdays[t.Weekday()]
+	case 'a':
+		if t.Hour() < 12 {
+			return l.am
+		}
+		return l.pm
+	case 'H':
+		return fmt.Sprintf("%0*d", n, t.Hour())
+	case 'h':
+		h := t.Hour() % 12
+		if h == 0 {
+			h = 12
+		}
+		return fmt.Sprintf("%0*d", n, h)
+	case 'm':
+		return fmt.Sprintf("%0*d", n, t.Minute())
+	case 's':
+		return fmt.Sprintf("%0*d", n, t.Second())
+	case 'S':
+		return fmt.Sprintf("%09d", t.Nanosecond())[:min(n, 9)]
+	case 'G':
+		if t.Year() <= 0 {
+			return l.eras[0]
+		}
+		return l.eras[1]
+	case 'Z':
+		if n >= 5 {
+			return formatOffset(t, true, false)
+		}
+		return formatOffset(t, false, false)
+	case 'X':
+		switch offset := formatOffset(t, false, true); {
+		case n == 1 && strings.HasSuffix(offset, "00"):
+			return offset[:3]
+		case n <= 2:
+			return offset
+		}
+		return formatOffset(t, true, true)
+	case 'z':
+		if n >= 4 {
+			return t.Location().String()
+		}
+		name, _ := t.Zone()
+		return name
+	case 'V':
+		return t.Location().String()
+	}
+	return token
+}
+
+// 格式化时区偏移，如 +0800、+08:00，zulu 为 true 时零偏移输出 Z
+func formatOffset(t time.Time, colon bool, zulu bool) string {
+	_, offset := t.Zone()
+	if offset == 0 && zulu {
+		return "Z"
+	}
+	sign := '+'
+	if offset < 0 {
+		sign, offset = '-', -offset
+	}
+	if colon {
+		return fmt.Sprintf("%c%02d:%02d", sign, offset/3600, offset/60%60)
+	}
+	return fmt.Sprintf("%c%02d%02d", sign, offset/3600, offset/60%60)
+}
+
+// 单引号中的文本，两个连续的单引号表示单引号本身
+func quotedLiteral(token string) string {
+	if token == "''" {
+		return "'"
+	}
+	return strings.ReplaceAll(token[1:len(token)-1], "''", "'")
+}
+
+func firstRune(s string) string {
+	_, n := utf8.DecodeRuneInString(s)
+	return s[:n]
+}
+
+// 按格式解析时间，未包含时区的时间按 loc 解析，解析失败时返回无法解析的位置及对应的格式
+func stringToTime(value string, layout string, loc *time.Location, l *dateLocale) (*time.Time, error) {
+	fail := func(rest string, token string) error {
+		return fmt.Errorf("parsing time %q as %q: cannot parse %q as %q", value, layout, rest, token)
+	}
+
+	year, month, day, hour, minute, second, nsec := 1970, 1, 1, 0, 0, 0, 0
+	pm, hasPeriod, twelve, bc := false, false, false, false
+	rest := value
+
+	tokens := dateTokenPattern.FindAllStringIndex(layout, -1)
+	pos := 0
+	for i := 0; i <= len(tokens); i++ {
+		// 匹配格式之间的文本
+		end := len(layout)
+		if i < len(tokens) {
+			end = tokens[i][0]
+		}
+		if literal := layout[pos:end]; !strings.HasPrefix(rest, literal) {
+			return nil, fail(rest, literal)
+		} else {
+			rest = rest[len(literal):]
+		}
+		if i == len(tokens) {
+			break
+		}
+		token := layout[tokens[i][0]:tokens[i][1]]
+		pos = tokens[i][1]
+
+		// 紧邻下一个数字格式时（如 yyyyMMdd），按格式的长度读取固定位数的数字
+		fixed := len(token) > 1 && i+1 < len(tokens) && tokens[i+1][0] == pos
+		n := len(token)
+
+		var err error
+		switch token[0] {
+		case '\'':
+			literal := quotedLiteral(token)
+			if !strings.HasPrefix(rest, literal) {
+				return nil, fail(rest, token)
+			}
+			rest = rest[len(literal):]
 		case 'y':
-			return fmt.Sprint(t.Year())[max(4-len(s), 0):]
+			var v, width int
+			if v, width, err = readNumber(rest, n, 9, fixed || n == 2); err == nil {
+				if n == 2 && width == 2 {
+					if v += 1900; v < 1969 {
+						v += 100
+					}
+				}
+				year, rest = v, rest[width:]
+			}
 		case 'M':
-			return fmt.Sprintf("%02d", t.Month())[max(2-len(s), 0):]
+			if n >= 3 {
+				var v, width int
+				if v, width = matchName(rest, l.months, l.shortMonths); width == 0 {
+					err = errors.New("unknown month name")
+				} else {
+					month, rest = v+1, rest[width:]
+				}
+				break
+			}
+			var width int
+			if month, width, err = readNumber(rest, n, 2, fixed); err == nil {
+				rest = rest[width:]
+				if month < 1 || month > 12 {
+					err = errors.New("month out of range")
+				}
+			}
 		case 'd':
-			return fmt.Sprintf("%02d", t.Day())[max(2-len(s), 0):]
-		case 'H':
-			return fmt.Sprintf("%02d", t.Hour())[max(2-len(s), 0):]
+			var width int
+			if day, width, err = readNumber(rest, n, 2, fixed); err == nil {
+				rest = rest[width:]
+				if day < 1 || day > 31 {
+					err = errors.New("day out of range")
+				}
+			}
+		case 'E':
+			if _, width := matchName(rest, l.weekdays, l.shortWeekdays); width == 0 { // 星期仅用于校验格式，不参与计算
+				err = errors.New("unknown weekday name")
+			} else {
+				rest = rest[width:]
+			}
+		case 'a':
+			switch {
+			case hasPrefixFold(rest, l.am):
+				pm, rest = false, rest[len(l.am):]
+			case hasPrefixFold(rest, l.pm):
+				pm, rest = true, rest[len(l.pm):]
+			default:
+				err = errors.New("unknown day period")
+			}
+			hasPeriod = true
+		case 'H', 'h':
+			var width int
+			if hour, width, err = readNumber(rest, n, 2, fixed); err == nil {
+				rest = rest[width:]
+				if token[0] == 'h' && (hour < 1 || hour > 12) || hour > 23 {
+					err = errors.New("hour out of range")
+				}
+				twelve = token[0] == 'h'
+			}
 		case 'm':
-			return fmt.Sprintf("%02d", t.Minute())[max(2-len(s), 0):]
+			var width int
+			if minute, width, err = readNumber(rest, n, 2, fixed); err == nil {
+				rest = rest[width:]
+				if minute > 59 {
+					err = errors.New("minute out of range")
+				}
+			}
 		case 's':
-			return fmt.Sprintf("%02d", t.Second())[max(2-len(s), 0):]
+			var width int
+			if second, width, err = readNumber(rest, n, 2, fixed); err == nil {
+				rest = rest[width:]
+				if second > 59 {
+					err = errors.New("second out of range")
+				}
+			}
 		case 'S':
-			return fmt.Sprintf("%03d", t.Nanosecond()/1e6)[:min(len(s), 3)]
-		default:
-			return s
+			var v, width int
+			if v, width, err = readNumber(rest, n, 9, fixed); err == nil {
+				for d := width; d < 9; d++ {
+					v *= 10
+				}
+				nsec, rest = v, rest[width:]
+			}
+		case 'G':
+			switch {
+			case hasPrefixFold(rest, l.eras[1]):
+				rest = rest[len(l.eras[1]):]
+			case hasPrefixFold(rest, l.eras[0]):
+				bc, rest = true, rest[len(l.eras[0]):]
+			default:
+				err = errors.New("unknown era")
+			}
+		case 'Z', 'X':
+			if strings.HasPrefix(rest, "Z") {
+				loc, rest = time.UTC, rest[1:]
+				break
+			}
+			offset, width := parseOffset(rest)
+			if width == 0 {
+				err = errors.New("invalid time zone offset")
+				break
+			}
+			loc, rest = time.FixedZone(rest[:width], offset), rest[width:]
+		case 'z', 'V':
+			width := strings.IndexFunc(rest, func(r rune) bool {
+				return !(r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '/' || r == '_' || r == '-' || r == '+' || r == ':')
+			})
+			if width < 0 {
+				width = len(rest)
+			}
+			var zone *time.Location
+			if zone, err = loadLocation(rest[:width]); err == nil {
+				loc, rest = zone, rest[width:]
+			}
 		}
-	})
-}
-
-func stringToTime(value string, layout string) (*time.Time, error) {
-	r, _ := regexp.Compile("y{1,4}|M{1,2}|d{1,2}|H{1,2}|m{1,2}|s{1,2}|S{1,3}")
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", fail(rest, token), err.Error())
+		}
+	}
+	if rest != "" {
+		return nil, fmt.Errorf("parsing time %q as %q: extra text %q", value, layout, rest)
+	}
 
-	p, m := make([]int, 7), map[byte]byte{'y': 0, 'M': 1, 'd': 2, 'H': 3, 'm': 4, 's': 5, 'S': 6}
+	if hasPeriod && twelve {
+		hour %= 12
+		if pm {
+			hour += 12
+		}
+	}
+	if bc { // 公元前 1 年为第 0 年
+		year = 1 - year
+	}
+	t := time.Date(year, time.Month(month), day, hour, minute, second, nsec, loc)
+	if t.Day() != day { // 如 02-30，time.Date 会将其规范化为 03-02
+		return nil, fmt.Errorf("parsing time %q as %q: day out of range", value, layout)
+	}
+	return &t, nil
+}
 
-	idxes := r.FindAllStringIndex(layout, -1)
-	for _, a := range idxes {
-		v, err := strconv.Atoi(value[a[0]:a[1]])
-		if err != nil {
-			return nil, err
+// 读取数字，fixed 为 true 时读取恰好 width 位，否则读取 1 到 limit 位
+func readNumber(value string, width int, limit int, fixed bool) (int, int, error) {
+	n := 0
+	for n < len(value) && n < limit && value[n] >= '0' && value[n] <= '9' {
+		n++
+		if fixed && n == width {
+			break
 		}
-		c := layout[a[0]]
-		if c == 'S' {
-			p[m[c]] = int(float64(v) * math.Pow10(3-a[1]+a[0]))
-			continue
+	}
+	if n == 0 || fixed && n != width {
+		return 0, 0, errors.New("expected " + strconv.Itoa(width) + " digits")
+	}
+	v, err := strconv.Atoi(value[:n])
+	return v, n, err
+}
+
+// 匹配名称（忽略大小写），优先匹配完整的名称，返回名称的下标和读取的字节数
+func matchName(value string, lists ...[]string) (int, int) {
+	for _, names := range lists {
+		for i, name := range names {
+			if hasPrefixFold(value, name) {
+				return i, len(name)
+			}
 		}
-		p[m[c]] = v
 	}
+	return 0, 0
+}
 
-	t := time.Date(p[0], time.Month(p[1]), p[2], p[3], p[4], p[5], p[6]*1e6, time.Local)
-	return &t, nil
+func hasPrefixFold(s string, prefix string) bool {
+	return prefix != "" && len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
 }
 
+//#endregion
+
 func max(a int, b int) int {
 	if a > b {
 		return a
@@ -94,8 +475,8 @@ func max(a int, b int) int {
 }
 
 func min(a int, b int) int {
-	if a > b {
-		return b
+	if a < b {
+		return a
 	}
-	return a
+	return b
 }
@@ -1,6 +1,11 @@
 package builtin
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"math/big"
+	"strings"
+
 	"github.com/dop251/goja"
 	"github.com/shopspring/decimal"
 )
@@ -10,17 +15,298 @@ func init() {
 		runtime := worker.Runtime()
 
 		runtime.Set("Decimal", func(call goja.ConstructorCall) *goja.Object {
-			v, ok := call.Argument(0).Export().(string)
-			if !ok {
+			if goja.IsUndefined(call.Argument(0)) {
 				panic(runtime.NewTypeError("value is required"))
 			}
-
-			d, err := decimal.NewFromString(v)
+			d, err := ParseDecimal(call.Argument(0))
 			if err != nil {
-				panic(err)
+				panic(runtime.NewTypeError(err.Error()))
 			}
-
-			return runtime.ToValue(&d).(*goja.Object)
+			return runtime.ToValue(NewDecimal(d)).(*goja.Object)
 		})
 	})
 }
+
+// 十进制数，用于金额等不能经过 float64 的精确计算，序列化为 JSON 时为字符串，写入数据库时以字符串绑定
+type Decimal struct {
+	value decimal.Decimal
+}
+
+func NewDecimal(d decimal.Decimal) *Decimal {
+	return &Decimal{d}
+}
+
+// 将 Decimal、字符串、数字或 BigInt 转换为十进制数，数字按其最短的十进制表示转换，如 0.1 转换为 "0.1"，无法转换时返回 typeError
+func ParseDecimal(value goja.Value) (decimal.Decimal, error) {
+	if value == nil {
+		return decimal.Zero, typeError("invalid decimal: undefined")
+	}
+	switch v := value.Export().(type) {
+	case *Decimal:
+		return v.value, nil
+	case string:
+		d, err := decimal.NewFromString(strings.TrimSpace(v))
+		if err != nil {
+			return decimal.Zero, typeError("invalid decimal: " + v)
+		}
+		return d, nil
+	case int64:
+		return decimal.NewFromInt(v), nil
+	case float64:
+		if v != v || v > 1.7976931348623157e308 || v < -1.7976931348623157e308 { // NaN 或 Infinity
+			return decimal.Zero, typeError("invalid decimal: " + value.String())
+		}
+		return decimal.NewFromFloat(v), nil
+	case *big.Int:
+		return decimal.NewFromBigInt(v, 0), nil
+	}
+	return decimal.Zero, typeError("invalid decimal: " + value.String())
+}
+
+//#region 运算
+
+// 加法，指定 scale 时按 rounding 舍入到 scale 位小数，下同
+func (d *Decimal) Add(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(d.apply(call.Argument(0), call.Argument(1), toString(call.Argument(2)), decimal.Decimal.Add))
+}
+
+func (d *Decimal) Sub(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(d.apply(call.Argument(0), call.Argument(1), toString(call.Argument(2)), decimal.Decimal.Sub))
+}
+
+func (d *Decimal) Mul(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(d.apply(call.Argument(0), call.Argument(1), toString(call.Argument(2)), decimal.Decimal.Mul))
+}
+
+// 除法，未指定 scale 时保留 16 位小数并四舍五入
+func (d *Decimal) Div(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(d.div(call.Argument(0), call.Argument(1), toString(call.Argument(2))))
+}
+
+func (d *Decimal) Mod(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(d.mod(call.Argument(0)))
+}
+
+func (d *Decimal) Pow(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(d.pow(call.Argument(0)))
+}
+
+func (d *Decimal) Neg() *Decimal {
+	return NewDecimal(d.value.Neg())
+}
+
+func (d *Decimal) Abs() *Decimal {
+	return NewDecimal(d.value.Abs())
+}
+
+// 按 rounding 舍入到 scale 位小数
+func (d *Decimal) Round(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	v, err := round(d.value, int32(toInt(call.Argument(0))), toString(call.Argument(1)))
+	return valueOrThrow(runtime)(NewDecimal(v), err)
+}
+
+func (d *Decimal) div(value goja.Value, scale goja.Value, rounding string) (*Decimal, error) {
+	v, err := ParseDecimal(value)
+	if err != nil {
+		return nil, err
+	}
+	if v.IsZero() {
+		return nil, &rangeError{message: "division by zero"}
+	}
+	s := int32(decimal.DivisionPrecision)
+	if scale != nil && !goja.IsUndefined(scale) {
+		s = int32(scale.ToInteger())
+	}
+	q, r := d.value.QuoRem(v, s)
+	q, err = roundQuotient(q, r, v, s, rounding)
+	if err != nil {
+		return nil, err
+	}
+	return NewDecimal(q), nil
+}
+
+func (d *Decimal) mod(value goja.Value) (*Decimal, error) {
+	v, err := ParseDecimal(value)
+	if err != nil {
+		return nil, err
+	}
+	if v.IsZero() {
+		return nil, &rangeError{message: "division by zero"}
+	}
+	return NewDecimal(d.value.Mod(v)), nil
+}
+
+func (d *Decimal) pow(value goja.Value) (*Decimal, error) {
+	v, err := ParseDecimal(value)
+	if err != nil {
+		return nil, err
+	}
+	return NewDecimal(d.value.Pow(v)), nil
+}
+
+func (d *Decimal) apply(value goja.Value, scale goja.Value, rounding string, op func(decimal.Decimal, decimal.Decimal) decimal.Decimal) (*Decimal, error) {
+	v, err := ParseDecimal(value)
+	if err != nil {
+		return nil, err
+	}
+	result := op(d.value, v)
+	if scale != nil && !goja.IsUndefined(scale) {
+		if result, err = round(result, int32(scale.ToInteger()), rounding); err != nil {
+			return nil, err
+		}
+	}
+	return NewDecimal(result), nil
+}
+
+//#endregion
+
+//#region 比较
+
+func (d *Decimal) Compare(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	return valueOrThrow(runtime)(d.compare(call.Argument(0)))
+}
+
+func (d *Decimal) Equals(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	c, err := d.compare(call.Argument(0))
+	return valueOrThrow(runtime)(c == 0, err)
+}
+
+func (d *Decimal) Lt(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	c, err := d.compare(call.Argument(0))
+	return valueOrThrow(runtime)(c < 0, err)
+}
+
+func (d *Decimal) Lte(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	c, err := d.compare(call.Argument(0))
+	return valueOrThrow(runtime)(c <= 0, err)
+}
+
+func (d *Decimal) Gt(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	c, err := d.compare(call.Argument(0))
+	return valueOrThrow(runtime)(c > 0, err)
+}
+
+func (d *Decimal) Gte(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	c, err := d.compare(call.Argument(0))
+	return valueOrThrow(runtime)(c >= 0, err)
+}
+
+func (d *Decimal) compare(value goja.Value) (int, error) {
+	v, err := ParseDecimal(value)
+	if err != nil {
+		return 0, err
+	}
+	return d.value.Cmp(v), nil
+}
+
+func (d *Decimal) Sign() int {
+	return d.value.Sign()
+}
+
+func (d *Decimal) IsZero() bool {
+	return d.value.IsZero()
+}
+
+//#endregion
+
+//#region 转换
+
+// 保留 places 位小数，不足时补 0，默认四舍五入
+func (d *Decimal) ToFixed(call goja.FunctionCall, runtime *goja.Runtime) goja.Value {
+	places := int32(toInt(call.Argument(0)))
+	v, err := round(d.value, places, toString(call.Argument(1)))
+	return valueOrThrow(runtime)(v.StringFixed(places), err)
+}
+
+func (d *Decimal) ToNumber() float64 {
+	return d.value.InexactFloat64()
+}
+
+func (d *Decimal) ToString() string {
+	return d.value.String()
+}
+
+func (d *Decimal) ToJSON() string {
+	return d.value.String()
+}
+
+// 与 decimal.js 一致，valueOf 返回字符串，防止在隐式转换中丢失精度
+func (d *Decimal) ValueOf() string {
+	return d.value.String()
+}
+
+func (d *Decimal) String() string {
+	return d.value.String()
+}
+
+func (d *Decimal) StringFixed(places int32) string {
+	return d.value.StringFixed(places)
+}
+
+// 用于 controller 返回值等在 go 中的 JSON 序列化
+func (d *Decimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.value.String())
+}
+
+// 用于 $native("db") 的参数绑定，以字符串写入数据库，不经过 float64
+func (d *Decimal) Value() (driver.Value, error) {
+	return d.value.String(), nil
+}
+
+//#endregion
+
+//#region 舍入
+
+// 舍入模式，与 decimal.js 中的 ROUND_* 相对应，默认为 half_up
+var roundingModes = map[string]bool{
+	"":          true,
+	"up":        true, // 远离 0
+	"down":      true, // 趋向 0，即截断
+	"ceil":      true, // 趋向正无穷
+	"floor":     true, // 趋向负无穷
+	"half_up":   true, // 四舍五入，0.5 远离 0
+	"half_down": true, // 0.5 趋向 0
+	"half_even": true, // 银行家舍入，0.5 趋向偶数
+}
+
+func round(d decimal.Decimal, scale int32, rounding string) (decimal.Decimal, error) {
+	q, r := d.QuoRem(decimal.New(1, 0), scale)
+	return roundQuotient(q, r, decimal.New(1, 0), scale, rounding)
+}
+
+// 根据截断的商 q 和余数 r 舍入，使得结果为 scale 位小数，其中 r 与被除数同号，且 |r| < |divisor| * 10^-scale
+func roundQuotient(q decimal.Decimal, r decimal.Decimal, divisor decimal.Decimal, scale int32, rounding string) (decimal.Decimal, error) {
+	if !roundingModes[rounding] {
+		return decimal.Zero, &rangeError{message: "invalid rounding mode: " + rounding}
+	}
+	if r.IsZero() {
+		return q, nil
+	}
+
+	sign := r.Sign() * divisor.Sign() // 精确结果的符号
+	ulp := decimal.New(int64(sign), -scale)
+	half := r.Abs().Mul(decimal.New(2, 0)).Cmp(divisor.Abs().Mul(decimal.New(1, -scale))) // 被截断的部分与 0.5 个单位比较
+
+	away := false
+	switch rounding {
+	case "up":
+		away = true
+	case "down":
+	case "ceil":
+		away = sign > 0
+	case "floor":
+		away = sign < 0
+	case "half_down":
+		away = half > 0
+	case "half_even":
+		away = half > 0 || half == 0 && q.Shift(scale).BigInt().Bit(0) == 1
+	default:
+		away = half >= 0
+	}
+	if away {
+		q = q.Add(ulp)
+	}
+	return q, nil
+}
+
+//#endregion
@@ -0,0 +1,172 @@
+package builtin
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/dop251/goja"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/transform"
+)
+
+func init() {
+	Builtins = append(Builtins, func(worker Worker) {
+		runtime := worker.Runtime()
+
+		runtime.Set("TextEncoder", func(call goja.ConstructorCall) *goja.Object {
+			o := runtime.ToValue(&TextEncoder{runtime: runtime, Encoding: "utf-8"}).(*goja.Object)
+			o.SetPrototype(call.This.Prototype())
+			return o
+		})
+
+		runtime.Set("TextDecoder", func(call goja.ConstructorCall) *goja.Object {
+			label := "utf-8"
+			if v := call.Argument(0); !goja.IsUndefined(v) {
+				label = v.String()
+			}
+			d, err := NewTextDecoder(runtime, label)
+			if err != nil {
+				e, _ := runtime.New(runtime.Get("RangeError"), runtime.ToValue(err.Error()))
+				panic(e)
+			}
+			if v := call.Argument(1); !goja.IsUndefined(v) && !goja.IsNull(v) {
+				o := v.ToObject(runtime)
+				d.Fatal = o.Get("fatal") != nil && o.Get("fatal").ToBoolean()
+				d.IgnoreBOM = o.Get("ignoreBOM") != nil && o.Get("ignoreBOM").ToBoolean()
+			}
+			o := runtime.ToValue(d).(*goja.Object)
+			o.SetPrototype(call.This.Prototype())
+			return o
+		})
+	})
+}
+
+// 创建 Uint8Array，其底层的 ArrayBuffer 直接使用 data
+func NewUint8Array(runtime *goja.Runtime, data []byte) goja.Value {
+	v, _ := runtime.New(runtime.Get("Uint8Array"), runtime.ToValue(runtime.NewArrayBuffer(data)))
+	return v
+}
+
+//#region TextEncoder
+
+// 文本编码器，按标准仅支持 UTF-8 编码
+type TextEncoder struct {
+	runtime  *goja.Runtime
+	Encoding string
+}
+
+func (e *TextEncoder) Encode(input goja.Value) goja.Value {
+	if input == nil || goja.IsUndefined(input) {
+		return NewUint8Array(e.runtime, []byte{})
+	}
+	return NewUint8Array(e.runtime, []byte(input.String()))
+}
+
+// 将字符串编码后写入 Uint8Array 中，仅写入完整的字符，返回 { read, written }，其中 read 为已读取的 UTF-16 码元个数
+func (e *TextEncoder) EncodeInto(source string, destination goja.Value) (*goja.Object, error) {
+	dst, ok := destination.Export().([]byte)
+	if !ok {
+		return nil, errors.New("The destination must be a Uint8Array")
+	}
+	read, written := 0, 0
+	for _, r := range source {
+		n := utf8.RuneLen(r)
+		if n < 0 { // 孤立的代理项编码为 U+FFFD
+			r, n = utf8.RuneError, 3
+		}
+		if written+n > len(dst) {
+			break
+		}
+		utf8.EncodeRune(dst[written:], r)
+		written += n
+		read += len(utf16.Encode([]rune{r}))
+	}
+	// 使用对象而不是 map，保证属性的顺序与规范一致
+	o := e.runtime.NewObject()
+	o.Set("read", read)
+	o.Set("written", written)
+	return o, nil
+}
+
+//#endregion
+
+//#region TextDecoder
+
+// 文本解码器，支持 WHATWG Encoding 标准中的编码，如 utf-8、utf-16le、gbk、gb18030、big5、shift_jis 等
+type TextDecoder struct {
+	runtime   *goja.Runtime
+	Encoding  string
+	Fatal     bool
+	IgnoreBOM bool
+	decoder   *encoding.Decoder
+	pending   []byte // 流式解码时未解码完毕的字节
+	started   bool   // 是否已开始解码，用于判断是否需要去除 BOM
+}
+
+func NewTextDecoder(runtime *goja.Runtime, label string) (*TextDecoder, error) {
+	e, err := htmlindex.Get(strings.TrimSpace(label))
+	if err != nil {
+		return nil, errors.New("The encoding label provided ('" + label + "') is invalid")
+	}
+	name, _ := htmlindex.Name(e)
+	return &TextDecoder{runtime: runtime, Encoding: strings.ToLower(name), decoder: e.NewDecoder()}, nil
+}
+
+type TextDecodeOptions struct {
+	Stream bool
+}
+
+// 解码，当 options.stream 为 true 时，末尾不完整的字节将保留到下一次调用时解码
+func (d *TextDecoder) Decode(value goja.Value, options *TextDecodeOptions) (string, error) {
+	var data []byte
+	if value != nil && !goja.IsUndefined(value) && !goja.IsNull(value) {
+		b, ok := ExportBytes(value)
+		if !ok {
+			panic(d.runtime.NewTypeError("The provided value is not of type '(ArrayBuffer or ArrayBufferView)'"))
+		}
+		data = b
+	}
+	stream := options != nil && options.Stream
+
+	input := append(d.pending, data...)
+	src := input
+	d.pending = nil
+	dst := make([]byte, len(src)*3+utf8.UTFMax)
+	var out []byte
+	for {
+		nDst, nSrc, err := d.decoder.Transform(dst, src, !stream)
+		out = append(out, dst[:nDst]...)
+		src = src[nSrc:]
+		if err == transform.ErrShortDst {
+			continue
+		}
+		if err == transform.ErrShortSrc && stream {
+			d.pending = append([]byte{}, src...)
+			err = nil
+		}
+		if err != nil {
+			return "", err
+		}
+		break
+	}
+	if !stream {
+		d.decoder.Reset()
+	}
+
+	s := string(out)
+	if d.Fatal && strings.ContainsRune(s, utf8.RuneError) && !(d.Encoding == "utf-8" && utf8.Valid(input[:len(input)-len(d.pending)])) { // 解码器会将无效的字节替换为 U+FFFD
+		d.pending, d.started = nil, false
+		panic(d.runtime.NewTypeError("The encoded data was not valid for encoding " + d.Encoding))
+	}
+	if !d.started && !d.IgnoreBOM {
+		s = strings.TrimPrefix(s, "\uFEFF")
+	}
+	d.started = stream && (d.started || len(s) > 0)
+
+	return s, nil
+}
+
+//#endregion
@@ -2,6 +2,7 @@ package builtin
 
 import (
 	"errors"
+	"sync/atomic"
 	"time"
 
 	"github.com/dop251/goja"
@@ -40,6 +41,9 @@ type EventLoop struct {
 	microtasks chan func()      // 微任务队列，如 Promise 中的 resolve 和 reject
 	count      int              // 计数器
 	interrupt  chan interface{} // 中断信号，用于中断事件循环
+	busy       atomic.Int64     // 累计执行任务的时长，单位纳秒
+	since      atomic.Int64     // 当前任务开始执行的时间，未在执行任务时为 0
+	generation int              // 重置的次数，用于丢弃上一次执行遗留的异步任务
 }
 
 func NewEventLoop() *EventLoop {
@@ -52,7 +56,11 @@ func NewEventLoop() *EventLoop {
 
 func (l *EventLoop) Run(main func() (goja.Value, error)) (goja.Value, error) {
 	// 执行主线程上的同步任务
-	value, err := main()
+	var value goja.Value
+	var err error
+	l.exec(func() {
+		value, err = main()
+	})
 
 	// 执行任务队列中的异步任务
 L:
@@ -61,9 +69,9 @@ L:
 		case <-l.interrupt:
 			break L
 		case microtask := <-l.microtasks: // 优先执行所有的微任务
-			microtask()
+			l.exec(microtask)
 		case task := <-l.tasks:
-			task()
+			l.exec(task)
 		}
 	}
 
@@ -71,6 +79,26 @@ L:
 	return value, err
 }
 
+// 执行任务并统计其占用执行线程的时长
+func (l *EventLoop) exec(task func()) {
+	start := time.Now().UnixNano()
+	l.since.Store(start)
+	defer func() {
+		l.since.Store(0)
+		l.busy.Add(time.Now().UnixNano() - start)
+	}()
+	task()
+}
+
+// 获取累计执行任务的时长，不包含等待异步任务（如定时器、网络请求）的时间，但包含任务中阻塞的同步调用（如 $native("db")）的时间
+func (l *EventLoop) Elapsed() time.Duration {
+	elapsed := l.busy.Load()
+	if since := l.since.Load(); since > 0 {
+		elapsed += time.Now().UnixNano() - since
+	}
+	return time.Duration(elapsed)
+}
+
 func (l *EventLoop) Interrupt() {
 	if len(l.interrupt) == 0 { // 这里需要防止重复发送中断信号导致过满，从而导致 Run 方法中异步任务队列 select 的阻塞
 		l.interrupt <- nil
@@ -79,6 +107,8 @@ func (l *EventLoop) Interrupt() {
 
 func (l *EventLoop) Reset() {
 	l.count = 0
+	l.busy.Store(0)
+	l.generation++
 	for len(l.tasks) > 0 {
 		<-l.tasks
 	}
@@ -95,16 +125,36 @@ func (l *EventLoop) Reset() {
 //#region 触发器、定时器
 
 type EventTaskTrigger struct {
-	cancelled bool
-	loop      *EventLoop
+	cancelled  bool
+	loop       *EventLoop
+	generation int
 }
 
 func (t *EventTaskTrigger) AddTask(fn func()) {
-	t.loop.tasks <- fn
+	t.loop.tasks <- t.wrap(fn)
+}
+
+// 加入宏任务队列，如果在等待入队的过程中 done 被关闭，则放弃该任务，用于防止长期运行的协程在 vm 实例归还后阻塞
+func (t *EventTaskTrigger) TryAddTask(fn func(), done <-chan struct{}) bool {
+	select {
+	case t.loop.tasks <- t.wrap(fn):
+		return true
+	case <-done:
+		return false
+	}
 }
 
 func (t *EventTaskTrigger) AddMicroTask(fn func()) {
-	t.loop.microtasks <- fn
+	t.loop.microtasks <- t.wrap(fn)
+}
+
+// 事件循环重置后，在协程中完成的异步任务仍可能被加入队列，这里丢弃这些过期的任务，防止其在下一次执行中被调用
+func (t *EventTaskTrigger) wrap(fn func()) func() {
+	return func() {
+		if t.generation == t.loop.generation {
+			fn()
+		}
+	}
 }
 
 func (t *EventTaskTrigger) IsCancelled() bool {
@@ -123,10 +173,24 @@ func (t *EventTaskTrigger) Cancel() bool {
 func (l *EventLoop) NewEventTaskTrigger() *EventTaskTrigger {
 	l.count++
 	return &EventTaskTrigger{
-		loop: l,
+		loop:       l,
+		generation: l.generation,
 	}
 }
 
+// 在新的协程中执行耗时的操作，完成后在事件循环中执行其返回的回调方法，如 resolve 或 reject
+func (l *EventLoop) Async(fn func() func()) {
+	t := l.NewEventTaskTrigger()
+	go func() {
+		callback := fn()
+		t.AddTask(func() {
+			if t.Cancel() {
+				callback()
+			}
+		})
+	}()
+}
+
 type Timeout struct {
 	trigger *EventTaskTrigger
 	timer   *time.Timer
@@ -1,97 +1,589 @@
 package builtin
 
 import (
-	"encoding/json"
-	"github.com/dop251/goja"
+	"bytes"
+	"context"
+	"errors"
 	"io"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// 所有的 fetch 请求共享同一个连接池
+var fetchTransport = func() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConns = 256
+	t.MaxIdleConnsPerHost = 32
+	return t
+}()
+
+var (
+	errFetchInterrupted = errors.New("fetch interrupted")
+	errFetchTimeout     = errors.New("fetch timeout")
 )
 
 func init() {
 	Builtins = append(Builtins, func(worker Worker) {
-		worker.Runtime().Set("fetch", func(url string, options *FetchOptions) (*goja.Promise, error) {
-			if options == nil {
-				options = &FetchOptions{
-					Method: "GET",
-				}
+		runtime := worker.Runtime()
+
+		runtime.Set("Headers", func(call goja.ConstructorCall) *goja.Object {
+			h, err := NewHeaders(runtime, call.Argument(0))
+			if err != nil {
+				panic(runtime.NewTypeError(err.Error()))
 			}
+			o := runtime.ToValue(h).(*goja.Object)
+			o.SetPrototype(call.This.Prototype())
+			return o
+		})
 
-			req, err := http.NewRequest(strings.ToUpper(options.Method), url, strings.NewReader(options.Body))
+		runtime.Set("Request", func(call goja.ConstructorCall) *goja.Object {
+			r, err := NewRequest(worker, call.Argument(0), call.Argument(1))
 			if err != nil {
-				return nil, err
+				panic(runtime.NewTypeError(err.Error()))
 			}
-			for k, v := range options.Headers {
-				req.Header.Set(k, v)
+			o := runtime.ToValue(r).(*goja.Object)
+			o.SetPrototype(call.This.Prototype())
+			return o
+		})
+
+		runtime.Set("Response", func(call goja.ConstructorCall) *goja.Object {
+			r, err := NewResponse(worker, call.Argument(0), call.Argument(1))
+			if err != nil {
+				panic(runtime.NewTypeError(err.Error()))
 			}
+			o := runtime.ToValue(r).(*goja.Object)
+			o.SetPrototype(call.This.Prototype())
+			return o
+		})
 
-			runtime := worker.Runtime()
-			promise, resolve, reject := runtime.NewPromise()
+		runtime.Set("fetch", func(input goja.Value, init goja.Value) *goja.Promise {
+			return fetch(worker, input, init)
+		})
+	})
+}
 
-			t := worker.EventLoop().NewEventTaskTrigger()
-			t.AddTask(func() {
-				c := &http.Client{}
+//#region Headers
 
-				resp, err := c.Do(req)
-				if err != nil {
-					t.AddMicroTask(func() {
-						reject(runtime.NewGoError(err))
-						t.Cancel()
-					})
-					return
-				}
+// 请求头或响应头，名称不区分大小写，同名的多个值在读取时以 ", " 拼接
+type Headers struct {
+	header http.Header
+}
 
-				data, err := io.ReadAll(resp.Body)
-				if err != nil {
-					t.AddMicroTask(func() {
-						reject(runtime.NewGoError(err))
-						t.Cancel()
-					})
-					return
-				}
-				defer resp.Body.Close()
+// 创建 Headers，init 可以是 Headers、[[name, value], ...] 或 { name: value, ... }
+func NewHeaders(runtime *goja.Runtime, init goja.Value) (*Headers, error) {
+	h := &Headers{header: http.Header{}}
+	if init == nil || goja.IsUndefined(init) || goja.IsNull(init) {
+		return h, nil
+	}
+	switch v := init.Export().(type) {
+	case *Headers:
+		h.header = v.header.Clone()
+	case []interface{}:
+		o := init.ToObject(runtime)
+		for i := range v {
+			pair, ok := o.Get(strconv.Itoa(i)).(*goja.Object)
+			if !ok || pair.Get("length").ToInteger() != 2 {
+				return nil, errors.New("header pair must be a [name, value] tuple")
+			}
+			h.header.Add(pair.Get("0").String(), pair.Get("1").String())
+		}
+	default:
+		o := init.ToObject(runtime)
+		for _, k := range o.Keys() {
+			h.header.Add(k, o.Get(k).String())
+		}
+	}
+	return h, nil
+}
 
-				headers := map[string]string{}
-				for k, v := range resp.Header {
-					headers[k] = v[0]
-				}
+func (h *Headers) Append(name string, value string) {
+	h.header.Add(name, value)
+}
 
-				t.AddMicroTask(func() {
-					resolve(&FetchResponse{
-						Status:  resp.StatusCode,
-						Headers: headers,
-						data:    data,
-					})
-					t.Cancel()
-				})
-			})
+func (h *Headers) Set(name string, value string) {
+	h.header.Set(name, value)
+}
 
-			return promise, nil
-		})
+func (h *Headers) Get(name string) interface{} {
+	values := h.header.Values(name)
+	if len(values) == 0 {
+		return nil
+	}
+	return strings.Join(values, ", ")
+}
+
+func (h *Headers) GetSetCookie() []string {
+	return append([]string{}, h.header.Values("Set-Cookie")...)
+}
+
+func (h *Headers) Has(name string) bool {
+	return len(h.header.Values(name)) > 0
+}
+
+func (h *Headers) Delete(name string) {
+	h.header.Del(name)
+}
+
+// 获取所有的名称，按小写字母排序
+func (h *Headers) Keys() []string {
+	keys := make([]string, 0, len(h.header))
+	for k := range h.header {
+		keys = append(keys, strings.ToLower(k))
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (h *Headers) Values() []string {
+	values := make([]string, 0, len(h.header))
+	for _, k := range h.Keys() {
+		values = append(values, h.Get(k).(string))
+	}
+	return values
+}
+
+func (h *Headers) Entries() [][]string {
+	entries := make([][]string, 0, len(h.header))
+	for _, k := range h.Keys() {
+		entries = append(entries, []string{k, h.Get(k).(string)})
+	}
+	return entries
+}
+
+func (h *Headers) ForEach(callback func(value string, name string)) {
+	for _, k := range h.Keys() {
+		callback(h.Get(k).(string), k)
+	}
+}
+
+//#endregion
+
+//#region Body
+
+// 消息体，Request 和 Response 共用的读取方法
+type body struct {
+	worker   Worker
+	Body     *ReadableStream
+	BodyUsed bool
+}
+
+// 设置消息体，字节流被读取后将 bodyUsed 标记为 true
+func (b *body) init(worker Worker, stream *ReadableStream) {
+	b.worker, b.Body, b.BodyUsed = worker, stream, false
+	if stream != nil {
+		stream.disturbed = &b.BodyUsed
+	}
+}
+
+func (b *body) consume(convert func(data []byte) (goja.Value, error)) *goja.Promise {
+	runtime := b.worker.Runtime()
+	promise, resolve, reject := runtime.NewPromise()
+	if b.BodyUsed || (b.Body != nil && b.Body.Locked) {
+		reject(runtime.NewTypeError("Body is unusable: Body has already been read"))
+		return promise
+	}
+	if b.Body == nil {
+		b.BodyUsed = true
+		v, err := convert(nil)
+		if err != nil {
+			reject(err)
+		} else {
+			resolve(v)
+		}
+		return promise
+	}
+	b.Body.readAll(func(data []byte, err error) {
+		if err != nil {
+			reject(runtime.NewGoError(err))
+			return
+		}
+		v, err := convert(data)
+		if err != nil {
+			reject(err)
+			return
+		}
+		resolve(v)
+	})
+	return promise
+}
+
+func (b *body) Text() *goja.Promise {
+	return b.consume(func(data []byte) (goja.Value, error) {
+		return b.worker.Runtime().ToValue(string(data)), nil
 	})
 }
 
-type FetchOptions struct {
-	Method  string
-	Headers map[string]string
-	Body    string
+func (b *body) Json() *goja.Promise {
+	return b.consume(func(data []byte) (goja.Value, error) {
+		runtime := b.worker.Runtime()
+		parse, _ := goja.AssertFunction(runtime.Get("JSON").ToObject(runtime).Get("parse"))
+		return parse(goja.Undefined(), runtime.ToValue(string(data)))
+	})
 }
 
-type FetchResponse struct {
-	Status  int
-	Headers map[string]string
-	data    []byte
+func (b *body) ArrayBuffer() *goja.Promise {
+	return b.consume(func(data []byte) (goja.Value, error) {
+		return b.worker.Runtime().ToValue(b.worker.Runtime().NewArrayBuffer(data)), nil
+	})
+}
+
+func (b *body) Bytes() *goja.Promise {
+	return b.consume(func(data []byte) (goja.Value, error) {
+		return NewUint8Array(b.worker.Runtime(), data), nil
+	})
 }
 
-func (f *FetchResponse) Buffer() Buffer {
-	return f.data
+// 读取为 Buffer，非标准方法
+func (b *body) Buffer() *goja.Promise {
+	return b.consume(func(data []byte) (goja.Value, error) {
+		buf := Buffer(data)
+		return b.worker.Runtime().ToValue(&buf), nil
+	})
+}
+
+// 从 js 值中获取字节数组，支持 Buffer、ArrayBuffer 和 Uint8Array 等类型
+func ExportBytes(value goja.Value) ([]byte, bool) {
+	if value == nil {
+		return nil, false
+	}
+	switch v := value.Export().(type) {
+	case []byte:
+		return v, true
+	case Buffer:
+		return v, true
+	case *Buffer:
+		return *v, true
+	case goja.ArrayBuffer:
+		return v.Bytes(), true
+	}
+	return nil, false
+}
+
+// 将 js 值转换为消息体，返回字节流和默认的 Content-Type
+func extractBody(worker Worker, value goja.Value) (*ReadableStream, string, error) {
+	if value == nil || goja.IsUndefined(value) || goja.IsNull(value) {
+		return nil, "", nil
+	}
+
+	var (
+		data        []byte
+		contentType string
+	)
+	if b, ok := ExportBytes(value); ok {
+		data = b
+	} else {
+		switch v := value.Export().(type) {
+		case *ReadableStream:
+			if v.Locked {
+				return nil, "", errors.New("ReadableStream is locked")
+			}
+			v.Locked = true
+			v.disturb()
+			return v, "", nil
+		case *URLSearchParams:
+			data, contentType = []byte(v.ToString()), "application/x-www-form-urlencoded;charset=UTF-8"
+		case *FormData:
+			var err error
+			if data, contentType, err = v.encode(); err != nil {
+				return nil, "", err
+			}
+		default:
+			data, contentType = []byte(value.String()), "text/plain;charset=UTF-8"
+		}
+	}
+	return NewReadableStream(worker, bytes.NewReader(data), int64(len(data)), nil), contentType, nil
+}
+
+//#endregion
+
+//#region Request
+
+type Request struct {
+	body
+	Method   string
+	Url      string
+	Headers  *Headers
+	Redirect string     // 重定向策略：follow、manual、error
+	Signal   goja.Value // AbortSignal
+	timeout  int64      // 超时时间，单位毫秒，非标准属性
+}
+
+// 创建请求，input 为 url 或 Request，init 为请求的配置项
+func NewRequest(worker Worker, input goja.Value, init goja.Value) (*Request, error) {
+	runtime := worker.Runtime()
+
+	r := &Request{Method: http.MethodGet, Redirect: "follow", Signal: goja.Null()}
+	if v, ok := input.Export().(*Request); ok {
+		if v.BodyUsed || (v.Body != nil && v.Body.Locked) {
+			return nil, errors.New("Request body has already been used")
+		}
+		*r = *v
+		r.Headers = &Headers{header: v.Headers.header.Clone()}
+		r.init(worker, v.Body)
+		if v.Body != nil { // 消息体转移给新的请求
+			v.Body, v.BodyUsed = nil, true
+		}
+	} else {
+		r.init(worker, nil)
+		u, err := url.Parse(input.String())
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, errors.New("Invalid URL: " + input.String())
+		}
+		r.Url = u.String()
+		r.Headers = &Headers{header: http.Header{}}
+	}
+
+	if init == nil || goja.IsUndefined(init) || goja.IsNull(init) {
+		return r, nil
+	}
+	o := init.ToObject(runtime)
+
+	if v := o.Get("method"); v != nil && !goja.IsUndefined(v) {
+		r.Method = v.String()
+		switch m := strings.ToUpper(r.Method); m { // 标准的请求方法不区分大小写
+		case "DELETE", "GET", "HEAD", "OPTIONS", "POST", "PUT", "PATCH":
+			r.Method = m
+		}
+	}
+	if v := o.Get("headers"); v != nil && !goja.IsUndefined(v) {
+		h, err := NewHeaders(runtime, v)
+		if err != nil {
+			return nil, err
+		}
+		r.Headers = h
+	}
+	if v := o.Get("redirect"); v != nil && !goja.IsUndefined(v) {
+		switch r.Redirect = v.String(); r.Redirect {
+		case "follow", "manual", "error":
+		default:
+			return nil, errors.New("invalid redirect: " + r.Redirect)
+		}
+	}
+	if v := o.Get("signal"); v != nil && !goja.IsUndefined(v) {
+		if !goja.IsNull(v) && ExportAbortSignal(v) == nil {
+			return nil, errors.New("signal is not an AbortSignal")
+		}
+		r.Signal = v
+	}
+	if v := o.Get("timeout"); v != nil && !goja.IsUndefined(v) {
+		r.timeout = v.ToInteger()
+	}
+	if v := o.Get("body"); v != nil && !goja.IsUndefined(v) && !goja.IsNull(v) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			return nil, errors.New("Request with GET/HEAD method cannot have body")
+		}
+		stream, contentType, err := extractBody(worker, v)
+		if err != nil {
+			return nil, err
+		}
+		r.init(worker, stream)
+		if contentType != "" && !r.Headers.Has("Content-Type") {
+			r.Headers.Set("Content-Type", contentType)
+		}
+	}
+
+	return r, nil
+}
+
+//#endregion
+
+//#region Response
+
+type Response struct {
+	body
+	Status     int
+	StatusText string
+	Ok         bool
+	Headers    *Headers
+	Url        string
+	Redirected bool
+	Type       string // basic、default
+}
+
+// 创建响应，body 为消息体，init 为 { status, statusText, headers }
+func NewResponse(worker Worker, value goja.Value, init goja.Value) (*Response, error) {
+	runtime := worker.Runtime()
+
+	r := &Response{Status: http.StatusOK, Headers: &Headers{header: http.Header{}}, Type: "default"}
+	if init != nil && !goja.IsUndefined(init) && !goja.IsNull(init) {
+		o := init.ToObject(runtime)
+		if v := o.Get("status"); v != nil && !goja.IsUndefined(v) {
+			r.Status = int(v.ToInteger())
+			if r.Status < 200 || r.Status > 599 {
+				return nil, errors.New("status must be in the range 200 to 599")
+			}
+		}
+		if v := o.Get("statusText"); v != nil && !goja.IsUndefined(v) {
+			r.StatusText = v.String()
+		}
+		if v := o.Get("headers"); v != nil && !goja.IsUndefined(v) {
+			h, err := NewHeaders(runtime, v)
+			if err != nil {
+				return nil, err
+			}
+			r.Headers = h
+		}
+	}
+	r.Ok = r.Status >= 200 && r.Status < 300
+
+	stream, contentType, err := extractBody(worker, value)
+	if err != nil {
+		return nil, err
+	}
+	r.init(worker, stream)
+	if contentType != "" && !r.Headers.Has("Content-Type") {
+		r.Headers.Set("Content-Type", contentType)
+	}
+
+	return r, nil
+}
+
+//#endregion
+
+func fetch(worker Worker, input goja.Value, init goja.Value) *goja.Promise {
+	runtime := worker.Runtime()
+	promise, resolve, reject := runtime.NewPromise()
+
+	r, err := NewRequest(worker, input, init)
+	if err != nil {
+		reject(runtime.NewTypeError(err.Error()))
+		return promise
+	}
+
+	// 请求的上下文，在中断信号、超时或 vm 实例被中断时取消
+	ctx, cancel := context.WithCancelCause(context.Background())
+	worker.AddDefer(func() {
+		cancel(errFetchInterrupted)
+	})
+	signal := ExportAbortSignal(r.Signal)
+	if signal != nil {
+		if signal.Context().Err() != nil {
+			reject(signal.Reason())
+			return promise
+		}
+		context.AfterFunc(signal.Context(), func() {
+			cancel(errAborted)
+		})
+	}
+	if r.timeout > 0 {
+		timer := time.AfterFunc(time.Duration(r.timeout)*time.Millisecond, func() {
+			cancel(errFetchTimeout)
+		})
+		worker.AddDefer(func() {
+			timer.Stop()
+		})
+	}
+
+	var reader io.Reader
+	size := int64(0)
+	if r.Body != nil {
+		reader, size = r.Body.reader, r.Body.size
+		r.BodyUsed = true
+	}
+	req, err := http.NewRequestWithContext(ctx, r.Method, r.Url, reader)
+	if err != nil {
+		reject(runtime.NewTypeError(err.Error()))
+		return promise
+	}
+	req.Header = r.Headers.header.Clone()
+	if size >= 0 && reader != nil {
+		req.ContentLength = size
+	}
+
+	span := worker.StartSpan("fetch "+r.Method, "client")
+	span.SetAttribute("http.method", r.Method)
+	span.SetAttribute("http.url", r.Url)
+	if span != nil && req.Header.Get("traceparent") == "" { // 向下游传递调用链路
+		req.Header.Set("traceparent", span.Traceparent())
+	}
+
+	client := &http.Client{
+		Transport: fetchTransport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			switch r.Redirect {
+			case "manual":
+				return http.ErrUseLastResponse
+			case "error":
+				return errors.New("unexpected redirect")
+			}
+			if len(via) >= 20 {
+				return errors.New("too many redirects")
+			}
+			return nil
+		},
+	}
+
+	worker.EventLoop().Async(func() func() {
+		resp, err := client.Do(req)
+		if err == nil {
+			span.SetAttribute("http.status_code", resp.StatusCode)
+		}
+		span.SetError(err)
+		span.Finish() // 不包括响应消息体的读取
+		return func() {
+			if err != nil {
+				switch context.Cause(ctx) {
+				case errAborted:
+					reject(signal.Reason())
+				case errFetchTimeout:
+					reject(NewDOMException(runtime, "TimeoutError", "The operation was aborted due to timeout"))
+				default:
+					reject(runtime.NewTypeError("fetch failed: " + err.Error()))
+				}
+				return
+			}
+
+			size := resp.ContentLength
+			if size < 0 {
+				size = -1
+			}
+			stream := NewReadableStream(worker, &fetchBodyReader{resp.Body, ctx}, size, func() {
+				resp.Body.Close()
+				cancel(nil)
+			})
+			res := &Response{
+				Status:     resp.StatusCode,
+				StatusText: http.StatusText(resp.StatusCode),
+				Ok:         resp.StatusCode >= 200 && resp.StatusCode < 300,
+				Headers:    &Headers{header: resp.Header},
+				Url:        resp.Request.URL.String(),
+				Redirected: resp.Request.URL.String() != r.Url,
+				Type:       "basic",
+			}
+			res.init(worker, stream)
+
+			o := runtime.ToValue(res).(*goja.Object)
+			if c, ok := runtime.Get("Response").(*goja.Object); ok {
+				o.SetPrototype(c.Get("prototype").ToObject(runtime))
+			}
+			resolve(o)
+		}
+	})
+
+	return promise
 }
 
-func (f *FetchResponse) Json() (v map[string]interface{}, err error) {
-	err = json.Unmarshal(f.data, &v)
-	return
+// 响应消息体的读取器，在请求被中断或超时后返回对应的异常
+type fetchBodyReader struct {
+	io.Reader
+	ctx context.Context
 }
 
-func (f *FetchResponse) Text() string {
-	return string(f.data)
+func (r *fetchBodyReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err != nil && err != io.EOF {
+		switch context.Cause(r.ctx) {
+		case errAborted:
+			return n, errors.New("AbortError: This operation was aborted")
+		case errFetchTimeout:
+			return n, errors.New("TimeoutError: The operation was aborted due to timeout")
+		}
+	}
+	return n, err
 }
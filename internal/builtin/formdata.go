@@ -0,0 +1,159 @@
+package builtin
+
+import (
+	"bytes"
+	"mime/multipart"
+
+	"github.com/dop251/goja"
+)
+
+func init() {
+	Builtins = append(Builtins, func(worker Worker) {
+		runtime := worker.Runtime()
+
+		runtime.Set("FormData", func(call goja.ConstructorCall) *goja.Object {
+			o := runtime.ToValue(&FormData{}).(*goja.Object)
+			o.SetPrototype(call.This.Prototype())
+			return o
+		})
+	})
+}
+
+type formDataEntry struct {
+	name     string
+	value    string
+	filename string
+	data     []byte
+	file     bool
+}
+
+// 表单数据，作为 fetch 的消息体时将以 multipart/form-data 格式发送
+type FormData struct {
+	entries []formDataEntry
+}
+
+func newFormDataEntry(name string, value goja.Value, filename goja.Value) formDataEntry {
+	if data, ok := ExportBytes(value); ok {
+		e := formDataEntry{name: name, data: data, filename: "blob", file: true}
+		if filename != nil && !goja.IsUndefined(filename) {
+			e.filename = filename.String()
+		}
+		return e
+	}
+	return formDataEntry{name: name, value: value.String()}
+}
+
+func (f *FormData) Append(name string, value goja.Value, filename goja.Value) {
+	f.entries = append(f.entries, newFormDataEntry(name, value, filename))
+}
+
+func (f *FormData) Set(name string, value goja.Value, filename goja.Value) {
+	e := newFormDataEntry(name, value, filename)
+	for i := range f.entries {
+		if f.entries[i].name == name {
+			f.entries[i] = e
+			f.delete(name, i+1)
+			return
+		}
+	}
+	f.entries = append(f.entries, e)
+}
+
+func (f *FormData) Delete(name string) {
+	f.delete(name, 0)
+}
+
+// 删除指定名称的字段，from 为开始查找的位置
+func (f *FormData) delete(name string, from int) {
+	entries := f.entries[:from]
+	for _, e := range f.entries[from:] {
+		if e.name != name {
+			entries = append(entries, e)
+		}
+	}
+	f.entries = entries
+}
+
+// 获取字段的值，文件字段返回 { name, size, data } 对象
+func (f *FormData) Get(name string) interface{} {
+	for _, e := range f.entries {
+		if e.name == name {
+			return e.export()
+		}
+	}
+	return nil
+}
+
+func (f *FormData) GetAll(name string) []interface{} {
+	values := make([]interface{}, 0)
+	for _, e := range f.entries {
+		if e.name == name {
+			values = append(values, e.export())
+		}
+	}
+	return values
+}
+
+func (f *FormData) Has(name string) bool {
+	for _, e := range f.entries {
+		if e.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *FormData) Keys() []string {
+	keys := make([]string, 0, len(f.entries))
+	for _, e := range f.entries {
+		keys = append(keys, e.name)
+	}
+	return keys
+}
+
+func (f *FormData) Entries() [][]interface{} {
+	entries := make([][]interface{}, 0, len(f.entries))
+	for _, e := range f.entries {
+		entries = append(entries, []interface{}{e.name, e.export()})
+	}
+	return entries
+}
+
+func (f *FormData) ForEach(callback func(value interface{}, name string)) {
+	for _, e := range f.entries {
+		callback(e.export(), e.name)
+	}
+}
+
+func (e formDataEntry) export() interface{} {
+	if !e.file {
+		return e.value
+	}
+	data := Buffer(e.data)
+	return map[string]interface{}{"name": e.filename, "size": len(e.data), "data": &data}
+}
+
+// 编码为 multipart/form-data 格式，返回消息体和 Content-Type
+func (f *FormData) encode() ([]byte, string, error) {
+	buf := &bytes.Buffer{}
+	w := multipart.NewWriter(buf)
+	for _, e := range f.entries {
+		if !e.file {
+			if err := w.WriteField(e.name, e.value); err != nil {
+				return nil, "", err
+			}
+			continue
+		}
+		part, err := w.CreateFormFile(e.name, e.filename)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err = part.Write(e.data); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), w.FormDataContentType(), nil
+}
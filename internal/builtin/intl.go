@@ -0,0 +1,595 @@
+package builtin
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+func init() {
+	Builtins = append(Builtins, func(worker Worker) {
+		runtime := worker.Runtime()
+
+		intl := runtime.NewObject()
+		intl.Set("DateTimeFormat", func(call goja.ConstructorCall) *goja.Object {
+			f := newDateTimeFormat(runtime, call.Argument(0), call.Argument(1), "date")
+			o := runtime.ToValue(f).(*goja.Object)
+			o.SetPrototype(call.This.Prototype())
+			return o
+		})
+		runtime.Set("Intl", intl)
+
+		// 覆盖 Date.prototype.toLocale*String，支持指定语言和时区
+		prototype := runtime.Get("Date").ToObject(runtime).Get("prototype").ToObject(runtime)
+		for name, defaults := range map[string]string{"toLocaleString": "all", "toLocaleDateString": "date", "toLocaleTimeString": "time"} {
+			name, defaults := name, defaults
+			prototype.Set(name, func(call goja.FunctionCall) goja.Value {
+				t, ok := call.This.Export().(time.Time)
+				if !ok {
+					if o, isObject := call.This.(*goja.Object); isObject && o.ClassName() == "Date" {
+						return runtime.ToValue("Invalid Date")
+					}
+					panic(runtime.NewTypeError("Method Date.prototype." + name + " is called on incompatible receiver"))
+				}
+				return runtime.ToValue(newDateTimeFormat(runtime, call.Argument(0), call.Argument(1), defaults).format(t))
+			})
+		}
+	})
+}
+
+//#region 语言
+
+// 日期相关的本地化数据，模板中的 y、M、d、h、m、s、a 在格式化时替换为对应宽度的格式
+type dateLocale struct {
+	months        []string
+	shortMonths   []string
+	weekdays      []string
+	shortWeekdays []string
+	am, pm        string
+	eras          []string // 公元前、公元
+	hour12        bool     // 是否默认使用 12 小时制
+	numeric       string   // 数字月份的日期模板，如 M/d/y
+	text          string   // 文本月份的日期模板，如 MMM d, y
+	numericMonth  bool     // 文本月份是否仍以数字表示，如 1月
+	weekdayFirst  bool     // 星期是否位于日期之前
+	weekdayJoin   string   // 星期与日期之间的分隔
+	time12        string
+	time24        string
+	join          string // 日期与时间之间的分隔
+}
+
+var dateLocales = map[string]*dateLocale{
+	"en": {
+		months:        []string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+		shortMonths:   []string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+		weekdays:      []string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+		shortWeekdays: []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"},
+		am:            "AM",
+		pm:            "PM",
+		eras:          []string{"BC", "AD"},
+		hour12:        true,
+		numeric:       "M/d/y",
+		text:          "MMM d, y",
+		weekdayFirst:  true,
+		weekdayJoin:   ", ",
+		time12:        "h:mm:ss a",
+		time24:        "HH:mm:ss",
+		join:          ", ",
+	},
+	"zh": {
+		months:        []string{"1月", "2月", "3月", "4月", "5月", "6月", "7月", "8月", "9月", "10月", "11月", "12月"},
+		shortMonths:   []string{"1月", "2月", "3月", "4月", "5月", "6月", "7月", "8月", "9月", "10月", "11月", "12月"},
+		weekdays:      []string{"星期日", "星期一", "星期二", "星期三", "星期四", "星期五", "星期六"},
+		shortWeekdays: []string{"周日", "周一", "周二", "周三", "周四", "周五", "周六"},
+		am:            "上午",
+		pm:            "下午",
+		eras:          []string{"公元前", "公元"},
+		numeric:       "y/M/d",
+		text:          "y年M月d日",
+		numericMonth:  true,
+		time12:        "ah:mm:ss",
+		time24:        "HH:mm:ss",
+		join:          " ",
+	},
+	"ja": {
+		months:        []string{"1月", "2月", "3月", "4月", "5月", "6月", "7月", "8月", "9月", "10月", "11月", "12月"},
+		shortMonths:   []string{"1月", "2月", "3月", "4月", "5月", "6月", "7月", "8月", "9月", "10月", "11月", "12月"},
+		weekdays:      []string{"日曜日", "月曜日", "火曜日", "水曜日", "木曜日", "金曜日", "土曜日"},
+		shortWeekdays: []string{"日", "月", "火", "水", "木", "金", "土"},
+		am:            "午前",
+		pm:            "午後",
+		eras:          []string{"紀元前", "西暦"},
+		numeric:       "y/M/d",
+		text:          "y年M月d日",
+		numericMonth:  true,
+		time12:        "ah:mm:ss",
+		time24:        "H:mm:ss",
+		join:          " ",
+	},
+	"ko": {
+		months:        []string{"1월", "2월", "3월", "4월", "5월", "6월", "7월", "8월", "9월", "10월", "11월", "12월"},
+		shortMonths:   []string{"1월", "2월", "3월", "4월", "5월", "6월", "7월", "8월", "9월", "10월", "11월", "12월"},
+		weekdays:      []string{"일요일", "월요일", "화요일", "수요일", "목요일", "금요일", "토요일"},
+		shortWeekdays: []string{"일", "월", "화", "수", "목", "금", "토"},
+		am:            "오전",
+		pm:            "오후",
+		eras:          []string{"기원전", "서기"},
+		hour12:        true,
+		numeric:       "y. M. d.",
+		text:          "y년 M월 d일",
+		numericMonth:  true,
+		weekdayJoin:   " ",
+		time12:        "a h:mm:ss",
+		time24:        "HH:mm:ss",
+		join:          " ",
+	},
+	"de": {
+		months:        []string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		shortMonths:   []string{"Jan.", "Feb.", "März", "Apr.", "Mai", "Juni", "Juli", "Aug.", "Sept.", "Okt.", "Nov.", "Dez."},
+		weekdays:      []string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+		shortWeekdays: []string{"So.", "Mo.", "Di.", "Mi.", "Do.", "Fr.", "Sa."},
+		am:            "AM",
+		pm:            "PM",
+		eras:          []string{"v. Chr.", "n. Chr."},
+		numeric:       "d.M.y",
+		text:          "d. MMM y",
+		weekdayFirst:  true,
+		weekdayJoin:   ", ",
+		time12:        "h:mm:ss a",
+		time24:        "HH:mm:ss",
+		join:          ", ",
+	},
+	"fr": {
+		months:        []string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		shortMonths:   []string{"janv.", "févr.", "mars", "avr.", "mai", "juin", "juil.", "août", "sept.", "oct.", "nov.", "déc."},
+		weekdays:      []string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+		shortWeekdays: []string{"dim.", "lun.", "mar.", "mer.", "jeu.", "ven.", "sam."},
+		am:            "AM",
+		pm:            "PM",
+		eras:          []string{"av. J.-C.", "ap. J.-C."},
+		numeric:       "dd/MM/y",
+		text:          "d MMM y",
+		weekdayFirst:  true,
+		weekdayJoin:   " ",
+		time12:        "h:mm:ss a",
+		time24:        "HH:mm:ss",
+		join:          " ",
+	},
+	"es": {
+		months:        []string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		shortMonths:   []string{"ene", "feb", "mar", "abr", "may", "jun", "jul", "ago", "sept", "oct", "nov", "dic"},
+		weekdays:      []string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"},
+		shortWeekdays: []string{"dom", "lun", "mar", "mié", "jue", "vie", "sáb"},
+		am:            "a. m.",
+		pm:            "p. m.",
+		eras:          []string{"a. C.", "d. C."},
+		numeric:       "d/M/y",
+		text:          "d MMM y",
+		weekdayFirst:  true,
+		weekdayJoin:   ", ",
+		time12:        "h:mm:ss a",
+		time24:        "H:mm:ss",
+		join:          ", ",
+	},
+}
+
+// 解析语言标签，如 zh-CN、en_us，返回规范化的标签及其本地化数据，不支持的语言回退为 en-US
+func resolveLocale(tag string) (string, *dateLocale) {
+	parts := strings.Split(strings.ReplaceAll(tag, "_", "-"), "-")
+	l, ok := dateLocales[strings.ToLower(parts[0])]
+	if !ok {
+		return "en-US", dateLocales["en"]
+	}
+	for i, part := range parts {
+		switch {
+		case i == 0:
+			parts[i] = strings.ToLower(part)
+		case len(part) == 2:
+			parts[i] = strings.ToUpper(part)
+		case len(part) == 4:
+			parts[i] = strings.ToUpper(part[:1]) + strings.ToLower(part[1:])
+		}
+	}
+	return strings.Join(parts, "-"), l
+}
+
+//#endregion
+
+//#region Intl.DateTimeFormat
+
+type DateTimeFormatOptions struct {
+	TimeZone               string
+	DateStyle              string // full、long、medium、short
+	TimeStyle              string
+	Weekday                string // long、short、narrow
+	Era                    string
+	Year                   string // numeric、2-digit
+	Month                  string // numeric、2-digit、long、short、narrow
+	Day                    string
+	Hour                   string
+	Minute                 string
+	Second                 string
+	FractionalSecondDigits int    // 1 - 3
+	TimeZoneName           string // short、long、shortOffset、longOffset
+	Hour12                 *bool
+}
+
+type DateTimeFormatPart struct {
+	Type  string
+	Value string
+}
+
+// 与 Intl.DateTimeFormat 相似的日期格式化，支持的语言见 dateLocales
+type DateTimeFormat struct {
+	runtime  *goja.Runtime
+	tag      string
+	locale   *dateLocale
+	location *time.Location
+	options  *DateTimeFormatOptions
+	hour12   bool
+	layout   string // 由选项生成的格式，如 MMM d, y, h:mm:ss a
+}
+
+var dateTimeFormatValues = map[string][]string{
+	"dateStyle":    {"full", "long", "medium", "short"},
+	"timeStyle":    {"full", "long", "medium", "short"},
+	"weekday":      {"long", "short", "narrow"},
+	"era":          {"long", "short", "narrow"},
+	"year":         {"numeric", "2-digit"},
+	"month":        {"numeric", "2-digit", "long", "short", "narrow"},
+	"day":          {"numeric", "2-digit"},
+	"hour":         {"numeric", "2-digit"},
+	"minute":       {"numeric", "2-digit"},
+	"second":       {"numeric", "2-digit"},
+	"timeZoneName": {"short", "long", "shortOffset", "longOffset"},
+}
+
+// defaults 为未指定任何日期和时间选项时的默认输出：date 为日期，time 为时间，all 为日期和时间
+func newDateTimeFormat(runtime *goja.Runtime, locales goja.Value, options goja.Value, defaults string) *DateTimeFormat {
+	tag := ""
+	if locales != nil && !goja.IsUndefined(locales) && !goja.IsNull(locales) {
+		if v, ok := locales.Export().([]interface{}); ok {
+			if len(v) > 0 {
+				tag, _ = v[0].(string)
+			}
+		} else {
+			tag = locales.String()
+		}
+	}
+
+	o := &DateTimeFormatOptions{}
+	if options != nil && !goja.IsUndefined(options) && !goja.IsNull(options) {
+		if err := runtime.ExportTo(options, o); err != nil {
+			panic(runtime.NewTypeError(err.Error()))
+		}
+	}
+	if err := o.validate(); err != nil {
+		panic(newRangeError(runtime, err.Error()))
+	}
+
+	f := &DateTimeFormat{runtime: runtime, options: o}
+	f.tag, f.locale = resolveLocale(tag)
+	f.location = (&dateOptions{TimeZone: o.TimeZone}).location(runtime)
+	f.hour12 = f.locale.hour12
+	if o.Hour12 != nil {
+		f.hour12 = *o.Hour12
+	}
+
+	if o.DateStyle != "" || o.TimeStyle != "" {
+		if o.Weekday != "" || o.Era != "" || o.Year != "" || o.Month != "" || o.Day != "" || o.Hour != "" || o.Minute != "" || o.Second != "" || o.FractionalSecondDigits != 0 || o.TimeZoneName != "" {
+			panic(runtime.NewTypeError("Can't set option dateStyle or timeStyle with other date and time options"))
+		}
+		o.expandStyles()
+	} else if o.Weekday == "" && o.Year == "" && o.Month == "" && o.Day == "" && o.Hour == "" && o.Minute == "" && o.Second == "" && o.FractionalSecondDigits == 0 {
+		if defaults != "time" {
+			o.Year, o.Month, o.Day = "numeric", "numeric", "numeric"
+		}
+		if defaults != "date" {
+			o.Hour, o.Minute, o.Second = "numeric", "numeric", "numeric"
+		}
+	}
+	f.layout = f.compose()
+	return f
+}
+
+func (o *DateTimeFormatOptions) validate() error {
+	values := map[string]string{
+		"dateStyle": o.DateStyle, "timeStyle": o.TimeStyle, "weekday": o.Weekday, "era": o.Era, "year": o.Year, "month": o.Month,
+		"day": o.Day, "hour": o.Hour, "minute": o.Minute, "second": o.Second, "timeZoneName": o.TimeZoneName,
+	}
+	for name, value := range values {
+		if value == "" {
+			continue
+		}
+		valid := false
+		for _, v := range dateTimeFormatValues[name] {
+			valid = valid || v == value
+		}
+		if !valid {
+			return errors.New("Value " + value + " out of range for Intl.DateTimeFormat options property " + name)
+		}
+	}
+	if o.FractionalSecondDigits < 0 || o.FractionalSecondDigits > 3 {
+		return errors.New("fractionalSecondDigits value is out of range")
+	}
+	return nil
+}
+
+func (o *DateTimeFormatOptions) expandStyles() {
+	switch o.DateStyle {
+	case "full":
+		o.Weekday, o.Year, o.Month, o.Day = "long", "numeric", "long", "numeric"
+	case "long":
+		o.Year, o.Month, o.Day = "numeric", "long", "numeric"
+	case "medium":
+		o.Year, o.Month, o.Day = "numeric", "short", "numeric"
+	case "short":
+		o.Year, o.Month, o.Day = "numeric", "numeric", "numeric"
+	}
+	switch o.TimeStyle {
+	case "full":
+		o.Hour, o.Minute, o.Second, o.TimeZoneName = "numeric", "numeric", "numeric", "long"
+	case "long":
+		o.Hour, o.Minute, o.Second, o.TimeZoneName = "numeric", "numeric", "numeric", "short"
+	case "medium":
+		o.Hour, o.Minute, o.Second = "numeric", "numeric", "numeric"
+	case "short":
+		o.Hour, o.Minute = "numeric", "numeric"
+	}
+}
+
+// 根据选项和语言的模板生成格式
+func (f *DateTimeFormat) compose() string {
+	o, l := f.options, f.locale
+
+	date := ""
+	if o.Year != "" || o.Month != "" || o.Day != "" {
+		template := l.numeric
+		if o.Month == "long" || o.Month == "short" || o.Month == "narrow" {
+			template = l.text
+		}
+		date = composeTemplate(template, false, func(token string) string {
+			switch token[0] {
+			case 'y':
+				return map[string]string{"numeric": "y", "2-digit": "yy"}[o.Year]
+			case 'M':
+				if l.numericMonth {
+					return "M"
+				}
+				return map[string]string{"numeric": token, "2-digit": "MM", "short": "MMM", "long": "MMMM", "narrow": "MMMMM"}[o.Month]
+			case 'd':
+				return map[string]string{"numeric": token, "2-digit": "dd"}[o.Day]
+			}
+			return token
+		})
+	}
+	if o.Era != "" && date != "" {
+		if l.weekdayFirst || l.weekdayJoin != "" {
+			date += " G"
+		} else {
+			date = "G" + date
+		}
+	}
+	if o.Weekday != "" {
+		weekday := map[string]string{"long": "EEEE", "short": "E", "narrow": "EEEEE"}[o.Weekday]
+		switch {
+		case date == "":
+			date = weekday
+		case l.weekdayFirst:
+			date = weekday + l.weekdayJoin + date
+		default:
+			date = date + l.weekdayJoin + weekday
+		}
+	}
+
+	clock := ""
+	if o.Hour != "" || o.Minute != "" || o.Second != "" || o.FractionalSecondDigits != 0 {
+		template := l.time24
+		if f.hour12 {
+			template = l.time12
+		}
+		clock = composeTemplate(template, true, func(token string) string {
+			switch token[0] {
+			case 'a':
+				if o.Hour == "" {
+					return ""
+				}
+				return token
+			case 'h', 'H':
+				letter := "H"
+				if f.hour12 {
+					letter = "h"
+				}
+				switch o.Hour {
+				case "":
+					return ""
+				case "2-digit":
+					return letter + letter
+				}
+				return strings.Repeat(letter, len(token))
+			case 'm':
+				if o.Minute == "" {
+					return ""
+				}
+				return "mm"
+			case 's':
+				if o.Second == "" && o.FractionalSecondDigits == 0 {
+					return ""
+				}
+				if o.FractionalSecondDigits > 0 {
+					return "ss." + strings.Repeat("S", o.FractionalSecondDigits)
+				}
+				return "ss"
+			}
+			return token
+		})
+	}
+	if o.TimeZoneName != "" {
+		zone := map[string]string{"short": "z", "long": "zzzz", "shortOffset": "'GMT'ZZZZZ", "longOffset": "'GMT'ZZZZZ"}[o.TimeZoneName]
+		if clock != "" {
+			clock += " " + zone
+		} else if date != "" {
+			date += l.join + zone
+		} else {
+			clock = zone
+		}
+	}
+
+	switch {
+	case date == "":
+		return clock
+	case clock == "":
+		return date
+	}
+	return date + l.join + clock
+}
+
+// 替换模板中的格式，replace 返回空字符串时移除该格式及与其相邻的一段文本：
+// 日期模板中移除其后的文本（如 y年M月d日 中的 年），时间模板中移除其前的文本（如 h:mm:ss a 中的 :），没有时则移除另一侧的文本
+func composeTemplate(template string, preceding bool, replace func(token string) string) string {
+	type segment struct {
+		literal bool
+		text    string
+	}
+	segments := make([]segment, 0)
+	pos := 0
+	for _, a := range dateTokenPattern.FindAllStringIndex(template, -1) {
+		if a[0] > pos {
+			segments = append(segments, segment{true, template[pos:a[0]]})
+		}
+		segments = append(segments, segment{false, template[a[0]:a[1]]})
+		pos = a[1]
+	}
+	if pos < len(template) {
+		segments = append(segments, segment{true, template[pos:]})
+	}
+
+	for i := 0; i < len(segments); {
+		if segments[i].literal {
+			i++
+			continue
+		}
+		if v := replace(segments[i].text); v != "" {
+			segments[i].text = v
+			i++
+			continue
+		}
+		before := i > 0 && segments[i-1].literal
+		after := i+1 < len(segments) && segments[i+1].literal
+		switch {
+		case before && (preceding || !after):
+			segments = append(segments[:i-1], segments[i+1:]...)
+			i--
+		case after:
+			segments = append(segments[:i], segments[i+2:]...)
+		default:
+			segments = append(segments[:i], segments[i+1:]...)
+		}
+	}
+
+	var b strings.Builder
+	for _, s := range segments {
+		b.WriteString(s.text)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func (f *DateTimeFormat) time(date goja.Value) time.Time {
+	if date == nil || goja.IsUndefined(date) {
+		return time.Now()
+	}
+	switch v := date.Export().(type) {
+	case time.Time:
+		return v
+	case int64:
+		return time.UnixMilli(v)
+	case float64:
+		if v == v {
+			return time.UnixMilli(int64(v))
+		}
+	}
+	panic(newRangeError(f.runtime, "Invalid time value"))
+}
+
+func (f *DateTimeFormat) format(t time.Time) string {
+	return timeToString(t.In(f.location), f.layout, f.locale)
+}
+
+func (f *DateTimeFormat) Format(date goja.Value) string {
+	return f.format(f.time(date))
+}
+
+// 按组成部分格式化，如 [{ type: "month", value: "Jan" }, { type: "literal", value: " " }, ...]
+func (f *DateTimeFormat) FormatToParts(date goja.Value) []*DateTimeFormatPart {
+	t := f.time(date).In(f.location)
+	parts := make([]*DateTimeFormatPart, 0)
+	add := func(kind string, value string) {
+		if value == "" {
+			return
+		}
+		if n := len(parts); kind == "literal" && n > 0 && parts[n-1].Type == "literal" {
+			parts[n-1].Value += value
+			return
+		}
+		parts = append(parts, &DateTimeFormatPart{kind, value})
+	}
+
+	pos, prefix := 0, ""
+	for _, a := range dateTokenPattern.FindAllStringIndex(f.layout, -1) {
+		add("literal", f.layout[pos:a[0]])
+		token := f.layout[a[0]:a[1]]
+		kind := map[byte]string{
+			'y': "year", 'M': "month", 'd': "day", 'E': "weekday", 'a': "dayPeriod", 'H': "hour", 'h': "hour", 'm': "minute",
+			's': "second", 'S': "fractionalSecond", 'G': "era", 'Z': "timeZoneName", 'X': "timeZoneName", 'z': "timeZoneName", 'V': "timeZoneName",
+		}[token[0]]
+		switch {
+		case token == "'GMT'": // 与其后的时区偏移合并为一个部分，如 GMT+08:00
+			prefix = "GMT"
+		case kind == "":
+			add("literal", formatDateToken(t, token, f.locale))
+		default:
+			add(kind, prefix+formatDateToken(t, token, f.locale))
+			prefix = ""
+		}
+		pos = a[1]
+	}
+	add("literal", f.layout[pos:])
+	return parts
+}
+
+func (f *DateTimeFormat) ResolvedOptions() map[string]interface{} {
+	o := f.options
+	resolved := map[string]interface{}{
+		"locale":          f.tag,
+		"calendar":        "gregory",
+		"numberingSystem": "latn",
+		"timeZone":        f.location.String(),
+	}
+	if o.Hour != "" {
+		resolved["hour12"] = f.hour12
+		resolved["hourCycle"] = "h23"
+		if f.hour12 {
+			resolved["hourCycle"] = "h12"
+		}
+	}
+	for name, value := range map[string]string{
+		"dateStyle": o.DateStyle, "timeStyle": o.TimeStyle, "weekday": o.Weekday, "era": o.Era, "year": o.Year, "month": o.Month,
+		"day": o.Day, "hour": o.Hour, "minute": o.Minute, "second": o.Second, "timeZoneName": o.TimeZoneName,
+	} {
+		if value != "" {
+			resolved[name] = value
+		}
+	}
+	if o.FractionalSecondDigits > 0 {
+		resolved["fractionalSecondDigits"] = o.FractionalSecondDigits
+	}
+	return resolved
+}
+
+func (f *DateTimeFormat) String() string {
+	return "[object Intl.DateTimeFormat]"
+}
+
+//#endregion
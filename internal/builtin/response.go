@@ -43,6 +43,8 @@ func init() {
 					output.data = []byte(s)
 				} else if b, ok := v.(Buffer); ok {
 					output.data = []byte(b)
+				} else if b, ok := v.(*Buffer); ok {
+					output.data = []byte(*b)
 				} else if t, ok := v.([]byte); ok {
 					output.data = t
 				} else {
@@ -0,0 +1,133 @@
+package builtin
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/dop251/goja"
+)
+
+// 只读的字节流，用于 fetch 的请求和响应消息体，读取操作在协程中执行，不阻塞事件循环
+type ReadableStream struct {
+	worker    Worker
+	reader    io.Reader
+	size      int64 // 字节流的长度，-1 表示未知
+	closer    func()
+	closeOnce sync.Once
+	last      chan struct{} // 上一次读取的完成信号，用于保证按调用的顺序读取
+	disturbed *bool         // 被读取后的标记，对应消息体的 bodyUsed 属性
+	Locked    bool
+}
+
+func NewReadableStream(worker Worker, reader io.Reader, size int64, closer func()) *ReadableStream {
+	s := &ReadableStream{worker: worker, reader: reader, size: size, closer: closer}
+	worker.AddDefer(s.close) // 执行结束或被中断时，关闭未读取完毕的字节流
+	return s
+}
+
+func (s *ReadableStream) close() {
+	s.closeOnce.Do(func() {
+		if s.closer != nil {
+			s.closer()
+		}
+	})
+}
+
+func (s *ReadableStream) disturb() {
+	if s.disturbed != nil {
+		*s.disturbed = true
+	}
+}
+
+// 在协程中按调用顺序执行读取操作，完成后在事件循环中执行回调
+func (s *ReadableStream) async(read func() func()) {
+	prev, done := s.last, make(chan struct{})
+	s.last = done
+	s.worker.EventLoop().Async(func() func() {
+		if prev != nil {
+			<-prev
+		}
+		defer close(done)
+		return read()
+	})
+}
+
+// 读取全部字节并关闭字节流
+func (s *ReadableStream) readAll(callback func(data []byte, err error)) {
+	s.disturb()
+	s.Locked = true
+	s.async(func() func() {
+		data, err := io.ReadAll(s.reader)
+		s.close()
+		return func() {
+			callback(data, err)
+		}
+	})
+}
+
+func (s *ReadableStream) GetReader() (*ReadableStreamDefaultReader, error) {
+	if s.Locked {
+		return nil, errors.New("ReadableStream is locked")
+	}
+	s.Locked = true
+	return &ReadableStreamDefaultReader{stream: s}, nil
+}
+
+func (s *ReadableStream) Cancel() *goja.Promise {
+	promise, resolve, _ := s.worker.Runtime().NewPromise()
+	s.close()
+	resolve(goja.Undefined())
+	return promise
+}
+
+type ReadableStreamDefaultReader struct {
+	stream   *ReadableStream
+	released bool
+}
+
+// 读取下一个数据块，结果为 { done: boolean, value: Uint8Array }
+func (r *ReadableStreamDefaultReader) Read() *goja.Promise {
+	runtime := r.stream.worker.Runtime()
+	promise, resolve, reject := runtime.NewPromise()
+	if r.released {
+		reject(runtime.NewTypeError("reader has been released"))
+		return promise
+	}
+
+	r.stream.disturb()
+	r.stream.async(func() func() {
+		buf := make([]byte, 32*1024)
+		n, err := r.stream.reader.Read(buf)
+		if err == io.EOF && n == 0 {
+			r.stream.close()
+		}
+		return func() {
+			result := runtime.NewObject()
+			if n > 0 {
+				result.Set("done", false)
+				result.Set("value", NewUint8Array(runtime, buf[:n]))
+				resolve(result)
+				return
+			}
+			if err != nil && err != io.EOF {
+				reject(runtime.NewGoError(err))
+				return
+			}
+			result.Set("done", true)
+			result.Set("value", goja.Undefined())
+			resolve(result)
+		}
+	})
+
+	return promise
+}
+
+func (r *ReadableStreamDefaultReader) Cancel() *goja.Promise {
+	return r.stream.Cancel()
+}
+
+func (r *ReadableStreamDefaultReader) ReleaseLock() {
+	r.released = true
+	r.stream.Locked = false
+}
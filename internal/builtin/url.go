@@ -0,0 +1,535 @@
+package builtin
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+var urlSymbol = goja.NewSymbol("URL") // 用于从 js 对象中获取 URL
+
+func init() {
+	Builtins = append(Builtins, func(worker Worker) {
+		runtime := worker.Runtime()
+
+		runtime.Set("URLSearchParams", func(call goja.ConstructorCall) *goja.Object {
+			p := &URLSearchParams{}
+
+			init := call.Argument(0)
+			switch v := init.Export().(type) {
+			case nil:
+			case string:
+				p.parse(strings.TrimPrefix(v, "?"))
+			case *URLSearchParams:
+				p.list = append(p.list, v.list...)
+			case []interface{}: // [[name, value], ...]
+				o := init.ToObject(runtime)
+				for i := range v {
+					pair, ok := o.Get(strconv.Itoa(i)).(*goja.Object)
+					if !ok || pair.Get("length").ToInteger() != 2 {
+						panic(runtime.NewTypeError("Each query pair must be an iterable [name, value] tuple"))
+					}
+					p.list = append(p.list, [2]string{pair.Get("0").String(), pair.Get("1").String()})
+				}
+			default: // { name: value, ... }，按属性的定义顺序添加
+				o := init.ToObject(runtime)
+				for _, k := range o.Keys() {
+					p.list = append(p.list, [2]string{k, o.Get(k).String()})
+				}
+			}
+			p.update()
+
+			o := runtime.ToValue(p).(*goja.Object)
+			o.SetPrototype(call.This.Prototype())
+			return o
+		})
+
+		c := runtime.ToValue(func(call goja.ConstructorCall) *goja.Object {
+			u, err := NewURL(runtime, call.Argument(0).String(), call.Argument(1))
+			if err != nil {
+				panic(runtime.NewTypeError(err.Error()))
+			}
+			o := runtime.NewObject()
+			o.SetPrototype(call.This.Prototype())
+			o.DefineDataPropertySymbol(urlSymbol, runtime.ToValue(u), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_FALSE)
+			return o
+		}).(*goja.Object)
+		c.Set("canParse", func(input string, base goja.Value) bool {
+			_, err := parseURL(input, base)
+			return err == nil
+		})
+		c.Set("parse", func(input string, base goja.Value) goja.Value {
+			if _, err := parseURL(input, base); err != nil {
+				return goja.Null()
+			}
+			o, _ := runtime.New(c, runtime.ToValue(input), base)
+			return o
+		})
+
+		// URL 的属性定义在原型上，读写时通过 this 获取对应的 go 对象
+		proto := c.Get("prototype").ToObject(runtime)
+		accessor := func(name string, get func(u *URL) interface{}, set func(u *URL, value string) error) {
+			getter := runtime.ToValue(func(call goja.FunctionCall) goja.Value {
+				return runtime.ToValue(get(thisURL(runtime, call.This)))
+			})
+			var setter goja.Value
+			if set != nil {
+				setter = runtime.ToValue(func(call goja.FunctionCall) goja.Value {
+					if err := set(thisURL(runtime, call.This), call.Argument(0).String()); err != nil {
+						panic(runtime.NewTypeError(err.Error()))
+					}
+					return goja.Undefined()
+				})
+			}
+			proto.DefineAccessorProperty(name, getter, setter, goja.FLAG_TRUE, goja.FLAG_TRUE)
+		}
+		accessor("href", func(u *URL) interface{} { return u.Href() }, (*URL).SetHref)
+		accessor("origin", func(u *URL) interface{} { return u.Origin() }, nil)
+		accessor("protocol", func(u *URL) interface{} { return u.url.Scheme + ":" }, (*URL).SetProtocol)
+		accessor("username", func(u *URL) interface{} { return u.url.User.Username() }, (*URL).SetUsername)
+		accessor("password", func(u *URL) interface{} { p, _ := u.url.User.Password(); return p }, (*URL).SetPassword)
+		accessor("host", func(u *URL) interface{} { return u.url.Host }, (*URL).SetHost)
+		accessor("hostname", func(u *URL) interface{} { return u.Hostname() }, (*URL).SetHostname)
+		accessor("port", func(u *URL) interface{} { return u.url.Port() }, (*URL).SetPort)
+		accessor("pathname", func(u *URL) interface{} { return u.Pathname() }, (*URL).SetPathname)
+		accessor("search", func(u *URL) interface{} { return u.Search() }, (*URL).SetSearch)
+		accessor("hash", func(u *URL) interface{} { return u.Hash() }, (*URL).SetHash)
+		accessor("searchParams", func(u *URL) interface{} { return u.SearchParams() }, nil)
+		proto.Set("toString", func(call goja.FunctionCall) goja.Value {
+			return runtime.ToValue(thisURL(runtime, call.This).Href())
+		})
+		proto.Set("toJSON", func(call goja.FunctionCall) goja.Value {
+			return runtime.ToValue(thisURL(runtime, call.This).Href())
+		})
+		runtime.Set("URL", c)
+	})
+}
+
+func thisURL(runtime *goja.Runtime, this goja.Value) *URL {
+	u, ok := exportSymbol(this, urlSymbol).(*URL)
+	if !ok {
+		panic(runtime.NewTypeError("Illegal invocation"))
+	}
+	return u
+}
+
+//#region URL
+
+// 特殊协议及其默认端口，见 https://url.spec.whatwg.org/#special-scheme
+var urlSpecialSchemes = map[string]string{"ftp": "21", "file": "", "http": "80", "https": "443", "ws": "80", "wss": "443"}
+
+var urlSchemeRegexp = regexp.MustCompile(`^[a-z][a-z0-9+.\-]*$`)
+
+type URL struct {
+	runtime *goja.Runtime
+	url     *url.URL
+	params  *URLSearchParams
+	object  *goja.Object // searchParams 属性对应的 js 对象，保证每次获取的都是同一个对象
+}
+
+// 创建 URL，如果 input 为相对路径，则基于 base 解析
+func NewURL(runtime *goja.Runtime, input string, base goja.Value) (*URL, error) {
+	v, err := parseURL(input, base)
+	if err != nil {
+		return nil, err
+	}
+	u := &URL{runtime: runtime, url: v}
+	u.params = &URLSearchParams{onUpdate: func() {
+		u.url.RawQuery, u.url.ForceQuery = u.params.ToString(), false
+	}}
+	u.params.reset(v.RawQuery)
+	return u, nil
+}
+
+func parseURL(input string, base goja.Value) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(input))
+	if err != nil {
+		return nil, errors.New("Invalid URL: " + input)
+	}
+	if base != nil && !goja.IsUndefined(base) {
+		b, err := url.Parse(strings.TrimSpace(base.String()))
+		if err != nil || !b.IsAbs() {
+			return nil, errors.New("Invalid base URL: " + base.String())
+		}
+		u = b.ResolveReference(u)
+	}
+	if !u.IsAbs() {
+		return nil, errors.New("Invalid URL: " + input)
+	}
+	if err := normalizeURL(u); err != nil {
+		return nil, errors.New("Invalid URL: " + input)
+	}
+	return u, nil
+}
+
+// 规范化特殊协议的 URL：主机名转为小写，去除默认端口，路径为空时设为 "/"
+func normalizeURL(u *url.URL) error {
+	port, ok := urlSpecialSchemes[u.Scheme]
+	if !ok {
+		return nil
+	}
+	if u.Host == "" && u.Scheme != "file" {
+		return errors.New("host is empty")
+	}
+	u.Host = strings.ToLower(u.Host)
+	if port != "" && u.Port() == port {
+		u.Host = strings.TrimSuffix(u.Host, ":"+port)
+	}
+	if u.Opaque == "" {
+		*u = *u.ResolveReference(&url.URL{}) // 移除路径中的 "." 和 ".." 片段
+		if u.Path == "" {
+			u.Path = "/"
+		}
+	}
+	return nil
+}
+
+func (u *URL) Href() string {
+	return u.url.String()
+}
+
+func (u *URL) SetHref(value string) error {
+	v, err := parseURL(value, nil)
+	if err != nil {
+		return err
+	}
+	u.url = v
+	u.params.reset(v.RawQuery)
+	return nil
+}
+
+func (u *URL) Origin() string {
+	if port, ok := urlSpecialSchemes[u.url.Scheme]; ok && port != "" {
+		return u.url.Scheme + "://" + u.url.Host
+	}
+	return "null"
+}
+
+func (u *URL) SetProtocol(value string) error {
+	scheme, _, _ := strings.Cut(strings.ToLower(value), ":")
+	if urlSchemeRegexp.MatchString(scheme) {
+		u.url.Scheme = scheme
+		normalizeURL(u.url)
+	}
+	return nil
+}
+
+func (u *URL) SetUsername(value string) error {
+	if p, ok := u.url.User.Password(); ok {
+		u.url.User = url.UserPassword(value, p)
+	} else if value != "" {
+		u.url.User = url.User(value)
+	} else {
+		u.url.User = nil
+	}
+	return nil
+}
+
+func (u *URL) SetPassword(value string) error {
+	if value != "" {
+		u.url.User = url.UserPassword(u.url.User.Username(), value)
+	} else if name := u.url.User.Username(); name != "" {
+		u.url.User = url.User(name)
+	} else {
+		u.url.User = nil
+	}
+	return nil
+}
+
+func (u *URL) SetHost(value string) error {
+	if value != "" {
+		u.url.Host = value
+		normalizeURL(u.url)
+	}
+	return nil
+}
+
+// 获取主机名，与 url.Hostname() 不同的是，IPv6 地址保留方括号
+func (u *URL) Hostname() string {
+	if port := u.url.Port(); port != "" {
+		return strings.TrimSuffix(u.url.Host, ":"+port)
+	}
+	return u.url.Host
+}
+
+func (u *URL) SetHostname(value string) error {
+	if port := u.url.Port(); port != "" {
+		value += ":" + port
+	}
+	return u.SetHost(value)
+}
+
+func (u *URL) SetPort(value string) error {
+	if value == "" {
+		u.url.Host = u.Hostname()
+		return nil
+	}
+	i := strings.IndexFunc(value, func(r rune) bool { return r < '0' || r > '9' })
+	if i >= 0 {
+		value = value[:i]
+	}
+	if n, err := strconv.Atoi(value); err == nil && n <= 65535 {
+		u.url.Host = u.Hostname() + ":" + strconv.Itoa(n)
+		normalizeURL(u.url)
+	}
+	return nil
+}
+
+func (u *URL) Pathname() string {
+	if u.url.Opaque != "" {
+		return u.url.Opaque
+	}
+	return u.url.EscapedPath()
+}
+
+func (u *URL) SetPathname(value string) error {
+	if u.url.Opaque != "" {
+		return nil
+	}
+	if _, ok := urlSpecialSchemes[u.url.Scheme]; ok && !strings.HasPrefix(value, "/") {
+		value = "/" + value
+	}
+	if p, err := url.PathUnescape(value); err == nil {
+		u.url.Path, u.url.RawPath = p, value // RawPath 不是合法的编码时，将基于 Path 重新编码
+	} else {
+		u.url.Path, u.url.RawPath = value, ""
+	}
+	return nil
+}
+
+func (u *URL) Search() string {
+	if u.url.RawQuery == "" {
+		return ""
+	}
+	return "?" + u.url.RawQuery
+}
+
+func (u *URL) SetSearch(value string) error {
+	u.url.RawQuery, u.url.ForceQuery = escapeURL(strings.TrimPrefix(value, "?"), "\"#<>"), false
+	u.params.reset(u.url.RawQuery)
+	return nil
+}
+
+func (u *URL) Hash() string {
+	if u.url.Fragment == "" {
+		return ""
+	}
+	return "#" + u.url.EscapedFragment()
+}
+
+func (u *URL) SetHash(value string) error {
+	value = strings.TrimPrefix(value, "#")
+	if f, err := url.PathUnescape(value); err == nil {
+		u.url.Fragment, u.url.RawFragment = f, value
+	} else {
+		u.url.Fragment, u.url.RawFragment = value, ""
+	}
+	return nil
+}
+
+func (u *URL) SearchParams() *goja.Object {
+	if u.object == nil {
+		runtime := u.runtime
+		u.object = runtime.ToValue(u.params).(*goja.Object)
+		u.object.SetPrototype(runtime.Get("URLSearchParams").ToObject(runtime).Get("prototype").ToObject(runtime))
+	}
+	return u.object
+}
+
+// 对空格、控制字符、非 ASCII 字符及 extra 中的字符进行百分号编码
+func escapeURL(s string, extra string) string {
+	const hex = "0123456789ABCDEF"
+	b := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c <= ' ' || c >= 0x7f || strings.IndexByte(extra, c) >= 0 {
+			b.WriteByte('%')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&15])
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+//#endregion
+
+// 查询参数，按 application/x-www-form-urlencoded 格式编码和解析，保留参数的顺序
+type URLSearchParams struct {
+	list     [][2]string
+	onUpdate func() // 参数变更时的回调，用于同步 URL 的 search 属性
+	Size     int
+}
+
+func (p *URLSearchParams) parse(query string) {
+	for _, s := range strings.Split(query, "&") {
+		if s == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(s, "=")
+		p.list = append(p.list, [2]string{unescapeQuery(name), unescapeQuery(value)})
+	}
+}
+
+func (p *URLSearchParams) update() {
+	p.Size = len(p.list)
+	if p.onUpdate != nil {
+		p.onUpdate()
+	}
+}
+
+// 重新解析查询字符串，不触发回调
+func (p *URLSearchParams) reset(query string) {
+	p.list = nil
+	p.parse(query)
+	p.Size = len(p.list)
+}
+
+func (p *URLSearchParams) Append(name string, value string) {
+	p.list = append(p.list, [2]string{name, value})
+	p.update()
+}
+
+// 删除参数，如果指定了 value，则只删除名称和值都相同的参数
+func (p *URLSearchParams) Delete(name string, value goja.Value) {
+	list := p.list[:0]
+	for _, e := range p.list {
+		if e[0] == name && (value == nil || goja.IsUndefined(value) || e[1] == value.String()) {
+			continue
+		}
+		list = append(list, e)
+	}
+	p.list = list
+	p.update()
+}
+
+func (p *URLSearchParams) Get(name string) interface{} {
+	for _, e := range p.list {
+		if e[0] == name {
+			return e[1]
+		}
+	}
+	return nil
+}
+
+func (p *URLSearchParams) GetAll(name string) []string {
+	values := make([]string, 0)
+	for _, e := range p.list {
+		if e[0] == name {
+			values = append(values, e[1])
+		}
+	}
+	return values
+}
+
+func (p *URLSearchParams) Has(name string, value goja.Value) bool {
+	for _, e := range p.list {
+		if e[0] == name && (value == nil || goja.IsUndefined(value) || e[1] == value.String()) {
+			return true
+		}
+	}
+	return false
+}
+
+// 设置参数，替换第一个同名参数的值并删除其余的同名参数
+func (p *URLSearchParams) Set(name string, value string) {
+	list, found := p.list[:0], false
+	for _, e := range p.list {
+		if e[0] == name {
+			if found {
+				continue
+			}
+			e[1], found = value, true
+		}
+		list = append(list, e)
+	}
+	if !found {
+		list = append(list, [2]string{name, value})
+	}
+	p.list = list
+	p.update()
+}
+
+// 按名称排序，名称相同的参数保持原有顺序
+func (p *URLSearchParams) Sort() {
+	sort.SliceStable(p.list, func(i, j int) bool {
+		return p.list[i][0] < p.list[j][0]
+	})
+	p.update()
+}
+
+func (p *URLSearchParams) Keys() []string {
+	keys := make([]string, 0, len(p.list))
+	for _, e := range p.list {
+		keys = append(keys, e[0])
+	}
+	return keys
+}
+
+func (p *URLSearchParams) Values() []string {
+	values := make([]string, 0, len(p.list))
+	for _, e := range p.list {
+		values = append(values, e[1])
+	}
+	return values
+}
+
+func (p *URLSearchParams) Entries() [][]string {
+	entries := make([][]string, 0, len(p.list))
+	for _, e := range p.list {
+		entries = append(entries, []string{e[0], e[1]})
+	}
+	return entries
+}
+
+func (p *URLSearchParams) ForEach(callback func(value string, name string)) {
+	for _, e := range p.list {
+		callback(e[1], e[0])
+	}
+}
+
+func (p *URLSearchParams) ToString() string {
+	b := strings.Builder{}
+	for i, e := range p.list {
+		if i > 0 {
+			b.WriteByte('&')
+		}
+		b.WriteString(escapeQuery(e[0]))
+		b.WriteByte('=')
+		b.WriteString(escapeQuery(e[1]))
+	}
+	return b.String()
+}
+
+// 按 application/x-www-form-urlencoded 格式编码：保留字母、数字和 *-._，空格编码为 +，其余字符按 UTF-8 字节编码为 %XX
+func escapeQuery(s string) string {
+	const hex = "0123456789ABCDEF"
+	b := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '*', c == '-', c == '.', c == '_':
+			b.WriteByte(c)
+		case c == ' ':
+			b.WriteByte('+')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&15])
+		}
+	}
+	return b.String()
+}
+
+func unescapeQuery(s string) string {
+	if v, err := url.QueryUnescape(s); err == nil {
+		return v
+	}
+	return strings.ReplaceAll(s, "+", " ") // 包含无效的转义序列时，保留原始字符
+}
@@ -0,0 +1,195 @@
+package builtin
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+func init() {
+	Builtins = append(Builtins, func(worker Worker) {
+		runtime := worker.Runtime()
+
+		runtime.Set("btoa", func(data string) string {
+			b := make([]byte, 0, len(data))
+			for _, r := range data {
+				if r > 0xff { // 仅支持 Latin1 字符
+					panic(NewDOMException(runtime, "InvalidCharacterError", "Invalid character"))
+				}
+				b = append(b, byte(r))
+			}
+			return base64.StdEncoding.EncodeToString(b)
+		})
+
+		runtime.Set("atob", func(data string) string {
+			data = strings.Map(func(r rune) rune { // 忽略空白字符
+				if r == ' ' || r == '\t' || r == '\n' || r == '\f' || r == '\r' {
+					return -1
+				}
+				return r
+			}, data)
+			if len(data)%4 == 0 {
+				data = strings.TrimSuffix(strings.TrimSuffix(data, "="), "=")
+			}
+			b, err := base64.RawStdEncoding.DecodeString(data)
+			if err != nil {
+				panic(NewDOMException(runtime, "InvalidCharacterError", "The string to be decoded is not correctly encoded."))
+			}
+			r := make([]rune, len(b))
+			for i, c := range b {
+				r[i] = rune(c)
+			}
+			return string(r)
+		})
+
+		runtime.Set("queueMicrotask", func(callback goja.Value) {
+			if _, ok := goja.AssertFunction(callback); !ok {
+				panic(runtime.NewTypeError("The callback provided as parameter 1 is not a function."))
+			}
+			// 使用 goja 的 Promise 任务队列执行，与 Promise 的回调处于同一个微任务队列中
+			promise, resolve, _ := runtime.NewPromise()
+			resolve(goja.Undefined())
+			then, _ := goja.AssertFunction(runtime.ToValue(promise).ToObject(runtime).Get("then"))
+			then(runtime.ToValue(promise), callback)
+		})
+
+		runtime.Set("structuredClone", func(value goja.Value) goja.Value {
+			return (&cloner{runtime: runtime, memory: make(map[*goja.Object]*goja.Object)}).clone(value)
+		})
+
+		crypto := runtime.NewObject()
+		crypto.Set("getRandomValues", func(array goja.Value) goja.Value {
+			o, ok := array.(*goja.Object)
+			if ok {
+				switch o.Export().(type) {
+				case []int8, []uint8, []int16, []uint16, []int32, []uint32, []int64, []uint64:
+				default:
+					ok = false
+				}
+			}
+			if !ok {
+				panic(NewDOMException(runtime, "TypeMismatchError", "The provided ArrayBufferView is not an integer array type"))
+			}
+			size := int(o.Get("byteLength").ToInteger())
+			if size > 65536 {
+				panic(NewDOMException(runtime, "QuotaExceededError", "The ArrayBufferView's byte length exceeds the number of bytes of entropy available via this API (65536)"))
+			}
+			buf, _ := o.Get("buffer").Export().(goja.ArrayBuffer)
+			offset := int(o.Get("byteOffset").ToInteger())
+			if _, err := rand.Read(buf.Bytes()[offset : offset+size]); err != nil {
+				panic(runtime.NewGoError(err))
+			}
+			return array
+		})
+		crypto.Set("randomUUID", func() string { // 版本 4 的 UUID
+			b := make([]byte, 16)
+			rand.Read(b)
+			b[6], b[8] = b[6]&0x0f|0x40, b[8]&0x3f|0x80
+			return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+		})
+		runtime.Set("crypto", crypto)
+	})
+}
+
+// 结构化克隆，支持基本类型、普通对象、数组、Date、RegExp、Map、Set、Error、ArrayBuffer 及类型化数组，支持循环引用
+type cloner struct {
+	runtime *goja.Runtime
+	memory  map[*goja.Object]*goja.Object // 已克隆的对象，用于处理循环引用
+}
+
+func (c *cloner) clone(value goja.Value) goja.Value {
+	runtime := c.runtime
+
+	o, ok := value.(*goja.Object)
+	if !ok {
+		if _, ok := value.(*goja.Symbol); ok {
+			panic(NewDOMException(runtime, "DataCloneError", value.String()+" could not be cloned."))
+		}
+		return value
+	}
+	if v, ok := c.memory[o]; ok {
+		return v
+	}
+
+	// Map、Set 等内置对象的 ClassName 均为 Object，需要通过构造器判断类型
+	instanceOf := func(name string) bool {
+		c, ok := runtime.Get(name).(*goja.Object)
+		return ok && runtime.InstanceOf(o, c)
+	}
+
+	var v *goja.Object
+	switch {
+	case o.ClassName() == "Array":
+		if _, ok := o.Export().([]interface{}); !ok { // go 切片等宿主对象
+			break
+		}
+		v = runtime.NewArray()
+		c.memory[o] = v
+		for _, k := range o.Keys() {
+			v.Set(k, c.clone(o.Get(k)))
+		}
+		v.Set("length", o.Get("length"))
+		return v
+	case o.ClassName() == "Date":
+		v, _ = runtime.New(runtime.Get("Date"), runtime.ToValue(o.Export().(time.Time).UnixMilli()))
+	case o.ClassName() == "RegExp":
+		v, _ = runtime.New(runtime.Get("RegExp"), o.Get("source"), o.Get("flags"))
+	case o.ClassName() == "Number", o.ClassName() == "String", o.ClassName() == "Boolean":
+		v = runtime.ToValue(o.Export()).ToObject(runtime)
+	case o.ClassName() == "Error":
+		name := o.Get("name").String()
+		switch name {
+		case "EvalError", "RangeError", "ReferenceError", "SyntaxError", "TypeError", "URIError":
+			v, _ = runtime.New(runtime.Get(name), o.Get("message"))
+		default:
+			v, _ = runtime.New(runtime.Get("Error"), o.Get("message"))
+			v.Set("name", name)
+		}
+		if o.Get("cause") != nil {
+			v.Set("cause", c.clone(o.Get("cause")))
+		}
+	case o.ClassName() != "Object":
+	case instanceOf("Map"), instanceOf("Set"):
+		name, add := "Map", "set"
+		if instanceOf("Set") {
+			name, add = "Set", "add"
+		}
+		v, _ = runtime.New(runtime.Get(name))
+		c.memory[o] = v
+		each, _ := goja.AssertFunction(o.Get("forEach"))
+		fn, _ := goja.AssertFunction(v.Get(add))
+		each(o, runtime.ToValue(func(value goja.Value, key goja.Value) {
+			if name == "Set" {
+				fn(v, c.clone(value))
+			} else {
+				fn(v, c.clone(key), c.clone(value))
+			}
+		}))
+		return v
+	case instanceOf("ArrayBuffer"):
+		b, _ := o.Export().(goja.ArrayBuffer)
+		v = runtime.ToValue(runtime.NewArrayBuffer(append([]byte{}, b.Bytes()...))).(*goja.Object)
+	case instanceOf("WeakMap"), instanceOf("WeakSet"), instanceOf("Promise"):
+	default:
+		switch o.Export().(type) {
+		case map[string]interface{}:
+			v = runtime.NewObject()
+			c.memory[o] = v
+			for _, k := range o.Keys() {
+				v.Set(k, c.clone(o.Get(k)))
+			}
+			return v
+		case []int8, []uint8, []int16, []uint16, []int32, []uint32, []int64, []uint64, []float32, []float64: // 类型化数组
+			v, _ = runtime.New(o.Get("constructor"), o)
+		}
+	}
+	if v == nil {
+		panic(NewDOMException(runtime, "DataCloneError", o.String()+" could not be cloned."))
+	}
+	c.memory[o] = v
+	return v
+}
@@ -1,39 +1,352 @@
 package builtin
 
 import (
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
 	"github.com/dop251/goja"
 	"github.com/gorilla/websocket"
 )
 
+var webSocketSymbol = goja.NewSymbol("WebSocket") // 用于从 js 对象中获取 WebSocket
+
 func init() {
 	Builtins = append(Builtins, func(worker Worker) {
 		runtime := worker.Runtime()
 
-		runtime.Set("WebSocket", func(call goja.ConstructorCall) *goja.Object {
+		c := runtime.ToValue(func(call goja.ConstructorCall) *goja.Object {
 			url, ok := call.Argument(0).Export().(string)
 			if !ok {
 				panic(runtime.NewTypeError("invalid url: not a string"))
 			}
 
-			c, _, err := websocket.DefaultDialer.Dial(url, nil)
+			options := &WebSocketOptions{}
+			if v := call.Argument(2); !goja.IsUndefined(v) && !goja.IsNull(v) {
+				if err := runtime.ExportTo(v, options); err != nil {
+					panic(runtime.NewTypeError(err.Error()))
+				}
+			}
+			switch v := call.Argument(1).Export().(type) { // 子协议，可以是字符串或字符串数组
+			case string:
+				options.Protocols = []string{v}
+			case []interface{}:
+				for _, p := range v {
+					options.Protocols = append(options.Protocols, p.(string))
+				}
+			}
+
+			dialer := *websocket.DefaultDialer
+			dialer.Subprotocols = options.Protocols
+			dialer.EnableCompression = options.compression()
+			header := http.Header{}
+			for k, v := range options.Headers {
+				header.Set(k, v)
+			}
+			conn, resp, err := dialer.Dial(url, header)
 			if err != nil {
-				panic(err)
+				panic(runtime.NewGoError(err))
 			}
 
-			iv := runtime.ToValue(NewWebSocket(c)).(*goja.Object)
-			iv.SetPrototype(call.This.Prototype())
-			return iv
-		})
+			s := NewWebSocket(worker, conn, resp.Header.Get("Sec-WebSocket-Extensions"), options)
+			s.object.SetPrototype(call.This.Prototype())
+			s.object.Set("url", url)
+
+			// 连接在构造时已建立，这里在下一个宏任务中触发 open 事件，以便调用方先设置 onopen
+			t := worker.EventLoop().NewEventTaskTrigger()
+			t.AddTask(func() {
+				if t.Cancel() {
+					s.emit("onopen", map[string]interface{}{"type": "open"})
+				}
+			})
+
+			return s.object
+		}).(*goja.Object)
+		for i, name := range []string{"CONNECTING", "OPEN", "CLOSING", "CLOSED"} {
+			c.Set(name, i)
+		}
+		runtime.Set("WebSocket", c)
 	})
 }
 
 //#region websocket
 
+const (
+	webSocketConnecting = iota
+	webSocketOpen
+	webSocketClosing
+	webSocketClosed
+)
+
+// 写入控制帧（ping、close）的超时时间
+const webSocketWriteWait = 5 * time.Second
+
+type WebSocketOptions struct {
+	Protocols    []string          // 子协议，服务端将选择第一个客户端也支持的子协议
+	Compression  *bool             // 是否启用 permessage-deflate 压缩，默认启用
+	PingInterval int               // 发送 ping 的间隔，单位毫秒，0 表示不发送；启用后超过 2 倍间隔未收到任何消息将断开连接
+	ReadLimit    int64             // 单条消息的最大字节数，0 表示不限制
+	Headers      map[string]string // 握手时的请求头，仅用于客户端
+}
+
+func (o *WebSocketOptions) compression() bool {
+	return o == nil || o.Compression == nil || *o.Compression
+}
+
 type WebSocket struct {
+	worker     Worker
 	connection *websocket.Conn
+	object     *goja.Object
+	options    *WebSocketOptions
+	extensions string
+	state      atomic.Int32
+	detached   atomic.Bool       // 是否已脱离 vm 实例，脱离后连接不再随执行结束而关闭，如加入 hub 的连接
+	lock       sync.Mutex        // 写锁，同一时刻只允许一个协程写入数据帧
+	trigger    *EventTaskTrigger // 监听消息时持有的触发器，连接关闭前事件循环不会结束
+	done       chan struct{}     // 连接释放的信号
+	doneOnce   sync.Once
+	releases   []func()              // 连接释放时的回调，如从 hub 中移除
+	hooks      sync.Mutex            // releases 的锁，连接可能在其他 vm 实例的协程中被释放
+	handlers   map[string]goja.Value // onopen、onmessage、onclose、onerror 事件的处理函数
+}
+
+// 创建 WebSocket，设置 onmessage 等事件处理函数后将在协程中读取消息，并通过事件循环回调
+func NewWebSocket(worker Worker, c *websocket.Conn, extensions string, options *WebSocketOptions) *WebSocket {
+	runtime := worker.Runtime()
+
+	if options == nil {
+		options = &WebSocketOptions{}
+	}
+	s := &WebSocket{
+		worker:     worker,
+		connection: c,
+		options:    options,
+		extensions: extensions,
+		done:       make(chan struct{}),
+		handlers:   make(map[string]goja.Value),
+	}
+	s.state.Store(webSocketOpen)
+	worker.AddDefer(func() { // 执行结束或被中断时关闭连接
+		if !s.detached.Load() {
+			s.release()
+		}
+	})
+
+	if options.ReadLimit > 0 {
+		c.SetReadLimit(options.ReadLimit)
+	}
+	if options.PingInterval > 0 {
+		s.keepalive(time.Duration(options.PingInterval) * time.Millisecond)
+	}
+
+	o := runtime.NewObject()
+	o.DefineDataPropertySymbol(webSocketSymbol, runtime.ToValue(s), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_FALSE)
+	o.DefineAccessorProperty("readyState", runtime.ToValue(func() int32 {
+		return s.state.Load()
+	}), nil, goja.FLAG_FALSE, goja.FLAG_TRUE)
+	o.Set("protocol", c.Subprotocol())
+	o.Set("extensions", extensions)
+	for _, name := range []string{"onopen", "onmessage", "onclose", "onerror"} {
+		name := name
+		o.DefineAccessorProperty(name, runtime.ToValue(func() goja.Value {
+			if h, ok := s.handlers[name]; ok {
+				return h
+			}
+			return goja.Null()
+		}), runtime.ToValue(func(h goja.Value) {
+			s.handlers[name] = h
+			if name != "onopen" {
+				s.listen()
+			}
+		}), goja.FLAG_FALSE, goja.FLAG_TRUE)
+	}
+	o.Set("read", s.Read)
+	o.Set("send", s.Send)
+	o.Set("sendText", s.SendText)
+	o.Set("sendBinary", s.SendBinary)
+	o.Set("ping", s.Ping)
+	o.Set("close", s.Close)
+	s.object = o
+
+	return s
+}
+
+// 从 js 对象中获取 WebSocket，如果不是 WebSocket 则返回 nil
+func ExportWebSocket(value goja.Value) *WebSocket {
+	s, _ := exportSymbol(value, webSocketSymbol).(*WebSocket)
+	return s
+}
+
+func (s *WebSocket) Object() *goja.Object {
+	return s.object
+}
+
+// 开始在协程中读取消息
+func (s *WebSocket) listen() {
+	if s.trigger != nil || s.detached.Load() || s.state.Load() == webSocketClosed { // 已脱离的连接由调用方读取消息
+		return
+	}
+	s.trigger = s.worker.EventLoop().NewEventTaskTrigger()
+	go func() {
+		for {
+			messageType, data, err := s.ReadMessage()
+			if err != nil {
+				s.trigger.TryAddTask(func() {
+					s.closed(err)
+				}, s.done)
+				return
+			}
+			if !s.trigger.TryAddTask(func() {
+				s.message(messageType, data)
+			}, s.done) {
+				return
+			}
+		}
+	}()
+}
+
+// 定时发送 ping，并在收到 pong 或消息时延长读取的超时时间
+func (s *WebSocket) keepalive(interval time.Duration) {
+	extend := func() {
+		s.connection.SetReadDeadline(time.Now().Add(2 * interval))
+	}
+	extend()
+	s.connection.SetPongHandler(func(string) error {
+		extend()
+		return nil
+	})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+				if err := s.connection.WriteControl(websocket.PingMessage, nil, time.Now().Add(webSocketWriteWait)); err != nil {
+					return
+				}
+			}
+		}
+	}()
+}
+
+func (s *WebSocket) message(messageType int, data []byte) {
+	event := map[string]interface{}{"type": "message", "binary": messageType == websocket.BinaryMessage}
+	if messageType == websocket.TextMessage {
+		event["data"] = string(data)
+	} else {
+		event["data"] = Buffer(data)
+	}
+	s.emit("onmessage", event)
+}
+
+// 连接关闭后触发 close 事件，非正常关闭时先触发 error 事件
+func (s *WebSocket) closed(err error) {
+	if !s.trigger.Cancel() {
+		return
+	}
+	s.state.Store(webSocketClosed)
+	s.release()
+
+	code, reason, clean := CloseStatus(err)
+	if !clean {
+		s.emit("onerror", map[string]interface{}{"type": "error", "message": err.Error()})
+	}
+	s.emit("onclose", map[string]interface{}{"type": "close", "code": code, "reason": reason, "wasClean": clean})
+}
+
+// 获取读取消息失败时的关闭状态，非正常关闭时 code 为 1006
+func CloseStatus(err error) (code int, reason string, clean bool) {
+	if e, ok := err.(*websocket.CloseError); ok && e.Code != websocket.CloseAbnormalClosure {
+		return e.Code, e.Text, true
+	}
+	return websocket.CloseAbnormalClosure, "", false
 }
 
+func (s *WebSocket) emit(name string, event map[string]interface{}) {
+	if fn, ok := goja.AssertFunction(s.handlers[name]); ok {
+		fn(s.object, s.worker.Runtime().ToValue(event))
+	}
+}
+
+// 释放连接，可重复调用
+func (s *WebSocket) Release() {
+	s.release()
+}
+
+func (s *WebSocket) release() {
+	s.doneOnce.Do(func() {
+		close(s.done)
+		if s.state.Swap(webSocketClosed) == webSocketOpen { // 如 vm 实例被中断，通知对方服务端即将离开
+			s.connection.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""), time.Now().Add(time.Second))
+		}
+		s.connection.Close()
+
+		s.hooks.Lock()
+		releases := s.releases
+		s.releases = nil
+		s.hooks.Unlock()
+		for _, fn := range releases {
+			fn()
+		}
+	})
+}
+
+// 注册连接释放时的回调，如果连接已释放，则立即调用
+func (s *WebSocket) OnRelease(fn func()) {
+	s.hooks.Lock()
+	select {
+	case <-s.done:
+		s.hooks.Unlock()
+		fn()
+		return
+	default:
+	}
+	s.releases = append(s.releases, fn)
+	s.hooks.Unlock()
+}
+
+// 使连接脱离 vm 实例，由调用方在协程中读取消息并负责释放，vm 实例在脚本返回后即可被归还，可重复调用
+func (s *WebSocket) Detach() error {
+	if s.detached.Load() {
+		return nil
+	}
+	if s.trigger != nil {
+		return errors.New("websocket is listening by event handlers")
+	}
+	if s.state.Load() != webSocketOpen {
+		return errors.New("websocket is not open")
+	}
+	s.detached.Store(true)
+	return nil
+}
+
+// 读取一条消息，启用 ping 时收到消息后延长读取的超时时间，可在协程中调用
+func (s *WebSocket) ReadMessage() (int, []byte, error) {
+	messageType, data, err := s.connection.ReadMessage()
+	if err == nil && s.options.PingInterval > 0 {
+		s.connection.SetReadDeadline(time.Now().Add(2 * time.Duration(s.options.PingInterval) * time.Millisecond))
+	}
+	return messageType, data, err
+}
+
+// 强制断开连接，可在其他协程中调用，连接的释放仍由读取消息的协程在事件循环中完成
+func (s *WebSocket) Terminate(code int, reason string) {
+	if s.state.Load() != webSocketOpen {
+		return
+	}
+	s.connection.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(webSocketWriteWait))
+	s.connection.Close()
+}
+
+// 读取一条消息，该方法会阻塞直到收到消息，不能与 onmessage 同时使用
 func (s *WebSocket) Read() (interface{}, error) {
+	if s.trigger != nil {
+		return nil, errors.New("websocket is listening by event handlers")
+	}
 	messageType, data, err := s.connection.ReadMessage()
 	if err != nil {
 		return nil, err
@@ -44,18 +357,73 @@ func (s *WebSocket) Read() (interface{}, error) {
 	}, nil
 }
 
-func (s *WebSocket) Send(data []byte) error {
-	return s.connection.WriteMessage(1, data) // message type：0 表示消息是文本格式，1 表示消息是二进制格式。这里 data 是 []byte，因此固定使用二进制格式类型
+// 发送消息，字符串以文本帧发送，其余以二进制帧发送
+func (s *WebSocket) Send(data goja.Value) error {
+	if b, ok := ExportBytes(data); ok {
+		return s.Write(websocket.BinaryMessage, b)
+	}
+	if _, ok := data.Export().([]interface{}); ok { // Array<number>
+		var b []byte
+		if err := s.worker.Runtime().ExportTo(data, &b); err != nil {
+			return err
+		}
+		return s.Write(websocket.BinaryMessage, b)
+	}
+	return s.Write(websocket.TextMessage, []byte(data.String()))
 }
 
-func (s *WebSocket) Close() {
-	s.connection.Close()
+func (s *WebSocket) SendText(text string) error {
+	return s.Write(websocket.TextMessage, []byte(text))
 }
 
-func NewWebSocket(c *websocket.Conn) *WebSocket {
-	return &WebSocket{
-		connection: c,
+func (s *WebSocket) SendBinary(data []byte) error {
+	return s.Write(websocket.BinaryMessage, data)
+}
+
+// 写入数据帧，可在多个协程中并发调用
+func (s *WebSocket) Write(messageType int, data []byte) error {
+	if s.state.Load() != webSocketOpen {
+		return errors.New("websocket is not open")
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.connection.WriteMessage(messageType, data) // message type：1 表示文本帧（websocket.TextMessage），2 表示二进制帧（websocket.BinaryMessage）
+}
+
+func (s *WebSocket) Ping(data []byte) error {
+	if s.state.Load() != webSocketOpen {
+		return errors.New("websocket is not open")
+	}
+	return s.connection.WriteControl(websocket.PingMessage, data, time.Now().Add(webSocketWriteWait))
+}
+
+// 关闭连接，code 默认为 1000，仅允许 1000 或 3000 ~ 4999；监听中的连接将在对方响应关闭帧后触发 close 事件
+func (s *WebSocket) Close(code goja.Value, reason goja.Value) error {
+	c := websocket.CloseNormalClosure
+	if code != nil && !goja.IsUndefined(code) {
+		c = int(code.ToInteger())
+		if c != websocket.CloseNormalClosure && (c < 3000 || c > 4999) {
+			return errors.New("the close code must be either 1000, or between 3000 and 4999")
+		}
+	}
+	r := ""
+	if reason != nil && !goja.IsUndefined(reason) {
+		r = reason.String()
+		if len(r) > 123 {
+			return errors.New("the close reason must not be greater than 123 bytes")
+		}
+	}
+
+	if !s.state.CompareAndSwap(webSocketOpen, webSocketClosing) {
+		return nil
+	}
+	err := s.connection.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(c, r), time.Now().Add(webSocketWriteWait))
+	if s.trigger == nil || err != nil {
+		s.release()
+		return nil
 	}
+	s.connection.SetReadDeadline(time.Now().Add(webSocketWriteWait)) // 对方未在超时时间内响应关闭帧时，读取将失败并关闭连接
+	return nil
 }
 
 //#endregion
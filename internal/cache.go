@@ -1,9 +1,13 @@
 package internal
 
 import (
-	"regexp"
+	"sort"
+	"sync"
+	"sync/atomic"
 
 	"cube/internal/model"
+	"cube/internal/util"
+
 	"github.com/dop251/goja"
 	"github.com/robfig/cron/v3"
 )
@@ -18,10 +22,14 @@ func InitCache() {
 		Modules:     make(map[string]*goja.Program),
 	}
 	Cache.InitRoutes()
+	Cache.InitFilters()
 }
 
 type CacheClient struct {
-	Routes      map[string]*regexp.Regexp
+	routes      map[string][2]string // 路由名称与请求方法、路径的映射
+	routesLock  sync.Mutex
+	router      atomic.Pointer[util.Router]
+	filters     atomic.Pointer[[]*filterRoute]
 	Controllers map[string]*model.Source
 	Crontabs    map[string]cron.EntryID
 	Daemons     map[string]*Worker
@@ -32,7 +40,7 @@ func (s *CacheClient) GetController(name string) *model.Source {
 	source := s.Controllers[name]
 	if source == nil {
 		source = &model.Source{}
-		if err := Db.QueryRow("select name, method from source where name = ? and type = 'controller' and active = true", name).Scan(&source.Name, &source.Method); err != nil {
+		if err := Db.QueryRow("select name, method, timeout, body_limit, concurrency from source where name = ? and type = 'controller' and active = true", name).Scan(&source.Name, &source.Method, &source.Timeout, &source.BodyLimit, &source.Concurrency); err != nil {
 			return nil
 		}
 		s.Controllers[name] = source
@@ -41,42 +49,98 @@ func (s *CacheClient) GetController(name string) *model.Source {
 }
 
 func (s *CacheClient) InitRoutes() {
-	s.Routes = make(map[string]*regexp.Regexp)
-	rows, err := Db.Query("select name, url from source where type = 'controller' order by rowid desc")
+	s.routesLock.Lock()
+	defer s.routesLock.Unlock()
+
+	s.routes = make(map[string][2]string)
+	rows, err := Db.Query("select name, method, url from source where type = 'controller' and active = true")
 	if err != nil {
 		panic(err)
 	}
 	defer rows.Close()
 	for rows.Next() {
-		var name, path string
-		rows.Scan(&name, &path)
-		s.SetRoute(name, path)
+		var name, method, path string
+		rows.Scan(&name, &method, &path)
+		s.routes[name] = [2]string{method, path}
 	}
+
+	s.buildRouter()
 }
 
-func (s *CacheClient) SetRoute(name string, path string) {
-	s.Routes[name] = regexp.MustCompile("^" + regexp.MustCompile("{(.*?)}").ReplaceAllString(path, "(?P<$1>.*?)") + "$")
+func (s *CacheClient) SetRoute(name string, method string, path string) {
+	s.routesLock.Lock()
+	defer s.routesLock.Unlock()
+
+	s.routes[name] = [2]string{method, path}
+	s.buildRouter()
 }
 
-func (s *CacheClient) GetRoute(path string) (string, map[string]string) {
-	for k, v := range s.Routes {
-		values := v.FindAllStringSubmatch(path, -1)
-		if len(values) == 0 {
-			continue
-		}
+func (s *CacheClient) DeleteRoute(name string) {
+	s.routesLock.Lock()
+	defer s.routesLock.Unlock()
+
+	delete(s.routes, name)
+	s.buildRouter()
+}
 
-		groups := v.SubexpNames()
+// 重建路由，构建完成后整体替换，匹配过程中无需加锁
+func (s *CacheClient) buildRouter() {
+	names := make([]string, 0, len(s.routes))
+	for name := range s.routes {
+		names = append(names, name)
+	}
+	sort.Strings(names) // 按名称排序，保证存在冲突的路由时结果是确定的
 
-		m := make(map[string]string)
-		for i, name := range groups {
-			if i == 0 {
-				continue
-			}
-			m[name] = values[0][i]
+	router := util.NewRouter()
+	for _, name := range names {
+		if err := router.Add(name, s.routes[name][0], s.routes[name][1]); err != nil {
+			util.MyLogger.Write(&util.LogEntry{Level: "warn", Worker: -1, Message: "failed to add route: " + err.Error()})
 		}
+	}
+	s.router.Store(router)
+}
+
+// 查询路由，返回 controller 名称、路径参数，以及路径匹配但请求方法不匹配时所允许的请求方法
+func (s *CacheClient) GetRoute(method string, path string) (string, map[string]string, []string) {
+	return s.router.Load().Match(method, path)
+}
+
+type filterRoute struct {
+	name   string
+	router *util.Router
+}
+
+// 重建过滤器，按 priority 升序排列
+func (s *CacheClient) InitFilters() {
+	rows, err := Db.Query("select name, method, url from source where type = 'filter' and active = true order by priority, name")
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+
+	filters := make([]*filterRoute, 0)
+	for rows.Next() {
+		var name, method, path string
+		rows.Scan(&name, &method, &path)
 
-		return k, m
+		router := util.NewRouter()
+		if err := router.Add(name, method, path); err != nil {
+			util.MyLogger.Write(&util.LogEntry{Level: "warn", Worker: -1, Message: "failed to add filter: " + err.Error()})
+			continue
+		}
+		filters = append(filters, &filterRoute{name, router})
 	}
 
-	return "", nil
+	s.filters.Store(&filters)
+}
+
+// 查询与请求方法、路径匹配的过滤器，按执行顺序返回名称
+func (s *CacheClient) GetFilters(method string, path string) []string {
+	names := make([]string, 0)
+	for _, f := range *s.filters.Load() {
+		if name, _, _ := f.router.Match(method, path); name != "" {
+			names = append(names, f.name)
+		}
+	}
+	return names
 }
@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"fmt"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+type CompileError struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`   // 行号，从 1 开始
+	Column  int    `json:"column"` // 列号，从 1 开始
+	Message string `json:"message"`
+}
+
+func (e *CompileError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Message)
+}
+
+// 判断 source 是否需要在服务端编译
+func IsCompilable(stype string, lang string) bool {
+	if lang != "typescript" {
+		return false
+	}
+	switch stype {
+	case "module", "controller", "daemon", "crontab", "filter":
+		return true
+	}
+	return false
+}
+
+// 获取 source 编译后的文件名，如 controller/foo.ts、node_modules/bar.ts
+func GetSourceFileName(name string, stype string) string {
+	if stype == "module" {
+		return name + ".ts"
+	}
+	return stype + "/" + name + ".ts"
+}
+
+// 将 typescript 源码编译为 commonjs 格式的 javascript 代码，并返回单独保存的源映射，用于将异常的位置还原为源码的位置
+func Compile(name string, stype string, content string) (string, string, error) {
+	result := api.Transform(content, api.TransformOptions{
+		Loader:         api.LoaderTS,
+		Format:         api.FormatCommonJS, // 与 require 方法的实现保持一致，即 (function(exports, require, module) { ... })
+		Target:         api.ES2017,         // goja 已支持 ES2017 及以下版本的大部分语法，更高版本的语法（如私有属性）将被降级
+		Charset:        api.CharsetUTF8,    // 保留源码中的非 ASCII 字符，不转义为 \uXXXX
+		Sourcefile:     GetSourceFileName(name, stype),
+		Sourcemap:      api.SourceMapExternal,
+		SourcesContent: api.SourcesContentExclude, // 源码已单独保存
+	})
+	if len(result.Errors) > 0 {
+		m, e := result.Errors[0], &CompileError{File: GetSourceFileName(name, stype), Message: result.Errors[0].Text}
+		if m.Location != nil {
+			e.Line, e.Column = m.Location.Line, m.Location.Column+1 // esbuild 返回的列号从 0 开始
+		}
+		return "", "", e
+	}
+	return string(result.Code), string(result.Map), nil
+}
@@ -1,9 +1,21 @@
 package config
 
-import "flag"
+import (
+	"flag"
+	"testing"
+)
 
 var (
 	Count            int
+	MinCount         int
+	QueueSize        int
+	WaitTimeout      int
+	IdleTimeout      int
+	Budget           int
+	MaxExecutions    int
+	MaxAllocs        int
+	JobConcurrency   int
+	Timeout          int
 	Port             string
 	Secure           bool
 	Http3            bool
@@ -11,11 +23,27 @@ var (
 	ServerCert       string
 	ClientCertVerify bool
 	IdeAuthorization string
+	LogLevel         string
+	LogSize          int
+	LogRotate        string
+	LogKeep          int
+	TraceEndpoint    string
+	TraceFile        string
+	TraceService     string
 )
 
 func init() {
 	// 获取启动参数
-	flag.IntVar(&Count, "n", 1, "Count of virtual machines.") // 定义命令行参数 c，表示虚拟机的个数，返回 Int 类型指针，默认值为 1，其值在 Parse 后会被修改为命令参数指定的值
+	flag.IntVar(&Count, "n", 1, "Maximum count of virtual machines.") // 定义命令行参数 n，表示虚拟机的最大个数，返回 Int 类型指针，默认值为 1，其值在 Parse 后会被修改为命令参数指定的值
+	flag.IntVar(&MinCount, "min", 1, "Minimum count of idle virtual machines to keep.")
+	flag.IntVar(&QueueSize, "queue", 100, "Maximum count of requests waiting for a virtual machine.")
+	flag.IntVar(&WaitTimeout, "wait", 3000, "Timeout in milliseconds of waiting for a virtual machine.")
+	flag.IntVar(&IdleTimeout, "idle", 60000, "Timeout in milliseconds of idle virtual machines before they are released.")
+	flag.IntVar(&Budget, "budget", 0, "Budget in milliseconds of execution time for each execution, including blocking native calls, 0 means unlimited.")
+	flag.IntVar(&MaxExecutions, "recycle", 0, "Recycle a virtual machine after the count of executions, 0 means never.")
+	flag.IntVar(&MaxAllocs, "allocs", 0, "Recycle a virtual machine after the cumulative heap allocations in megabytes of its executions, 0 means never.")
+	flag.IntVar(&JobConcurrency, "job-concurrency", 0, "Maximum count of jobs running at the same time, 0 means half of the virtual machines.")
+	flag.IntVar(&Timeout, "t", 60000, "Default timeout in milliseconds of service executions.")
 	flag.StringVar(&Port, "p", "8090", "Port to listen.")
 	flag.BoolVar(&Secure, "s", false, "Enable https.")
 	flag.BoolVar(&Http3, "3", false, "Enable http3.")
@@ -23,7 +51,26 @@ func init() {
 	flag.StringVar(&ServerCert, "c", "server.crt", "SSL cert file.")
 	flag.BoolVar(&ClientCertVerify, "v", false, "Enable client cert verification.")
 	flag.StringVar(&IdeAuthorization, "a", "", "<username:password> for ide authorization verification.")
+	flag.StringVar(&LogLevel, "log-level", "debug", "Minimum level of logs: debug, info, warn or error.")
+	flag.IntVar(&LogSize, "log-size", 100, "Rotate the log file when its size exceeds the megabytes, 0 means never.")
+	flag.StringVar(&LogRotate, "log-rotate", "daily", "Rotate the log file periodically: daily, hourly or never.")
+	flag.IntVar(&LogKeep, "log-keep", 7, "Count of rotated log files to keep, which are compressed with gzip, 0 means all.")
+	flag.StringVar(&TraceEndpoint, "trace-endpoint", "", "OTLP/HTTP endpoint to export spans, such as http://127.0.0.1:4318/v1/traces.")
+	flag.StringVar(&TraceFile, "trace-file", "", "File to export spans as JSON lines, such as ./cube.trace, rotated as the log file.")
+	flag.StringVar(&TraceService, "trace-service", "cube", "Service name of the exported spans.")
+
+	// 在定义命令行参数之后，调用 Parse 方法对所有命令行参数进行解析，测试时的参数由 testing 解析
+	if !testing.Testing() {
+		flag.Parse()
+	}
 
-	// 在定义命令行参数之后，调用 Parse 方法对所有命令行参数进行解析
-	flag.Parse()
+	if Count < 1 {
+		Count = 1
+	}
+	if MinCount > Count {
+		MinCount = Count
+	}
+	if JobConcurrency < 1 || JobConcurrency > Count { // 默认保留一半的实例用于处理请求
+		JobConcurrency = max(Count/2, 1)
+	}
 }
@@ -5,10 +5,13 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"cube/internal/builtin"
 
+	"github.com/dop251/goja"
 	"github.com/gorilla/websocket"
 )
 
@@ -36,12 +39,15 @@ func (s *ServiceContextReader) ReadByte() (byte, error) {
 }
 
 type ServiceContext struct {
+	worker         *Worker
 	request        *http.Request
 	responseWriter http.ResponseWriter
 	timer          *time.Timer
 	returnless     bool
+	streaming      atomic.Bool // 是否已升级为事件流，事件流由其自身监听客户端的断开
 	body           interface{} // 用于缓存请求消息体，防止重复读取和关闭 body 流
 	vars           *map[string]string
+	attributes     map[string]interface{} // 请求属性，用于在过滤器和 controller 之间传递数据
 }
 
 func (s *ServiceContext) GetHeader() map[string]string {
@@ -54,6 +60,25 @@ func (s *ServiceContext) GetHeader() map[string]string {
 	return headers
 }
 
+func (s *ServiceContext) SetHeader(name string, value string) { // 修改请求消息头，用于过滤器向 controller 传递请求消息头
+	s.request.Header.Set(name, value)
+}
+
+func (s *ServiceContext) SetResponseHeader(name string, value string) { // 设置响应消息头，如跨域相关的消息头
+	s.responseWriter.Header().Set(name, value)
+}
+
+func (s *ServiceContext) GetAttribute(name string) interface{} {
+	return s.attributes[name]
+}
+
+func (s *ServiceContext) SetAttribute(name string, value interface{}) {
+	if s.attributes == nil {
+		s.attributes = make(map[string]interface{})
+	}
+	s.attributes[name] = value
+}
+
 func (s *ServiceContext) GetURL() interface{} {
 	u := s.request.URL
 
@@ -126,15 +151,43 @@ func (s *ServiceContext) GetCookie(name string) (*http.Cookie, error) {
 	return cookie, err
 }
 
-func (s *ServiceContext) UpgradeToWebSocket() (*builtin.WebSocket, error) {
-	s.returnless = true // upgrader.Upgrade 内部已经调用过 WriteHeader 方法了，后续不应再次调用，否则将会出现 http: superfluous response.WriteHeader call from ... 的异常
-	s.timer.Stop()      // 关闭定时器，WebSocket 不需要设置超时时间
-	upgrader := websocket.Upgrader{}
-	if conn, err := upgrader.Upgrade(s.responseWriter, s.request, nil); err != nil {
+func (s *ServiceContext) UpgradeToWebSocket(options *builtin.WebSocketOptions) (*goja.Object, error) {
+	s.returnless = true  // upgrader.Upgrade 内部已经调用过 WriteHeader 方法了，后续不应再次调用，否则将会出现 http: superfluous response.WriteHeader call from ... 的异常
+	s.timer.Stop()       // 关闭定时器，WebSocket 不需要设置超时时间
+	s.worker.StopWatch() // 连接保持期间不受执行预算的限制
+	if options == nil {
+		options = &builtin.WebSocketOptions{}
+	}
+	compression := options.Compression == nil || *options.Compression // 默认启用 permessage-deflate 压缩
+	upgrader := websocket.Upgrader{
+		Subprotocols:      options.Protocols, // 按服务端声明的顺序选择第一个客户端也支持的子协议
+		EnableCompression: compression,
+	}
+	conn, err := upgrader.Upgrade(s.responseWriter, s.request, nil)
+	if err != nil {
+		return nil, err
+	}
+	extensions := ""
+	if compression && strings.Contains(s.request.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate") {
+		extensions = "permessage-deflate; server_no_context_takeover; client_no_context_takeover" // 与 upgrader 协商的扩展一致
+	}
+	return builtin.NewWebSocket(s.worker, conn, extensions, options).Object(), nil
+}
+
+func (s *ServiceContext) UpgradeToEventStream(options *EventStreamOptions) (*EventStream, error) {
+	if s.returnless {
+		return nil, errors.New("the response has already been committed")
+	}
+	s.streaming.Store(true)
+	stream, err := NewEventStream(s.worker, s.request, s.responseWriter, options)
+	if err != nil {
+		s.streaming.Store(false)
 		return nil, err
-	} else {
-		return builtin.NewWebSocket(conn), nil
 	}
+	s.returnless = true  // 响应头已发送
+	s.timer.Stop()       // 关闭定时器，事件流不需要设置超时时间
+	s.worker.StopWatch() // 连接保持期间不受执行预算的限制
+	return stream, nil
 }
 
 func (s *ServiceContext) GetReader() *ServiceContextReader {
@@ -181,8 +234,9 @@ func (s *ServiceContext) ResetTimeout(timeout int) {
 	}
 }
 
-func CreateServiceContext(r *http.Request, w http.ResponseWriter, t *time.Timer, v *map[string]string) *ServiceContext {
+func CreateServiceContext(worker *Worker, r *http.Request, w http.ResponseWriter, t *time.Timer, v *map[string]string) *ServiceContext {
 	return &ServiceContext{
+		worker:         worker,
 		request:        r,
 		responseWriter: w,
 		timer:          t,
@@ -194,4 +248,8 @@ func Returnless(ctx *ServiceContext) bool {
 	return ctx.returnless
 }
 
+func Streaming(ctx *ServiceContext) bool {
+	return ctx.streaming.Load()
+}
+
 //#endregion
@@ -1,14 +1,36 @@
 package internal
 
 import (
+	"errors"
+	"sync"
+	"time"
+	_ "time/tzdata" // 内置时区数据库，使得 CRON_TZ 在缺少时区数据的系统上也可用
+
+	"cube/internal/config"
+	"cube/internal/util"
+
 	"github.com/robfig/cron/v3"
 )
 
 var Crontab *cron.Cron // 定时任务
 
+// 支持可选的秒字段以及 CRON_TZ=Asia/Shanghai 形式的时区前缀，如 "CRON_TZ=Asia/Shanghai 0 30 8 * * *"
+var cronParser = cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+type crontabJob struct {
+	name    string
+	overlap string // 上一次执行未结束时的策略：allow、skip、queue，空值等同于 allow
+	lock    sync.Mutex
+	running int
+}
+
+var crontabJobs sync.Map // crontab 名称与 *crontabJob 的映射
+
+var errSkipped = errors.New("skipped")
+
 func RunCrontabs(name string) {
 	if Crontab == nil { // 首次执行时，先初始化 Crontab
-		Crontab = cron.New()
+		Crontab = cron.New(cron.WithParser(cronParser))
 		Crontab.Start()
 	}
 
@@ -16,35 +38,131 @@ func RunCrontabs(name string) {
 		name = "%"
 	}
 
-	rows, err := Db.Query("select name, cron from source where name like ? and type = 'crontab' and active = true", name)
+	rows, err := Db.Query("select name, cron, overlap from source where name like ? and type = 'crontab' and active = true", name)
 	if err != nil {
 		panic(err)
 	}
 	defer rows.Close()
 	for rows.Next() {
-		var n, c string
-		rows.Scan(&n, &c)
+		var n, c, o string
+		rows.Scan(&n, &c, &o)
 
 		if _, ok := Cache.Crontabs[n]; ok { // 防止重复添加任务
 			continue
 		}
 
+		job := &crontabJob{name: n, overlap: o}
 		id, err := Crontab.AddFunc(c, func() {
-			worker := <-WorkerPool.Channels
-			defer func() {
-				WorkerPool.Channels <- worker
-			}()
-
-			worker.Run(worker.Runtime().ToValue("./crontab/" + n))
+			job.run("schedule")
 		})
 		if err != nil {
 			panic(err)
 		} else {
 			Cache.Crontabs[n] = id
+			crontabJobs.Store(n, job)
 		}
 	}
 }
 
+func StopCrontab(name string) {
+	if id, ok := Cache.Crontabs[name]; ok {
+		Crontab.Remove(id)           // 停止 crontab
+		delete(Cache.Crontabs, name) // 删除缓存
+		crontabJobs.Delete(name)
+	}
+}
+
+// 手动触发执行一次 crontab，返回执行记录的 id，执行过程是异步的
+func TriggerCrontab(name string) (int64, error) {
+	v, ok := crontabJobs.Load(name)
+	if !ok {
+		return 0, errors.New("crontab is not active")
+	}
+	job := v.(*crontabJob)
+
+	id, err := job.start("manual")
+	if err != nil {
+		return 0, err
+	}
+	go job.execute(id, time.Now())
+	return id, nil
+}
+
 func ParseCron(c string) (cron.Schedule, error) {
-	return cron.ParseStandard(c)
+	return cronParser.Parse(c)
+}
+
+func (j *crontabJob) run(trigger string) {
+	id, err := j.start(trigger)
+	if err != nil {
+		LogWithError(err, nil)
+		return
+	}
+	j.execute(id, time.Now())
+}
+
+// 写入执行记录
+func (j *crontabJob) start(trigger string) (int64, error) {
+	res, err := Db.Exec("insert into crontab_run (name, trigger, outcome) values(?, ?, 'running')", j.name, trigger)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (j *crontabJob) execute(id int64, start time.Time) {
+	err := func() error {
+		switch j.overlap {
+		case "skip": // 上一次执行未结束时跳过本次执行
+			j.lock.Lock()
+			if j.running > 0 {
+				j.lock.Unlock()
+				return errSkipped
+			}
+			j.running++
+			j.lock.Unlock()
+			defer func() {
+				j.lock.Lock()
+				j.running--
+				j.lock.Unlock()
+			}()
+		case "queue": // 等待上一次执行结束后再执行
+			j.lock.Lock()
+			defer j.lock.Unlock()
+		}
+
+		worker, err := WorkerPool.Acquire(time.Duration(config.WaitTimeout) * time.Millisecond)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			worker.Reset()
+			WorkerPool.Release(worker)
+		}()
+
+		worker.SetTask("crontab", j.name, "", "")
+
+		value, err := worker.Run(worker.Runtime().ToValue("./crontab/" + j.name))
+		if err == nil {
+			_, err = util.ExportGojaValue(value) // 获取异步方法的执行结果
+		}
+		if err != nil {
+			LogWithError(err, worker)
+		}
+		return err
+	}()
+
+	outcome, message := "success", ""
+	if err == errSkipped {
+		outcome = "skipped"
+	} else if err != nil {
+		outcome, message = "failed", err.Error()
+	}
+	crontabRuns.Add(1, j.name, outcome)
+	if outcome != "skipped" {
+		crontabDuration.Observe(time.Since(start).Seconds(), j.name)
+	}
+	if _, e := Db.Exec("update crontab_run set end_date = datetime('now', 'localtime'), duration = ?, outcome = ?, error = ? where id = ?", time.Since(start).Milliseconds(), outcome, message, id); e != nil {
+		LogWithError(e, nil)
+	}
 }
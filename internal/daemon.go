@@ -1,37 +1,184 @@
 package internal
 
+import (
+	"sync"
+	"time"
+)
+
+const (
+	daemonMinBackoff = time.Second     // 重启的初始等待时间
+	daemonMaxBackoff = 5 * time.Minute // 重启的最大等待时间，运行时长超过该值后将重置等待时间
+)
+
+// 守护任务的监管状态
+type DaemonState struct {
+	Restarts       int       // 自启动以来的重启次数
+	LastExitReason string    // 最近一次退出的原因：returned、failed、stopped
+	LastError      string    // 最近一次失败的异常信息
+	LastExitDate   time.Time // 最近一次退出的时间
+	stop           chan struct{}
+}
+
+var daemons = struct {
+	sync.Mutex
+	states map[string]*DaemonState
+}{states: make(map[string]*DaemonState)}
+
 func RunDaemons(name string) {
 	if name == "" {
 		name = "%"
 	}
 
-	rows, err := Db.Query("select name from source where name like ? and type = 'daemon' and active = true", name)
+	rows, err := Db.Query("select name, restart, max_restarts from source where name like ? and type = 'daemon' and active = true", name)
 	if err != nil {
 		panic(err)
 	}
 	defer rows.Close()
 	for rows.Next() {
-		var n string
-		rows.Scan(&n)
+		var (
+			n, restart  string
+			maxRestarts int
+		)
+		rows.Scan(&n, &restart, &maxRestarts)
 
-		if Cache.Daemons[n] != nil { // 防止重复执行
+		daemons.Lock()
+		state := daemons.states[n]
+		if state != nil && state.stop != nil && !isStopped(state.stop) { // 防止重复执行
+			daemons.Unlock()
 			continue
 		}
+		if state == nil {
+			state = &DaemonState{}
+			daemons.states[n] = state
+		}
+		state.Restarts, state.stop = 0, make(chan struct{})
+		daemons.Unlock()
+
+		go superviseDaemon(n, restart, maxRestarts, state, state.stop)
+	}
+}
+
+// 监管守护任务，按重启策略在退出后以指数退避的方式重新启动
+func superviseDaemon(name string, restart string, maxRestarts int, state *DaemonState, stop chan struct{}) {
+	defer func() {
+		daemons.Lock()
+		if state.stop == stop { // 停止后可能已被重新启动
+			state.stop = nil
+		}
+		daemons.Unlock()
+	}()
+
+	backoff := daemonMinBackoff
+	for {
+		start := time.Now()
+		err := runDaemon(name, stop)
+
+		daemons.Lock()
+		stopped, restarts := isStopped(stop), state.Restarts
+		state.LastExitDate, state.LastError = time.Now(), ""
+		switch {
+		case stopped:
+			state.LastExitReason = "stopped"
+		case err != nil:
+			state.LastExitReason, state.LastError = "failed", err.Error()
+		default:
+			state.LastExitReason = "returned"
+		}
+		daemons.Unlock()
+
+		if stopped {
+			return
+		}
+		if restart != "always" && (restart != "on-failure" || err == nil) {
+			return
+		}
+		if maxRestarts > 0 && restarts >= maxRestarts {
+			return
+		}
+
+		// 运行时长超过最大等待时间，视为已恢复正常，重置等待时间
+		if time.Since(start) > daemonMaxBackoff {
+			backoff = daemonMinBackoff
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, daemonMaxBackoff)
 
-		go func() {
-			worker := <-WorkerPool.Channels
-			defer func() {
-				worker.Reset()
-				WorkerPool.Channels <- worker
-				delete(Cache.Daemons, n)
-			}()
+		daemons.Lock()
+		state.Restarts++
+		daemons.Unlock()
+	}
+}
+
+func runDaemon(name string, stop chan struct{}) error {
+	worker, err := WorkerPool.Acquire(-1) // 不限等待时长
+	if err != nil {
+		return err
+	}
+
+	daemons.Lock()
+	if isStopped(stop) { // 等待实例期间已被停止
+		daemons.Unlock()
+		WorkerPool.Release(worker)
+		return nil
+	}
+	Cache.Daemons[name] = worker
+	worker.SetTask("daemon", name, "", "")
+	daemons.Unlock()
+
+	defer func() {
+		// 先移除缓存再归还实例，防止 StopDaemon 中断已被其他任务复用的实例，停止后立即重新启动时，缓存可能已是新的实例
+		daemons.Lock()
+		if Cache.Daemons[name] == worker {
+			delete(Cache.Daemons, name)
+		}
+		daemons.Unlock()
+		worker.Reset()
+		WorkerPool.Release(worker)
+	}()
+
+	_, err = worker.Run(worker.Runtime().ToValue("./daemon/" + name))
+	if err != nil {
+		LogWithError(err, worker)
+	}
+	return err
+}
+
+func isStopped(stop chan struct{}) bool {
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// 停止守护任务，包括正在等待重启的任务
+func StopDaemon(name string) {
+	daemons.Lock()
+	defer daemons.Unlock()
+
+	if state := daemons.states[name]; state != nil && state.stop != nil && !isStopped(state.stop) {
+		close(state.stop)
+	}
+	if worker := Cache.Daemons[name]; worker != nil {
+		worker.InterruptTask("Daemon stopped") // 停止，停止后会自动清理缓存，见 runDaemon 方法的 defer 实现
+	}
+}
 
-			Cache.Daemons[n] = worker
+// 获取守护任务的监管状态，第二个返回值表示是否正在被监管（运行中或等待重启）
+func GetDaemonState(name string) (DaemonState, bool) {
+	daemons.Lock()
+	defer daemons.Unlock()
 
-			_, err := worker.Run(worker.Runtime().ToValue("./daemon/" + n))
-			if err != nil {
-				LogWithError(err, worker)
-			}
-		}()
+	state := daemons.states[name]
+	if state == nil {
+		return DaemonState{}, false
 	}
+	return *state, state.stop != nil && !isStopped(state.stop)
 }
@@ -23,16 +23,88 @@ func InitDb() {
 			lang varchar(16) not null,
 			content text not null default '',
 			compiled text not null default '',
+			source_map text not null default '',
 			active boolean not null default false,
 			method varchar(8) not null default '',
 			url varchar(64) not null default '',
 			cron varchar(16) not null default '',
 			tag text not null default '',
+			priority integer not null default 0,
+			timeout integer not null default 0,
+			body_limit integer not null default 0,
+			concurrency integer not null default 0,
+			restart varchar(16) not null default '',
+			max_restarts integer not null default 0,
+			overlap varchar(16) not null default '',
 			last_modified_date datetime default (datetime('now', 'localtime')),
 			primary key(name, type)
 		);
+		create table if not exists source_history (
+			name varchar(64) not null,
+			type varchar(16) not null,
+			revision integer not null,
+			content text not null default '',
+			compiled text not null default '',
+			source_map text not null default '',
+			author varchar(64) not null default '',
+			created_date datetime default (datetime('now', 'localtime')),
+			primary key(name, type, revision)
+		);
+		create table if not exists crontab_run (
+			id integer primary key autoincrement,
+			name varchar(64) not null,
+			trigger varchar(16) not null,
+			start_date datetime default (datetime('now', 'localtime')),
+			end_date datetime,
+			duration integer not null default 0,
+			outcome varchar(16) not null,
+			error text not null default ''
+		);
+		create index if not exists crontab_run_name on crontab_run (name, id);
+		create table if not exists job (
+			id integer primary key autoincrement,
+			module varchar(64) not null,
+			payload text not null default 'null',
+			status varchar(16) not null default 'pending',
+			attempts integer not null default 0,
+			max_attempts integer not null default 1,
+			backoff integer not null default 1000,
+			run_at datetime not null,
+			last_error text not null default '',
+			created_date datetime default (datetime('now', 'localtime')),
+			updated_date datetime default (datetime('now', 'localtime'))
+		);
+		create index if not exists job_status_run_at on job (status, run_at);
 	`)
 	if err != nil {
 		panic(err)
 	}
+
+	// 升级旧版本的数据库，补充新增的字段
+	for _, c := range [][3]string{
+		{"source", "priority", "integer not null default 0"},
+		{"source", "timeout", "integer not null default 0"},
+		{"source", "body_limit", "integer not null default 0"},
+		{"source", "concurrency", "integer not null default 0"},
+		{"source", "restart", "varchar(16) not null default ''"},
+		{"source", "max_restarts", "integer not null default 0"},
+		{"source", "overlap", "varchar(16) not null default ''"},
+		{"source", "source_map", "text not null default ''"},
+		{"source_history", "source_map", "text not null default ''"},
+	} {
+		addColumnIfNotExists(c[0], c[1], c[2])
+	}
+}
+
+func addColumnIfNotExists(table string, column string, definition string) {
+	var count int
+	if err := Db.QueryRow("select count(1) from pragma_table_info(?) where name = ?", table, column).Scan(&count); err != nil {
+		panic(err)
+	}
+	if count > 0 {
+		return
+	}
+	if _, err := Db.Exec("alter table " + table + " add column " + column + " " + definition); err != nil {
+		panic(err)
+	}
 }
@@ -0,0 +1,234 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cube/internal/builtin"
+	m "cube/internal/module"
+
+	"github.com/dop251/goja"
+)
+
+//#region event stream
+
+type EventStreamOptions struct {
+	Retry     int  // 建议客户端断线重连的间隔，单位毫秒，0 表示不发送
+	Heartbeat *int // 发送注释心跳的间隔，单位毫秒，默认 15 秒，0 表示不发送；用于防止代理因空闲而断开连接
+}
+
+// 服务端推送事件（Server-Sent Events）流，客户端断开或调用 close 前 vm 实例不会被归还
+type EventStream struct {
+	LastEventId string // 客户端断线重连时携带的 Last-Event-ID
+	worker      *Worker
+	writer      http.ResponseWriter
+	flusher     http.Flusher
+	lock        sync.Mutex // 写锁，心跳在单独的协程中写入
+	closed      bool
+	trigger     *builtin.EventTaskTrigger
+	done        chan struct{}
+	doneOnce    sync.Once
+	subscribers []*m.EventSubscriber
+	Onclose     goja.Value // 连接关闭时的回调，包括客户端断开和调用 close
+}
+
+func NewEventStream(worker *Worker, r *http.Request, w http.ResponseWriter, options *EventStreamOptions) (*EventStream, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, errors.New("failed to get a http flusher")
+	}
+	if options == nil {
+		options = &EventStreamOptions{}
+	}
+
+	s := &EventStream{
+		LastEventId: r.Header.Get("Last-Event-ID"),
+		worker:      worker,
+		writer:      w,
+		flusher:     flusher,
+		trigger:     worker.EventLoop().NewEventTaskTrigger(),
+		done:        make(chan struct{}),
+	}
+	if s.LastEventId == "" { // 不支持自定义请求头的客户端（如 EventSource 的 polyfill）可通过查询参数传递
+		s.LastEventId = r.URL.Query().Get("lastEventId")
+	}
+	worker.AddDefer(s.release)
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream; charset=utf-8")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Accel-Buffering", "no") // 禁用 nginx 的响应缓冲
+	if r.ProtoMajor == 1 {
+		header.Set("Connection", "keep-alive")
+	}
+	w.WriteHeader(http.StatusOK)
+
+	var b strings.Builder
+	if options.Retry > 0 {
+		b.WriteString("retry: " + strconv.Itoa(options.Retry) + "\n\n")
+	}
+	if err := s.write(b.String()); err != nil {
+		return nil, err
+	}
+
+	heartbeat := 15000
+	if options.Heartbeat != nil {
+		heartbeat = *options.Heartbeat
+	}
+	if heartbeat > 0 {
+		go s.heartbeat(time.Duration(heartbeat) * time.Millisecond)
+	}
+
+	go s.watch(r.Context())
+
+	return s, nil
+}
+
+// 写入并立即发送，以冒号开头的注释行会被客户端忽略
+func (s *EventStream) write(data string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return errors.New("event stream is closed")
+	}
+	if data != "" {
+		if _, err := s.writer.Write([]byte(data)); err != nil {
+			return err
+		}
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+func (s *EventStream) heartbeat(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			if s.write(": ping\n\n") != nil {
+				return
+			}
+		}
+	}
+}
+
+// 监听客户端断开连接
+func (s *EventStream) watch(ctx context.Context) {
+	select {
+	case <-s.done:
+	case <-ctx.Done():
+		s.trigger.TryAddTask(func() {
+			s.Close()
+		}, s.done)
+	}
+}
+
+// 释放资源，停止心跳和事件订阅，可重复调用
+func (s *EventStream) release() {
+	s.doneOnce.Do(func() {
+		s.lock.Lock()
+		s.closed = true
+		s.lock.Unlock()
+		close(s.done)
+		for _, subscriber := range s.subscribers {
+			subscriber.Cancel()
+		}
+	})
+}
+
+// 发送事件，data 为字符串时原样发送，多行时拆分为多个 data 字段，其他值序列化为 JSON 后发送
+func (s *EventStream) Send(event goja.Value, data goja.Value, id goja.Value) error {
+	runtime := s.worker.Runtime()
+
+	text, ok := data.Export().(string)
+	if !ok {
+		stringify, _ := goja.AssertFunction(runtime.Get("JSON").ToObject(runtime).Get("stringify"))
+		v, err := stringify(nil, data)
+		if err != nil {
+			return err
+		}
+		if goja.IsUndefined(v) {
+			return errors.New("invalid argument data, cannot be serialized")
+		}
+		text = v.String()
+	}
+
+	var b strings.Builder
+	if !isBlank(event) {
+		b.WriteString("event: " + singleLine(event.String()) + "\n")
+	}
+	if !isBlank(id) {
+		b.WriteString("id: " + singleLine(id.String()) + "\n")
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\r", "\n"), "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+	b.WriteString("\n")
+	return s.write(b.String())
+}
+
+// 发送注释，可用于自定义的心跳
+func (s *EventStream) Comment(text string) error {
+	var b strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		b.WriteString(": " + strings.TrimSuffix(line, "\r") + "\n")
+	}
+	b.WriteString("\n")
+	return s.write(b.String())
+}
+
+// 建议客户端断线重连的间隔，单位毫秒
+func (s *EventStream) Retry(interval int) error {
+	return s.write("retry: " + strconv.Itoa(interval) + "\n\n")
+}
+
+// 将 $native("event") 中的主题直接转发为同名的事件
+func (s *EventStream) Subscribe(topics ...string) {
+	for _, topic := range topics {
+		topic := topic
+		s.subscribers = append(s.subscribers, m.Listen(s.worker, topic, func(data interface{}) {
+			if s.IsClosed() {
+				return
+			}
+			if err := s.Send(s.worker.Runtime().ToValue(topic), s.worker.Runtime().ToValue(data), nil); err != nil {
+				s.Close()
+			}
+		}))
+	}
+}
+
+func (s *EventStream) IsClosed() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.closed
+}
+
+// 关闭事件流，触发 onclose 事件后脚本将结束
+func (s *EventStream) Close() {
+	if !s.trigger.Cancel() {
+		return
+	}
+	s.release()
+	if fn, ok := goja.AssertFunction(s.Onclose); ok {
+		fn(nil)
+	}
+}
+
+func isBlank(v goja.Value) bool {
+	return v == nil || goja.IsUndefined(v) || goja.IsNull(v) || v.String() == ""
+}
+
+// 字段值中不能包含换行符
+func singleLine(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
+
+//#endregion
@@ -7,6 +7,7 @@ import (
 	"io/fs"
 	"net/http"
 
+	"cube/internal"
 	"cube/internal/builtin"
 	"cube/internal/config"
 	"cube/internal/util"
@@ -23,6 +24,12 @@ func InitHandle(web *embed.FS) {
 	http.HandleFunc("/source", authenticate(HandleSource))
 	http.HandleFunc("/document/", authenticate(HandleDocument))
 
+	// 运维
+	http.HandleFunc("/worker", authenticate(HandleWorker))
+	http.HandleFunc("/job", authenticate(HandleJob))
+	http.HandleFunc("/logs", authenticate(HandleLog))
+	http.HandleFunc("/metrics", HandleMetrics)
+
 	fileList, _ := fs.Sub(web, "web")
 	http.Handle("/", http.FileServer(http.FS(fileList)))
 }
@@ -57,29 +64,53 @@ func Error(w http.ResponseWriter, data interface{}) {
 		http.Error(w, http.StatusText(err), err)
 	case string:
 		http.Error(w, err, http.StatusBadRequest)
+	case *internal.CompileError: // 编译异常，额外返回出错的文件、行号和列号，用于编辑器定位
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"code":    "1",
+			"message": err.Error(),
+			"data":    err,
+		})
 	case error:
-		code, message := "1", err.Error() // 错误信息默认包含了异常信息和调用栈
-		if e, ok := err.(*goja.Exception); ok {
-			if o, ok := e.Value().Export().(map[string]interface{}); ok {
+		code, message := "1", err.Error() // 错误信息默认包含了异常信息和抛出的位置
+		stack := util.StackFrames(err)    // 按源映射还原为源码位置的调用栈，用于 IDE 定位
+		if v := thrownValue(err); v != nil {
+			if o, ok := v.Export().(map[string]interface{}); ok {
 				if m, ok := util.ExportMapValue(o, "message", "string"); ok {
-					message = m.(string) // 获取 throw 对象中的 message 和 code 属性，作为失败响应的错误信息和错误码
+					message, stack = m.(string), nil // 获取 throw 对象中的 message 和 code 属性，作为失败响应的错误信息和错误码，此时不返回调用栈
 				}
 				if c, ok := util.ExportMapValue(o, "code", "string"); ok {
 					code = c.(string)
 				}
 			}
 		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest) // 在同一次请求响应过程中，只能调用一次 WriteHeader，否则会抛出异常 http: superfluous response.WriteHeader call from ...
-		json.NewEncoder(w).Encode(map[string]interface{}{
+		body := map[string]interface{}{
 			"code":    code,
 			"message": message,
-		})
+		}
+		if len(stack) > 0 {
+			body["stack"] = stack
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest) // 在同一次请求响应过程中，只能调用一次 WriteHeader，否则会抛出异常 http: superfluous response.WriteHeader call from ...
+		json.NewEncoder(w).Encode(body)
 	default:
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 	}
 }
 
+// 获取脚本中抛出的值，包括异步方法中被拒绝的 Promise 的值
+func thrownValue(err error) goja.Value {
+	switch e := err.(type) {
+	case *goja.Exception:
+		return e.Value()
+	case *util.PromiseRejection:
+		return e.Value
+	}
+	return nil
+}
+
 func authenticate(next func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// 如果未配置用户名密码，直接执行
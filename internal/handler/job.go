@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	. "cube/internal"
+	"cube/internal/model"
+	"cube/internal/util"
+)
+
+func HandleJob(w http.ResponseWriter, r *http.Request) {
+	var (
+		data interface{}
+		err  error
+	)
+	switch r.Method {
+	case http.MethodGet:
+		data, err = handleJobGet(r)
+	case http.MethodPut:
+		err = handleJobRetry(r)
+	case http.MethodDelete:
+		err = handleJobDelete(r)
+	default:
+		Error(w, http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		Error(w, err)
+		return
+	}
+	Success(w, data)
+}
+
+// 分页查询任务，可按状态过滤，如 status=pending、status=dead
+func handleJobGet(r *http.Request) (interface{}, error) {
+	p := &util.QueryParams{Values: r.URL.Query()}
+	status, module := p.GetOrDefault("status", "%"), p.GetOrDefault("module", "%")
+	from, size := p.GetIntOrDefault("from", 0), p.GetIntOrDefault("size", 10)
+
+	var data struct {
+		Jobs  []model.Job `json:"jobs"`
+		Total int         `json:"total"`
+	}
+	data.Jobs = make([]model.Job, 0, size)
+
+	if err := Db.QueryRow("select count(1) from job where status like ? and module like ?", status, module).Scan(&data.Total); err != nil {
+		return data, err
+	}
+
+	rows, err := Db.Query("select id, module, payload, status, attempts, max_attempts, run_at, last_error, created_date, updated_date from job where status like ? and module like ? order by id desc limit ?, ?", status, module, from, size)
+	if err != nil {
+		return data, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var job model.Job
+		if err := rows.Scan(&job.Id, &job.Module, &job.Payload, &job.Status, &job.Attempts, &job.MaxAttempts, &job.RunAt, &job.LastError, &job.CreatedDate, &job.UpdatedDate); err != nil {
+			return data, err
+		}
+		data.Jobs = append(data.Jobs, job)
+	}
+
+	return data, nil
+}
+
+// 重新执行死信状态的任务
+func handleJobRetry(r *http.Request) error {
+	id := (&util.QueryParams{Values: r.URL.Query()}).GetIntOrDefault("id", 0)
+
+	res, err := Db.Exec("update job set status = 'pending', attempts = 0, run_at = strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime'), updated_date = datetime('now', 'localtime') where id = ? and status = 'dead'", id)
+	if err != nil {
+		return err
+	}
+	if count, _ := res.RowsAffected(); count == 0 {
+		return errors.New("dead job does not existed")
+	}
+	return nil
+}
+
+// 删除未在执行中的任务
+func handleJobDelete(r *http.Request) error {
+	id := (&util.QueryParams{Values: r.URL.Query()}).GetIntOrDefault("id", 0)
+
+	res, err := Db.Exec("delete from job where id = ? and status != 'running'", id)
+	if err != nil {
+		return err
+	}
+	if count, _ := res.RowsAffected(); count == 0 {
+		return errors.New("job does not existed or is running")
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	. "cube/internal"
+	"cube/internal/util"
+)
+
+func HandleLog(w http.ResponseWriter, r *http.Request) {
+	var (
+		data interface{}
+		err  error
+	)
+	switch r.Method {
+	case http.MethodGet:
+		if r.URL.Query().Has("tail") {
+			handleLogTail(w, r)
+			return
+		}
+		if r.URL.Query().Has("levels") {
+			data = util.MyLogger.Levels()
+			break
+		}
+		data, err = handleLogGet(r)
+	case http.MethodPut:
+		err = handleLogLevel(r)
+	default:
+		Error(w, http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		Error(w, err)
+		return
+	}
+	Success(w, data)
+}
+
+// 查询日志，可按来源、等级（不低于）、请求 id 和时间范围过滤，如 source=foo&level=warn&from=2024-01-01 00:00:00&size=100
+func handleLogGet(r *http.Request) (interface{}, error) {
+	p := &util.QueryParams{Values: r.URL.Query()}
+	q := &util.LogQuery{
+		Kind:      p.Get("kind"),
+		Source:    p.Get("source"),
+		RequestId: p.Get("requestId"),
+		TraceId:   p.Get("traceId"),
+		Level:     p.Get("level"),
+		Size:      p.GetIntOrDefault("size", 100),
+	}
+	if _, found := util.LogLevels[q.Level]; q.Level != "" && !found {
+		return nil, errors.New("invalid log level: " + q.Level)
+	}
+	var err error
+	if q.From, err = parseLogTime(p.Get("from")); err != nil {
+		return nil, err
+	}
+	if q.To, err = parseLogTime(p.Get("to")); err != nil {
+		return nil, err
+	}
+
+	entries := make([]*util.LogEntry, 0)
+	for _, name := range LogFiles() {
+		if t, err := util.RotatedTime(LogFile, name); err == nil && t.Before(q.From) { // 已轮转的文件中的日志均早于开始时间
+			continue
+		}
+		matches, err := queryLogFile(name, q)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, matches...)
+	}
+	if q.Size > 0 && len(entries) > q.Size {
+		entries = entries[len(entries)-q.Size:]
+	}
+	return entries, nil
+}
+
+func queryLogFile(name string, q *util.LogQuery) ([]*util.LogEntry, error) {
+	r, err := util.OpenLogFile(name)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) { // 已在压缩或清理中被删除
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer r.Close()
+	return util.QueryLogs(r, q)
+}
+
+// 以服务端推送事件（Server-Sent Events）的方式实时输出新写入的日志，过滤条件与查询相同，size 为先输出的最近的日志条数
+func handleLogTail(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		Error(w, http.StatusInternalServerError)
+		return
+	}
+	p := &util.QueryParams{Values: r.URL.Query()}
+	q := &util.LogQuery{
+		Kind:      p.Get("kind"),
+		Source:    p.Get("source"),
+		RequestId: p.Get("requestId"),
+		TraceId:   p.Get("traceId"),
+		Level:     p.Get("level"),
+	}
+	if _, found := util.LogLevels[q.Level]; q.Level != "" && !found {
+		Error(w, "invalid log level: "+q.Level)
+		return
+	}
+
+	ch, cancel := util.MyLogger.Subscribe(q) // 先订阅，再查询最近的日志，避免遗漏两者之间写入的日志
+	defer cancel()
+
+	recent := make([]*util.LogEntry, 0)
+	if size := p.GetIntOrDefault("size", 0); size > 0 {
+		backlog := *q
+		backlog.Size = size
+		recent, _ = queryLogFile(LogFile, &backlog)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	write := func(e *util.LogEntry) error {
+		b, _ := json.Marshal(e)
+		if _, err := w.Write(append(append([]byte("data: "), b...), '\n', '\n')); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+	// 记录最近的日志中与最后一条同时写入的日志，同一时刻可能写入多条日志，因此按时间和内容去重
+	var last time.Time
+	written := make(map[logKey]int)
+	for _, e := range recent {
+		if write(e) != nil {
+			return
+		}
+		if !e.Time.Equal(last) {
+			last = e.Time
+			clear(written)
+		}
+		written[newLogKey(e)]++
+	}
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e := <-ch:
+			if e.Time.Before(last) { // 已在最近的日志中输出
+				continue
+			}
+			if k := newLogKey(e); e.Time.Equal(last) && written[k] > 0 {
+				written[k]--
+				continue
+			}
+			if write(e) != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := w.Write([]byte(": ping\n\n")); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// 日志的标识，用于订阅的日志与最近的日志去重
+type logKey struct {
+	time      int64
+	level     string
+	worker    int
+	source    string
+	requestId string
+	message   string
+}
+
+func newLogKey(e *util.LogEntry) logKey {
+	return logKey{e.Time.UnixNano(), e.Level, e.Worker, e.Source, e.RequestId, e.Message}
+}
+
+// 支持 2006-01-02 15:04:05 格式的本地时间和 RFC 3339 格式的时间
+func parseLogTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05", value, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return t, errors.New("invalid time: " + value)
+	}
+	return t, nil
+}
+
+// 调整来源的最低等级，如 {"source": "foo", "level": "warn"}，source 为空时调整默认的最低等级，level 为空时恢复为默认的最低等级
+func handleLogLevel(r *http.Request) error {
+	var body struct {
+		Source string `json:"source"`
+		Level  string `json:"level"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return err
+	}
+	return util.MyLogger.SetLevel(body.Source, body.Level)
+}
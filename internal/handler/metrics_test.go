@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type pushRecorder struct {
+	*httptest.ResponseRecorder
+	targets []string
+}
+
+func (p *pushRecorder) Push(target string, opts *http.PushOptions) error {
+	p.targets = append(p.targets, target)
+	return nil
+}
+
+func TestStatusRecorderPush(t *testing.T) {
+	p := &pushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	var w http.ResponseWriter = &statusRecorder{ResponseWriter: p}
+	pusher, ok := w.(http.Pusher)
+	if !ok {
+		t.Fatal("statusRecorder does not implement http.Pusher")
+	}
+	if err := pusher.Push("/app.js", nil); err != nil || len(p.targets) != 1 || p.targets[0] != "/app.js" {
+		t.Fatalf("unexpected push: %v, %v", err, p.targets)
+	}
+
+	w = &statusRecorder{ResponseWriter: httptest.NewRecorder()}
+	if err := w.(http.Pusher).Push("/app.js", nil); err != http.ErrNotSupported {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -1,36 +1,90 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"cube/internal"
+	"cube/internal/config"
+	m "cube/internal/module"
 	"cube/internal/util"
 )
 
+var concurrencies sync.Map // controller 名称与当前并发执行数的映射
+
 func HandleService(w http.ResponseWriter, r *http.Request) {
 	path := strings.TrimPrefix(r.URL.Path, "/service/")
 
 	// 查询 controller
-	name, vars := internal.Cache.GetRoute(path)
+	name, vars, allowed := internal.Cache.GetRoute(r.Method, path)
 	if name == "" {
-		Error(w, http.StatusNotFound)
+		if len(allowed) == 0 {
+			Error(w, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Allow", strings.Join(append(allowed, http.MethodOptions), ", "))
+		if r.Method == http.MethodOptions { // 如果没有 controller 处理 OPTIONS 请求，则自动响应所允许的请求方法
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		Error(w, http.StatusMethodNotAllowed)
 		return
 	}
 
 	source := internal.Cache.GetController(name)
-	if source.Method != "" && source.Method != r.Method { // 校验请求方法
-		Error(w, http.StatusMethodNotAllowed)
+	if source == nil {
+		Error(w, http.StatusNotFound)
 		return
 	}
 
+	// 统计请求数、状态码和耗时，并记录调用链路，优先延续上游传递的调用链路
+	start, recorder := time.Now(), &statusRecorder{ResponseWriter: w}
+	w = recorder
+	span := util.MyTracer.ContinueSpan(r.Header.Get("traceparent"), r.Method+" "+source.Name, "server") // 以 controller 名称命名，避免路径参数导致名称过多
+	span.SetAttribute("http.method", r.Method)
+	span.SetAttribute("http.target", r.URL.RequestURI())
+	span.SetAttribute("controller", source.Name)
+	defer func() {
+		status := recorder.Status()
+		internal.RequestsTotal.Add(1, source.Name, r.Method, strconv.Itoa(status))
+		internal.RequestDuration.Observe(time.Since(start).Seconds(), source.Name)
+		span.SetAttribute("http.status_code", status)
+		if status >= http.StatusInternalServerError {
+			span.SetError(errors.New(http.StatusText(status)))
+		}
+		span.Finish()
+	}()
+
+	// 校验请求消息体大小
+	if source.BodyLimit > 0 {
+		if r.ContentLength > source.BodyLimit {
+			Error(w, http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, source.BodyLimit) // 用于限制未声明 Content-Length 的请求，如 chunk 传输
+	}
+
+	// 校验并发执行数
+	if source.Concurrency > 0 {
+		v, _ := concurrencies.LoadOrStore(source.Name, new(atomic.Int32))
+		counter := v.(*atomic.Int32)
+		if counter.Add(1) > int32(source.Concurrency) {
+			counter.Add(-1)
+			Error(w, http.StatusTooManyRequests)
+			return
+		}
+		defer counter.Add(-1)
+	}
+
 	// 获取 vm 实例
-	var worker *internal.Worker
-	select {
-	case worker = <-internal.WorkerPool.Channels:
-	default:
-		Error(w, http.StatusServiceUnavailable) // 如果无可用实例，则返回 503
+	worker, err := internal.WorkerPool.Acquire(time.Duration(config.WaitTimeout) * time.Millisecond)
+	if err != nil {
+		Error(w, http.StatusServiceUnavailable) // 如果等待队列已满或等待超时，则返回 503
 		return
 	}
 	defer func() {
@@ -38,11 +92,26 @@ func HandleService(w http.ResponseWriter, r *http.Request) {
 			Error(w, x)
 		}
 		worker.Reset()
-		internal.WorkerPool.Channels <- worker // 归还实例
+		internal.WorkerPool.Release(worker) // 归还实例
 	}()
 
-	// 允许最大执行的时间为 60 秒
-	timer := time.AfterFunc(60*time.Second, func() {
+	// 请求 id，优先使用上游（如网关）传递的 id，用于关联请求期间的日志
+	requestId := r.Header.Get("X-Request-Id")
+	if requestId == "" {
+		requestId = m.CreateULID()
+	}
+	w.Header().Set("X-Request-Id", requestId)
+	worker.SetTask("controller", source.Name, r.URL.Path, requestId)
+	worker.PushSpan(span)
+
+	// 允许最大执行的时间，默认为 60 秒
+	timeout := source.Timeout
+	if timeout <= 0 {
+		timeout = config.Timeout
+	}
+	var timedout atomic.Bool
+	timer := time.AfterFunc(time.Duration(timeout)*time.Millisecond, func() {
+		timedout.Store(true)
 		worker.Interrupt("service executed timeout")
 	})
 	defer timer.Stop()
@@ -50,24 +119,30 @@ func HandleService(w http.ResponseWriter, r *http.Request) {
 	// 脚本执行完成标记
 	completed := false
 
+	ctx := internal.CreateServiceContext(worker, r, w, timer, &vars)
+
 	// 监听客户端是否主动取消请求
 	go func() {
-		<-r.Context().Done() // 客户端主动取消
-		if !completed {      // 如果脚本已执行结束，不再中断 goja 运行时，否则中断信号无法被触发和清除（需要 goja 运行时执行指令栈才会触发中断操作），导致回收再复用时直接抛出 "Client cancelled." 的异常
+		<-r.Context().Done()                        // 客户端主动取消
+		if !completed && !internal.Streaming(ctx) { // 如果脚本已执行结束，不再中断 goja 运行时，否则中断信号无法被触发和清除（需要 goja 运行时执行指令栈才会触发中断操作），导致回收再复用时直接抛出 "Client cancelled." 的异常；事件流在客户端断开时由其自身触发 onclose 后正常结束
 			worker.Interrupt("client cancelled")
 		}
 	}()
 
-	ctx := internal.CreateServiceContext(r, w, timer, &vars)
-
-	// 执行
-	value, err := worker.Run(
-		worker.Runtime().ToValue("./controller/"+source.Name),
+	// 依次执行匹配的过滤器和 controller
+	filters := internal.Cache.GetFilters(r.Method, path)
+	for i, name := range filters {
+		filters[i] = "./filter/" + name
+	}
+	value, err := worker.RunWithFilters(
+		filters,
+		"./controller/"+source.Name,
 		worker.Runtime().ToValue(ctx),
 	)
 
 	// 标记脚本执行完成
 	completed = true
+	span.SetError(err)
 
 	if internal.Returnless(ctx) == true { // 如果是 WebSocket 或 chunk 响应，不需要封装响应
 		if err != nil {
@@ -77,6 +152,14 @@ func HandleService(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err != nil {
+		if e := new(http.MaxBytesError); errors.As(err, &e) { // 读取的请求消息体超出限制
+			Error(w, http.StatusRequestEntityTooLarge)
+			return
+		}
+		if timedout.Load() { // 执行超时
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
 		Error(w, err) // 如果 returnless 为 true，则可能已经执行了 response.Write，此时不能调用 toError 或 toSuccess（该方法会间接调用 WriteHeader），由于 Write 必须在 WriteHeader 之后调用，从而导致异常 http: superfluous response.WriteHeader call from ...
 		return
 	}
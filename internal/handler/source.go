@@ -12,6 +12,7 @@ import (
 	"time"
 
 	. "cube/internal"
+	"cube/internal/config"
 	"cube/internal/model"
 	"cube/internal/util"
 
@@ -26,17 +27,33 @@ func HandleSource(w http.ResponseWriter, r *http.Request) {
 	)
 	switch r.Method {
 	case http.MethodPost:
-		if _, bulk := r.URL.Query()["bulk"]; !bulk {
-			err = handleSourcePost(r)
-		} else {
+		q := r.URL.Query()
+		if q.Has("bulk") {
 			err = handleSourceBulkPost(r)
+		} else if q.Has("trigger") {
+			data, err = handleSourceTrigger(r)
+		} else {
+			err = handleSourcePost(r)
 		}
 	case http.MethodDelete:
 		err = handleSourceDelete(r)
 	case http.MethodPut:
-		err = handleSourcePut(r)
+		if _, rollback := r.URL.Query()["rollback"]; !rollback {
+			err = handleSourcePut(r)
+		} else {
+			err = handleSourceRollback(r)
+		}
 	case http.MethodGet:
-		data, returnless, err = handleSourceGet(w, r)
+		q := r.URL.Query()
+		if q.Has("history") {
+			data, err = handleSourceHistoryGet(r)
+		} else if q.Has("diff") {
+			data, err = handleSourceDiffGet(r)
+		} else if q.Has("runs") {
+			data, err = handleSourceRunsGet(r)
+		} else {
+			data, returnless, err = handleSourceGet(w, r)
+		}
 	case "EVAL":
 		handleSourceEval(w, r)
 		returnless = true
@@ -61,8 +78,8 @@ func handleSourcePost(r *http.Request) error {
 	}
 
 	// 校验类型
-	if ok, _ := regexp.MatchString("^(module|controller|daemon|crontab|template|resource)$", source.Type); !ok {
-		return errors.New("type must be module, controller, daemon, crontab, template or resource")
+	if ok, _ := regexp.MatchString("^(module|controller|daemon|crontab|template|resource|filter)$", source.Type); !ok {
+		return errors.New("type must be module, controller, daemon, crontab, template, resource or filter")
 	}
 	// 校验名称
 	if source.Type == "module" {
@@ -78,16 +95,30 @@ func handleSourcePost(r *http.Request) error {
 	if source.Active {
 		return errors.New("active must be false")
 	}
-	// 校验 url 不能重复
+	// 校验 url 格式
+	if source.Type == "controller" || source.Type == "filter" {
+		if err := util.NewRouter().Add(source.Name, source.Method, source.Url); err != nil {
+			return err
+		}
+	}
+	// 校验 url 不能重复，controller 的 url 和 method 不能同时重复，其中 method 为空时匹配任意的请求方法
 	if source.Type == "controller" || source.Type == "resource" {
 		var count int
-		if err := Db.QueryRow("select count(1) from source where type = ? and url = ? and name != ?", source.Type, source.Url, source.Name).Scan(&count); err != nil {
+		if err := Db.QueryRow("select count(1) from source where type = ? and url = ? and name != ? and (type != 'controller' or method = ? or method = '' or ? = '')", source.Type, source.Url, source.Name, source.Method, source.Method).Scan(&count); err != nil {
 			return err
 		}
 		if count > 0 {
 			return errors.New("url already existed")
 		}
 	}
+	// 校验重启策略
+	if ok, _ := regexp.MatchString("^(|never|on-failure|always)$", source.Restart); !ok {
+		return errors.New("restart must be never, on-failure or always")
+	}
+	// 校验 crontab 的重叠执行策略
+	if ok, _ := regexp.MatchString("^(|allow|skip|queue)$", source.Overlap); !ok {
+		return errors.New("overlap must be allow, skip or queue")
+	}
 	// 校验 cron 表达式
 	if source.Type == "crontab" {
 		if _, err := ParseCron(source.Cron); err != nil {
@@ -102,12 +133,23 @@ func handleSourcePost(r *http.Request) error {
 		}
 	}
 
+	// 编译，忽略客户端提交的 compiled 字段
+	source.Compiled, source.SourceMap = "", ""
+	if IsCompilable(source.Type, source.Lang) {
+		compiled, sourceMap, err := Compile(source.Name, source.Type, source.Content)
+		if err != nil {
+			return err
+		}
+		source.Compiled, source.SourceMap = compiled, sourceMap
+	}
+
 	// 新增
-	if _, err := Db.Exec("insert into source (name, type, lang, content, compiled, active, method, url, cron, tag, last_modified_date) values(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, datetime('now', 'localtime'))", source.Name, source.Type, source.Lang, source.Content, source.Compiled, source.Active, source.Method, source.Url, source.Cron, source.Tag); err != nil {
+	if _, err := Db.Exec("insert into source (name, type, lang, content, compiled, source_map, active, method, url, cron, tag, priority, timeout, body_limit, concurrency, restart, max_restarts, overlap, last_modified_date) values(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, datetime('now', 'localtime'))", source.Name, source.Type, source.Lang, source.Content, source.Compiled, source.SourceMap, source.Active, source.Method, source.Url, source.Cron, source.Tag, source.Priority, source.Timeout, source.BodyLimit, source.Concurrency, source.Restart, source.MaxRestarts, source.Overlap); err != nil {
 		return err
 	}
 
-	return nil
+	// 记录历史版本
+	return saveSourceHistory(source.Name, source.Type, getAuthor(r))
 }
 
 func handleSourceBulkPost(r *http.Request) error {
@@ -121,7 +163,7 @@ func handleSourceBulkPost(r *http.Request) error {
 	}
 
 	// 批量新增或修改
-	stmt, err := Db.Prepare("insert or replace into source (rowid, name, type, lang, content, compiled, active, method, url, cron, tag, last_modified_date) values(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	stmt, err := Db.Prepare("insert or replace into source (rowid, name, type, lang, content, compiled, source_map, active, method, url, cron, tag, priority, timeout, body_limit, concurrency, restart, max_restarts, overlap, last_modified_date) values(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
 	if err != nil {
 		return err
 	}
@@ -130,12 +172,21 @@ func handleSourceBulkPost(r *http.Request) error {
 		if source.Name == "" || source.Type == "" {
 			continue
 		}
-		if _, err = stmt.Exec(source.Id, source.Name, source.Type, source.Lang, source.Content, source.Compiled, source.Active, source.Method, source.Url, source.Cron, source.Tag, source.LastModifiedDate.String()); err != nil {
+		if IsCompilable(source.Type, source.Lang) { // 重新编译，防止导入的 compiled 与 content 不一致
+			if source.Compiled, source.SourceMap, err = Compile(source.Name, source.Type, source.Content); err != nil {
+				return err
+			}
+		}
+		if _, err = stmt.Exec(source.Id, source.Name, source.Type, source.Lang, source.Content, source.Compiled, source.SourceMap, source.Active, source.Method, source.Url, source.Cron, source.Tag, source.Priority, source.Timeout, source.BodyLimit, source.Concurrency, source.Restart, source.MaxRestarts, source.Overlap, source.LastModifiedDate.String()); err != nil {
+			return err
+		}
+		if err = saveSourceHistory(source.Name, source.Type, getAuthor(r)); err != nil {
 			return err
 		}
 	}
 
 	Cache.InitRoutes()
+	Cache.InitFilters()
 	// 批量导入后，需要清空 module 缓存以重建
 	Cache.Modules = make(map[string]*goja.Program)
 	// 启动守护任务
@@ -166,7 +217,10 @@ func handleSourceDelete(r *http.Request) error {
 
 	// 删除路由
 	if stype == "controller" {
-		delete(Cache.Routes, name)
+		Cache.DeleteRoute(name)
+	}
+	if stype == "filter" {
+		Cache.InitFilters()
 	}
 
 	return nil
@@ -180,23 +234,52 @@ func handleSourcePut(r *http.Request) error {
 	}
 
 	// 校验类型和名称
-	name, stype, url, cron, status := record["name"], record["type"], record["url"], record["cron"], record["status"]
+	name, stype, url, method, cron, status := record["name"], record["type"], record["url"], record["method"], record["cron"], record["status"]
 	if name == nil {
 		return errors.New("name is required")
 	}
 	if stype == nil {
 		return errors.New("type is required")
 	}
-	// 校验 url 不能重复
+	if (stype == "controller" || stype == "filter") && (url != nil || method != nil) {
+		// 未修改的 url 或 method 使用原值
+		var u, m string
+		if err := Db.QueryRow("select url, method from source where name = ? and type = ?", name, stype).Scan(&u, &m); err != nil {
+			return errors.New("source does not existed")
+		}
+		if url == nil {
+			url = u
+		}
+		if method == nil {
+			method = m
+		}
+		// 校验 url 格式
+		if err := util.NewRouter().Add(fmt.Sprint(name), fmt.Sprint(method), fmt.Sprint(url)); err != nil {
+			return err
+		}
+	}
+	// 校验 url 不能重复，controller 的 url 和 method 不能同时重复，其中 method 为空时匹配任意的请求方法
 	if url != nil && (stype == "controller" || stype == "resource") {
 		var count int
-		if err := Db.QueryRow("select count(1) from source where type = ? and url = ? and active = true and name != ?", stype, url, name).Scan(&count); err != nil {
+		if err := Db.QueryRow("select count(1) from source where type = ? and url = ? and active = true and name != ? and (type != 'controller' or method = ? or method = '' or ? = '')", stype, url, name, fmt.Sprint(method), fmt.Sprint(method)).Scan(&count); err != nil {
 			return err
 		}
 		if count > 0 {
 			return errors.New("url already existed")
 		}
 	}
+	// 校验重启策略
+	if restart, ok := record["restart"]; ok {
+		if ok, _ := regexp.MatchString("^(|never|on-failure|always)$", fmt.Sprint(restart)); !ok {
+			return errors.New("restart must be never, on-failure or always")
+		}
+	}
+	// 校验 crontab 的重叠执行策略
+	if overlap, ok := record["overlap"]; ok {
+		if ok, _ := regexp.MatchString("^(|allow|skip|queue)$", fmt.Sprint(overlap)); !ok {
+			return errors.New("overlap must be allow, skip or queue")
+		}
+	}
 	// 校验 cron 表达式
 	if cron != nil && stype == "crontab" {
 		if _, err := ParseCron(cron.(string)); err != nil {
@@ -204,9 +287,26 @@ func handleSourcePut(r *http.Request) error {
 		}
 	}
 
+	// 编译，忽略客户端提交的 compiled 字段
+	delete(record, "compiled")
+	delete(record, "source_map")
+	if content, ok := record["content"].(string); ok {
+		var lang string
+		if err := Db.QueryRow("select lang from source where name = ? and type = ?", name, stype).Scan(&lang); err != nil {
+			return errors.New("source does not existed")
+		}
+		if IsCompilable(fmt.Sprint(stype), lang) {
+			compiled, sourceMap, err := Compile(fmt.Sprint(name), fmt.Sprint(stype), content)
+			if err != nil {
+				return err
+			}
+			record["compiled"], record["source_map"] = compiled, sourceMap
+		}
+	}
+
 	// 修改
 	setsen, params := "", []interface{}{}
-	for _, c := range []string{"content", "compiled", "active", "method", "url", "cron", "tag"} {
+	for _, c := range []string{"content", "compiled", "source_map", "active", "method", "url", "cron", "tag", "priority", "timeout", "body_limit", "concurrency", "restart", "max_restarts", "overlap"} {
 		if v, ok := record[c]; ok {
 			setsen += ", " + c + " = ?"
 			params = append(params, v)
@@ -220,12 +320,35 @@ func handleSourcePut(r *http.Request) error {
 		return errors.New("source does not existed")
 	}
 
+	// 如果修改了源码，则记录历史版本
+	if _, ok := record["content"]; ok {
+		if err := saveSourceHistory(fmt.Sprint(name), fmt.Sprint(stype), getAuthor(r)); err != nil {
+			return err
+		}
+	}
+
 	// 查询更新后的记录
-	var source model.Source
-	if err := Db.QueryRow("select name, type, lang, active, method, url, cron, tag from source where name = ? and type = ?", name, stype).Scan(&source.Name, &source.Type, &source.Lang, &source.Active, &source.Method, &source.Url, &source.Cron, &source.Tag); err != nil {
+	source, err := getSource(name, stype)
+	if err != nil {
 		return err
 	}
 
+	refreshSource(source, status)
+
+	return nil
+}
+
+// 查询 source 的基本信息，不包含源码
+func getSource(name interface{}, stype interface{}) (*model.Source, error) {
+	var source model.Source
+	if err := Db.QueryRow("select name, type, lang, active, method, url, cron, tag, priority, timeout, body_limit, concurrency, restart, max_restarts, overlap from source where name = ? and type = ?", name, stype).Scan(&source.Name, &source.Type, &source.Lang, &source.Active, &source.Method, &source.Url, &source.Cron, &source.Tag, &source.Priority, &source.Timeout, &source.BodyLimit, &source.Concurrency, &source.Restart, &source.MaxRestarts, &source.Overlap); err != nil {
+		return nil, err
+	}
+	return &source, nil
+}
+
+// 刷新 source 相关的路由、缓存和任务
+func refreshSource(source *model.Source, status interface{}) {
 	switch source.Type {
 	case "module":
 		if strings.HasPrefix(source.Name, "node_modules/") {
@@ -236,33 +359,80 @@ func handleSourcePut(r *http.Request) error {
 
 	case "controller":
 		if source.Active {
-			Cache.SetRoute(source.Name, source.Url) // 更新路由
+			Cache.SetRoute(source.Name, source.Method, source.Url) // 更新路由
 		} else {
-			delete(Cache.Routes, source.Name) // 删除路由
+			Cache.DeleteRoute(source.Name) // 删除路由
 		}
 		delete(Cache.Controllers, source.Name) // 删除缓存
 		delete(Cache.Modules, "./controller/"+source.Name)
+	case "filter":
+		Cache.InitFilters() // 重建过滤器
+		delete(Cache.Modules, "./filter/"+source.Name)
 	case "crontab":
-		id, ok := Cache.Crontabs[source.Name]
-		if !ok && source.Active {
-			RunCrontabs(source.Name) // 启动 crontab
-		}
-		if ok && !source.Active {
-			Crontab.Remove(id)                  // // 停止 crontab
-			delete(Cache.Crontabs, source.Name) // 删除缓存
+		if source.Active {
+			RunCrontabs(source.Name) // 启动 crontab，已启动的任务不会被重复添加
+		} else {
+			StopCrontab(source.Name) // 停止 crontab
 		}
 		delete(Cache.Modules, "./crontab/"+source.Name)
 	case "daemon":
 		if source.Active {
-			if Cache.Daemons[source.Name] == nil && status == "true" {
-				RunDaemons(source.Name) // 启动
+			if status == "true" {
+				RunDaemons(source.Name) // 启动，已在运行或等待重启的任务不会被重复启动
 			}
-			if Cache.Daemons[source.Name] != nil && status == "false" {
-				Cache.Daemons[source.Name].Interrupt("Daemon stopped") // 停止，停止后会自动清理缓存，见 RunDaemons 方法的 defer 实现
+			if status == "false" {
+				StopDaemon(source.Name) // 停止，包括等待重启的任务
 			}
 		}
 		delete(Cache.Modules, "./daemon/"+source.Name)
 	}
+}
+
+func handleSourceRollback(r *http.Request) error {
+	var history model.SourceHistory
+	if err := util.UnmarshalWithIoReader(r.Body, &history); err != nil {
+		return err
+	}
+	if history.Name == "" {
+		return errors.New("name is required")
+	}
+	if history.Type == "" {
+		return errors.New("type is required")
+	}
+
+	// 查询指定的历史版本
+	var sourceMap string
+	if err := Db.QueryRow("select content, compiled, source_map from source_history where name = ? and type = ? and revision = ?", history.Name, history.Type, history.Revision).Scan(&history.Content, &history.Compiled, &sourceMap); err != nil {
+		return errors.New("revision does not existed")
+	}
+
+	// 回滚
+	res, err := Db.Exec("update source set content = ?, compiled = ?, source_map = ?, last_modified_date = datetime('now', 'localtime') where name = ? and type = ?", history.Content, history.Compiled, sourceMap, history.Name, history.Type)
+	if err != nil {
+		return err
+	}
+	if count, _ := res.RowsAffected(); count == 0 {
+		return errors.New("source does not existed")
+	}
+
+	// 回滚后的源码作为一个新的版本记录
+	if err := saveSourceHistory(history.Name, history.Type, getAuthor(r)); err != nil {
+		return err
+	}
+
+	// 查询回滚后的记录
+	source, err := getSource(history.Name, history.Type)
+	if err != nil {
+		return err
+	}
+
+	refreshSource(source, nil)
+
+	// 重新启动正在运行的守护任务，使回滚后的源码生效
+	if _, running := GetDaemonState(source.Name); source.Type == "daemon" && source.Active && running {
+		StopDaemon(source.Name)
+		RunDaemons(source.Name)
+	}
 
 	return nil
 }
@@ -307,7 +477,7 @@ func handleSourceGet(w http.ResponseWriter, r *http.Request) (interface{}, bool,
 	}
 
 	// 分页查询，默认查询所有字段
-	columns := "rowid, name, type, lang, content, compiled, active, method, url, cron, tag, last_modified_date"
+	columns := "rowid, name, type, lang, content, compiled, active, method, url, cron, tag, priority, timeout, body_limit, concurrency, restart, max_restarts, overlap, last_modified_date"
 	if p.Has("content") { // 不返回 compiled 字段，用于编辑器查询源码
 		columns = strings.Replace(columns, ", compiled", ", '' compiled", 1)
 	}
@@ -322,9 +492,15 @@ func handleSourceGet(w http.ResponseWriter, r *http.Request) (interface{}, bool,
 	defer rows.Close()
 	for rows.Next() {
 		source := model.Source{}
-		rows.Scan(&source.Id, &source.Name, &source.Type, &source.Lang, &source.Content, &source.Compiled, &source.Active, &source.Method, &source.Url, &source.Cron, &source.Tag, &source.LastModifiedDate)
-		if source.Type == "daemon" { // 如果是 daemon，写入状态
-			source.Status = fmt.Sprintf("%v", Cache.Daemons[source.Name] != nil)
+		rows.Scan(&source.Id, &source.Name, &source.Type, &source.Lang, &source.Content, &source.Compiled, &source.Active, &source.Method, &source.Url, &source.Cron, &source.Tag, &source.Priority, &source.Timeout, &source.BodyLimit, &source.Concurrency, &source.Restart, &source.MaxRestarts, &source.Overlap, &source.LastModifiedDate)
+		if source.Type == "daemon" { // 如果是 daemon，写入状态和监管信息
+			state, running := GetDaemonState(source.Name)
+			source.Status = fmt.Sprintf("%v", running)
+			source.Restarts, source.LastExitReason, source.LastError = state.Restarts, state.LastExitReason, state.LastError
+			if !state.LastExitDate.IsZero() {
+				t := util.Time(state.LastExitDate)
+				source.LastExitDate = &t
+			}
 		}
 		data.Sources = append(data.Sources, source)
 	}
@@ -343,6 +519,151 @@ func handleSourceGet(w http.ResponseWriter, r *http.Request) (interface{}, bool,
 	return data, false, err
 }
 
+// 手动触发执行 crontab
+func handleSourceTrigger(r *http.Request) (interface{}, error) {
+	var source model.Source
+	if err := util.UnmarshalWithIoReader(r.Body, &source); err != nil {
+		return nil, err
+	}
+	if source.Name == "" {
+		return nil, errors.New("name is required")
+	}
+	if source.Type != "crontab" {
+		return nil, errors.New("type must be crontab")
+	}
+
+	id, err := TriggerCrontab(source.Name)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"id": id}, nil
+}
+
+// 分页查询 crontab 的执行记录
+func handleSourceRunsGet(r *http.Request) (interface{}, error) {
+	p := &util.QueryParams{Values: r.URL.Query()}
+	name := p.Get("name")
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+	from, size := p.GetIntOrDefault("from", 0), p.GetIntOrDefault("size", 10)
+
+	var data struct {
+		Runs  []model.CrontabRun `json:"runs"`
+		Total int                `json:"total"`
+	}
+	data.Runs = make([]model.CrontabRun, 0, size)
+
+	if err := Db.QueryRow("select count(1) from crontab_run where name = ?", name).Scan(&data.Total); err != nil {
+		return data, err
+	}
+
+	rows, err := Db.Query("select id, trigger, start_date, end_date, duration, outcome, error from crontab_run where name = ? order by id desc limit ?, ?", name, from, size)
+	if err != nil {
+		return data, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		run := model.CrontabRun{Name: name}
+		if err := rows.Scan(&run.Id, &run.Trigger, &run.StartDate, &run.EndDate, &run.Duration, &run.Outcome, &run.Error); err != nil {
+			return data, err
+		}
+		data.Runs = append(data.Runs, run)
+	}
+
+	return data, nil
+}
+
+func handleSourceHistoryGet(r *http.Request) (interface{}, error) {
+	p := &util.QueryParams{Values: r.URL.Query()}
+	name, stype := p.Get("name"), p.Get("type")
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+	if stype == "" {
+		return nil, errors.New("type is required")
+	}
+
+	// 查询指定的历史版本，包含源码
+	if p.Has("revision") {
+		history := model.SourceHistory{Name: name, Type: stype, Revision: p.GetIntOrDefault("revision", 0)}
+		if err := Db.QueryRow("select content, compiled, author, created_date from source_history where name = ? and type = ? and revision = ?", name, stype, history.Revision).Scan(&history.Content, &history.Compiled, &history.Author, &history.CreatedDate); err != nil {
+			return nil, errors.New("revision does not existed")
+		}
+		return history, nil
+	}
+
+	// 分页查询历史版本列表，不包含源码
+	from, size := p.GetIntOrDefault("from", 0), p.GetIntOrDefault("size", 10)
+
+	var data struct {
+		Histories []model.SourceHistory `json:"histories"`
+		Total     int                   `json:"total"`
+	}
+	data.Histories = make([]model.SourceHistory, 0, size)
+
+	if err := Db.QueryRow("select count(1) from source_history where name = ? and type = ?", name, stype).Scan(&data.Total); err != nil {
+		return data, err
+	}
+
+	rows, err := Db.Query("select revision, author, created_date from source_history where name = ? and type = ? order by revision desc limit ?, ?", name, stype, from, size)
+	if err != nil {
+		return data, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		history := model.SourceHistory{Name: name, Type: stype}
+		if err := rows.Scan(&history.Revision, &history.Author, &history.CreatedDate); err != nil {
+			return data, err
+		}
+		data.Histories = append(data.Histories, history)
+	}
+
+	return data, nil
+}
+
+func handleSourceDiffGet(r *http.Request) (interface{}, error) {
+	p := &util.QueryParams{Values: r.URL.Query()}
+	name, stype := p.Get("name"), p.Get("type")
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+	if stype == "" {
+		return nil, errors.New("type is required")
+	}
+
+	// 默认比较指定版本与其上一个版本
+	revision := p.GetIntOrDefault("revision", 0)
+	base := p.GetIntOrDefault("base", revision-1)
+
+	contents := make([]string, 2)
+	for i, v := range []int{base, revision} {
+		if v == 0 { // 版本号 0 表示空内容，用于与首个版本比较
+			continue
+		}
+		if err := Db.QueryRow("select content from source_history where name = ? and type = ? and revision = ?", name, stype, v).Scan(&contents[i]); err != nil {
+			return nil, errors.New("revision " + strconv.Itoa(v) + " does not existed")
+		}
+	}
+
+	return util.Diff(contents[0], contents[1], name+"@"+strconv.Itoa(base), name+"@"+strconv.Itoa(revision)), nil
+}
+
+// 记录 source 当前的源码为一个新的历史版本
+func saveSourceHistory(name string, stype string, author string) error {
+	_, err := Db.Exec(`
+		insert into source_history (name, type, revision, content, compiled, source_map, author)
+		select name, type, (select coalesce(max(revision), 0) + 1 from source_history h where h.name = s.name and h.type = s.type), content, compiled, source_map, ?
+		from source s where name = ? and type = ?
+	`, author, name, stype)
+	return err
+}
+
+// 获取当前请求的用户名，用于记录历史版本的作者
+func getAuthor(r *http.Request) string {
+	return (&util.DigestAuth{}).GetUsername(r.Header.Get("Authorization"))
+}
+
 func handleSourceEval(w http.ResponseWriter, r *http.Request) {
 	script, err := util.StringWithIoReader(r.Body)
 	if err != nil {
@@ -351,10 +672,8 @@ func handleSourceEval(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 获取 vm 实例
-	var worker *Worker
-	select {
-	case worker = <-WorkerPool.Channels:
-	default:
+	worker, err := WorkerPool.Acquire(time.Duration(config.WaitTimeout) * time.Millisecond)
+	if err != nil {
 		Error(w, http.StatusServiceUnavailable)
 		return
 	}
@@ -363,11 +682,13 @@ func handleSourceEval(w http.ResponseWriter, r *http.Request) {
 			Error(w, x)
 		}
 		worker.Reset()
-		WorkerPool.Channels <- worker
+		WorkerPool.Release(worker)
 	}()
+	worker.SetTask("eval", "", r.URL.Path, "")
 
-	// 允许最大执行的时间为 60 秒
-	timer := time.AfterFunc(60*time.Second, func() {
+	// 允许最大执行的时间，默认为 60 秒，可通过参数 timeout 指定，单位毫秒
+	timeout := (&util.QueryParams{Values: r.URL.Query()}).GetIntOrDefault("timeout", config.Timeout)
+	timer := time.AfterFunc(time.Duration(timeout)*time.Millisecond, func() {
 		worker.Interrupt("service executed timeout")
 	})
 	defer timer.Stop()
@@ -383,18 +704,26 @@ func handleSourceEval(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
-	// 编译
-	entry, _ := worker.Runtime().RunString(strings.Join([]string{
+	// 编译，由浏览器编译的代码内联了源映射，异常的位置按源映射还原为编辑器中的位置
+	script, sourceMap := util.SplitInlineSourceMap(script)
+	program, err := util.CompileWithSourceMap("eval", strings.Join([]string{
 		"(function () {",
 		"const console = { __logs__: [], log: function(...args) { this.__logs__.push(['log', new Date(), ...args]) }, };",
-		script,
-		";return { logs: console.__logs__, };",
-		"})",
-	}, "\n"))
+		"",
+	}, "\n"), script, "\n;return { logs: console.__logs__, };\n})", sourceMap)
+	if err != nil {
+		Error(w, err)
+		return
+	}
+	entry, err := worker.Runtime().RunProgram(program)
+	if err != nil {
+		Error(w, err)
+		return
+	}
 	function, _ := goja.AssertFunction(entry)
 
 	// 执行
-	value, err := worker.EventLoop().Run(func() (goja.Value, error) {
+	value, err := worker.RunFunc(func() (goja.Value, error) {
 		return function(nil)
 	})
 
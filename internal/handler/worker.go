@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	. "cube/internal"
+	"cube/internal/util"
+)
+
+func HandleWorker(w http.ResponseWriter, r *http.Request) {
+	var (
+		data interface{}
+		err  error
+	)
+	switch r.Method {
+	case http.MethodGet:
+		data = handleWorkerGet()
+	case http.MethodDelete:
+		err = handleWorkerDelete(r)
+	default:
+		Error(w, http.StatusMethodNotAllowed)
+		return
+	}
+	if err != nil {
+		Error(w, err)
+		return
+	}
+	Success(w, data)
+}
+
+func handleWorkerGet() interface{} {
+	type worker struct {
+		Id         int    `json:"id"`
+		State      string `json:"state"` // 状态：idle、busy
+		Executions int    `json:"executions"`
+		*WorkerTask
+		Elapsed int64 `json:"elapsed,omitempty"` // 已执行的时长，单位毫秒
+	}
+
+	var data struct {
+		Stats   WorkerPoolStats `json:"stats"`
+		Workers []worker        `json:"workers"`
+	}
+	data.Stats = WorkerPool.Stats()
+	data.Workers = make([]worker, 0)
+	for _, w := range WorkerPool.Workers() {
+		v := worker{Id: w.Id(), State: "idle", Executions: w.Executions()}
+		if t := w.Task(); t != nil {
+			v.State, v.WorkerTask, v.Elapsed = "busy", t, time.Since(time.Time(t.Start)).Milliseconds()
+		}
+		data.Workers = append(data.Workers, v)
+	}
+	return data
+}
+
+func handleWorkerDelete(r *http.Request) error {
+	id := (&util.QueryParams{Values: r.URL.Query()}).GetIntOrDefault("id", -1)
+
+	worker := WorkerPool.Get(id)
+	if worker == nil {
+		return errors.New("worker does not existed")
+	}
+	if !worker.InterruptTask("worker interrupted by administrator") {
+		return errors.New("worker is idle")
+	}
+	return nil
+}
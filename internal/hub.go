@@ -0,0 +1,41 @@
+package internal
+
+import (
+	"time"
+
+	"cube/internal/config"
+	m "cube/internal/module"
+	"cube/internal/util"
+)
+
+func init() {
+	m.MyHub.Dispatch = dispatchHubEvent
+}
+
+// 在按需获取的 vm 实例中执行 hub 连接的事件处理模块，执行完成后立即归还实例
+func dispatchHubEvent(handler string, event map[string]interface{}) {
+	worker, err := WorkerPool.Acquire(time.Duration(config.WaitTimeout) * time.Millisecond)
+	if err != nil {
+		LogWithError(err, nil)
+		return
+	}
+	defer func() {
+		worker.Reset()
+		WorkerPool.Release(worker)
+	}()
+
+	worker.SetTask("hub", handler, "", "")
+
+	timer := time.AfterFunc(time.Duration(config.Timeout)*time.Millisecond, func() {
+		worker.Interrupt("hub handler executed timeout")
+	})
+	defer timer.Stop()
+
+	value, err := worker.Run(worker.Runtime().ToValue(handler), worker.Runtime().ToValue(event))
+	if err == nil {
+		_, err = util.ExportGojaValue(value) // 获取异步方法的执行结果
+	}
+	if err != nil {
+		LogWithError(err, worker)
+	}
+}
@@ -0,0 +1,130 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"cube/internal/config"
+	"cube/internal/util"
+)
+
+// 轮询并执行到期的任务，任务由 $native("jobs") 添加
+func RunJobs() {
+	// 进程退出时正在执行的任务，重新置为待执行
+	if _, err := Db.Exec("update job set status = 'pending', attempts = attempts - 1 where status = 'running'"); err != nil {
+		panic(err)
+	}
+
+	slots := make(chan struct{}, config.JobConcurrency) // 限制同时执行的任务数，防止占满实例池导致请求无法获取实例
+	for range time.Tick(time.Second) {
+		if len(slots) == cap(slots) {
+			continue
+		}
+
+		ids, err := claimJobs(cap(slots) - len(slots))
+		if err != nil {
+			LogWithError(err, nil)
+			continue
+		}
+		for _, id := range ids {
+			slots <- struct{}{}
+			go func() {
+				defer func() {
+					<-slots
+				}()
+				runJob(id)
+			}()
+		}
+	}
+}
+
+// 认领到期的任务，将其状态修改为执行中
+func claimJobs(limit int) ([]int64, error) {
+	rows, err := Db.Query("select id from job where status = 'pending' and run_at <= strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime') order by run_at limit ?", limit)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		rows.Scan(&id)
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	claimed := ids[:0]
+	for _, id := range ids {
+		res, err := Db.Exec("update job set status = 'running', attempts = attempts + 1, updated_date = datetime('now', 'localtime') where id = ? and status = 'pending'", id)
+		if err != nil {
+			return claimed, err
+		}
+		if count, _ := res.RowsAffected(); count > 0 {
+			claimed = append(claimed, id)
+		}
+	}
+	return claimed, nil
+}
+
+func runJob(id int64) {
+	var (
+		module, payload       string
+		attempts, maxAttempts int
+		backoff               int
+	)
+	if err := Db.QueryRow("select module, payload, attempts, max_attempts, backoff from job where id = ?", id).Scan(&module, &payload, &attempts, &maxAttempts, &backoff); err != nil {
+		LogWithError(err, nil)
+		return
+	}
+
+	err := executeJob(module, payload)
+	if errors.Is(err, ErrWorkerPoolQueueFull) || errors.Is(err, ErrWorkerPoolWaitTimeout) { // 无可用实例，不计入执行次数，稍后重新执行
+		Db.Exec("update job set status = 'pending', attempts = attempts - 1, updated_date = datetime('now', 'localtime') where id = ?", id)
+		return
+	}
+
+	switch {
+	case err == nil:
+		_, err = Db.Exec("update job set status = 'succeeded', last_error = '', updated_date = datetime('now', 'localtime') where id = ?", id)
+	case attempts < maxAttempts: // 按指数退避的间隔重试
+		delay := float64(backoff) * float64(int(1)<<min(attempts-1, 20)) / 1000
+		_, err = Db.Exec("update job set status = 'pending', last_error = ?, run_at = strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime', ?), updated_date = datetime('now', 'localtime') where id = ?", err.Error(), fmt.Sprintf("+%.3f seconds", delay), id)
+	default: // 重试次数用尽，进入死信状态
+		_, err = Db.Exec("update job set status = 'dead', last_error = ?, updated_date = datetime('now', 'localtime') where id = ?", err.Error(), id)
+	}
+	if err != nil {
+		LogWithError(err, nil)
+	}
+}
+
+func executeJob(module string, payload string) error {
+	worker, err := WorkerPool.Acquire(time.Duration(config.WaitTimeout) * time.Millisecond)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		worker.Reset()
+		WorkerPool.Release(worker)
+	}()
+
+	worker.SetTask("job", module, "", "")
+
+	timer := time.AfterFunc(time.Duration(config.Timeout)*time.Millisecond, func() {
+		worker.Interrupt("job executed timeout")
+	})
+	defer timer.Stop()
+
+	var data interface{}
+	if err := json.Unmarshal([]byte(payload), &data); err != nil {
+		return err
+	}
+	value, err := worker.Run(worker.Runtime().ToValue(module), worker.Runtime().ToValue(data))
+	if err == nil {
+		_, err = util.ExportGojaValue(value) // 获取异步方法的执行结果
+	}
+	if err != nil {
+		LogWithError(err, worker)
+	}
+	return err
+}
@@ -1,20 +1,44 @@
 package internal
 
 import (
+	"encoding/json"
 	"log"
-	"os"
-	"time"
+
+	"cube/internal/config"
+	"cube/internal/util"
 )
 
+const LogFile = "./cube.log"
+
+var logFile *util.RotatingFile
+
 func InitLog() {
-	fd, err := os.OpenFile("./cube.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	var err error
+	logFile, err = util.OpenRotatingFile(LogFile, int64(config.LogSize)<<20, config.LogRotate, config.LogKeep)
 	if err != nil {
 		panic(err)
 	}
-	log.SetOutput(fd)
-	log.SetFlags(log.Lmsgprefix) // 去除日志每行开头自带的时间戳前缀
+	util.MyLogger.SetOutput(logFile)
+	if err := util.MyLogger.SetLevel("", config.LogLevel); err != nil {
+		panic(err)
+	}
+	log.SetOutput(util.LogWriter("warn")) // 标准库 log 包的输出（如 net/http 的连接异常）同样以 JSON 格式写入
+	log.SetFlags(0)
+}
+
+// 日志文件，包括已轮转的文件，按时间排序
+func LogFiles() []string {
+	return append(logFile.Rotated(), LogFile)
 }
 
 func LogWithError(err error, worker *Worker) {
-	log.Println(append(append([]interface{}{"\033[0;31m" + time.Now().Format("2006-01-02 15:04:05.000"), worker.Id(), "Error"}, err), "\033[m")...)
+	e := &util.LogEntry{Level: "error", Worker: -1} // 非 vm 实例内的异常
+	if worker != nil {
+		e = worker.NewLogEntry("error")
+	}
+	e.Message = err.Error()
+	if stack := util.StackFrames(err); len(stack) > 0 { // 按源映射还原为源码位置的调用栈
+		e.Fields, _ = json.Marshal(map[string]interface{}{"stack": stack})
+	}
+	util.MyLogger.Write(e)
 }
@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"os"
+	"runtime"
+	"time"
+
+	"cube/internal/util"
+
+	"github.com/shirou/gopsutil/process"
+)
+
+// 内置的指标，其中工作池、守护任务和运行时的指标在输出时按当前状态生成
+var (
+	RequestsTotal   = util.MustMetric(util.MyMetrics.Counter("cube_http_requests_total", "Count of controller requests by status code.", "controller", "method", "status"))
+	RequestDuration = util.MustMetric(util.MyMetrics.Histogram("cube_http_request_duration_seconds", "Latency of controller requests in seconds.", nil, "controller"))
+	crontabRuns     = util.MustMetric(util.MyMetrics.Counter("cube_crontab_runs_total", "Count of crontab runs by outcome: success, failed or skipped.", "crontab", "outcome"))
+	crontabDuration = util.MustMetric(util.MyMetrics.Histogram("cube_crontab_duration_seconds", "Duration of crontab runs in seconds.", nil, "crontab"))
+	moduleCache     = util.MustMetric(util.MyMetrics.Counter("cube_module_cache_total", "Count of module loads by compile cache result: hit or miss.", "result"))
+
+	workers       = util.MustMetric(util.MyMetrics.Gauge("cube_workers", "Count of virtual machines by state: busy or idle.", "state"))
+	workerQueue   = util.MustMetric(util.MyMetrics.Gauge("cube_worker_queue", "Count of requests waiting for a virtual machine."))
+	workerWaits   = util.MustMetric(util.MyMetrics.Counter("cube_worker_waits_total", "Count of requests that have waited for a virtual machine."))
+	workerWaited  = util.MustMetric(util.MyMetrics.Counter("cube_worker_wait_seconds_total", "Total time in seconds of waiting for a virtual machine."))
+	daemonUp      = util.MustMetric(util.MyMetrics.Gauge("cube_daemon_up", "Whether the daemon is running (1) or not (0).", "daemon"))
+	daemonRestart = util.MustMetric(util.MyMetrics.Counter("cube_daemon_restarts_total", "Count of daemon restarts since it was started.", "daemon"))
+
+	goroutines   = util.MustMetric(util.MyMetrics.Gauge("go_goroutines", "Number of goroutines that currently exist."))
+	heapAlloc    = util.MustMetric(util.MyMetrics.Gauge("go_memstats_heap_alloc_bytes", "Number of heap bytes allocated and still in use."))
+	heapSys      = util.MustMetric(util.MyMetrics.Gauge("go_memstats_sys_bytes", "Number of bytes obtained from system."))
+	gcCycles     = util.MustMetric(util.MyMetrics.Counter("go_gc_cycles_total", "Count of completed GC cycles."))
+	gcPause      = util.MustMetric(util.MyMetrics.Counter("go_gc_pause_seconds_total", "Total GC pause time in seconds."))
+	residentSize = util.MustMetric(util.MyMetrics.Gauge("process_resident_memory_bytes", "Resident memory size in bytes."))
+	cpuSeconds   = util.MustMetric(util.MyMetrics.Counter("process_cpu_seconds_total", "Total user and system CPU time spent in seconds."))
+	startTime    = util.MustMetric(util.MyMetrics.Gauge("process_start_time_seconds", "Start time of the process since unix epoch in seconds."))
+)
+
+var started = time.Now()
+
+// 按当前状态更新工作池、守护任务和运行时的指标，在输出指标前调用
+func CollectMetrics() {
+	s := WorkerPool.Stats()
+	workers.Set(float64(s.Size-s.Idle), "busy")
+	workers.Set(float64(s.Idle), "idle")
+	workerQueue.Set(float64(s.Queue))
+	workerWaits.Set(float64(s.Waited))
+	workerWaited.Set(s.WaitTime.Seconds())
+
+	daemonUp.Reset()
+	daemonRestart.Reset()
+	daemons.Lock()
+	for name, state := range daemons.states {
+		up := 0.0
+		if Cache.Daemons[name] != nil {
+			up = 1
+		}
+		daemonUp.Set(up, name)
+		daemonRestart.Set(float64(state.Restarts), name)
+	}
+	daemons.Unlock()
+
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	goroutines.Set(float64(runtime.NumGoroutine()))
+	heapAlloc.Set(float64(m.HeapAlloc))
+	heapSys.Set(float64(m.Sys))
+	gcCycles.Set(float64(m.NumGC))
+	gcPause.Set(time.Duration(m.PauseTotalNs).Seconds())
+
+	if p, err := process.NewProcess(int32(os.Getpid())); err == nil {
+		if info, err := p.MemoryInfo(); err == nil {
+			residentSize.Set(float64(info.RSS))
+		}
+		if times, err := p.Times(); err == nil {
+			cpuSeconds.Set(times.User + times.System)
+		}
+	}
+	startTime.Set(float64(started.UnixMilli()) / 1000)
+}
@@ -0,0 +1,16 @@
+package model
+
+import "cube/internal/util"
+
+type Job struct {
+	Id          int64     `json:"id"`
+	Module      string    `json:"module"`
+	Payload     string    `json:"payload"`
+	Status      string    `json:"status"` // pending, running, succeeded, dead
+	Attempts    int       `json:"attempts"`
+	MaxAttempts int       `json:"max_attempts"`
+	RunAt       util.Time `json:"run_at"`
+	LastError   string    `json:"last_error"`
+	CreatedDate util.Time `json:"created_date"`
+	UpdatedDate util.Time `json:"updated_date"`
+}
@@ -3,17 +3,50 @@ package model
 import "cube/internal/util"
 
 type Source struct {
-	Id               int       `json:"rowid"`
-	Name             string    `json:"name"`
-	Type             string    `json:"type"` // module, controller, daemon, crontab, template, resource
-	Lang             string    `json:"lang"` // typescript, html, text, vue
-	Content          string    `json:"content,omitempty"`
-	Compiled         string    `json:"compiled,omitempty"`
-	Active           bool      `json:"active"`
-	Method           string    `json:"method"`
-	Url              string    `json:"url"`
-	Cron             string    `json:"cron"`
-	Tag              string    `json:"tag"`
-	LastModifiedDate util.Time `json:"last_modified_date"`
-	Status           string    `json:"status"`
+	Id               int        `json:"rowid"`
+	Name             string     `json:"name"`
+	Type             string     `json:"type"` // module, controller, daemon, crontab, template, resource, filter
+	Lang             string     `json:"lang"` // typescript, html, text, vue
+	Content          string     `json:"content,omitempty"`
+	Compiled         string     `json:"compiled,omitempty"`
+	SourceMap        string     `json:"-"` // 编译时生成的源映射，不对外返回
+	Active           bool       `json:"active"`
+	Method           string     `json:"method"`
+	Url              string     `json:"url"`
+	Cron             string     `json:"cron"`
+	Tag              string     `json:"tag"`
+	Priority         int        `json:"priority"`     // filter 的执行顺序，值越小越先执行
+	Timeout          int        `json:"timeout"`      // controller 的最大执行时间，单位毫秒，0 表示使用默认值
+	BodyLimit        int64      `json:"body_limit"`   // controller 的最大请求消息体大小，单位字节，0 表示不限制
+	Concurrency      int        `json:"concurrency"`  // controller 的最大并发执行数，0 表示不限制
+	Restart          string     `json:"restart"`      // daemon 的重启策略：never、on-failure、always，空值等同于 never
+	MaxRestarts      int        `json:"max_restarts"` // daemon 的最大重启次数，0 表示不限制
+	Overlap          string     `json:"overlap"`      // crontab 上一次执行未结束时的策略：allow、skip、queue，空值等同于 allow
+	LastModifiedDate util.Time  `json:"last_modified_date"`
+	Status           string     `json:"status"`
+	Restarts         int        `json:"restarts,omitempty"`         // daemon 自启动以来的重启次数
+	LastExitReason   string     `json:"last_exit_reason,omitempty"` // daemon 最近一次退出的原因：returned、failed、stopped
+	LastError        string     `json:"last_error,omitempty"`       // daemon 最近一次失败的异常信息
+	LastExitDate     *util.Time `json:"last_exit_date,omitempty"`
+}
+
+type SourceHistory struct {
+	Name        string    `json:"name"`
+	Type        string    `json:"type"`
+	Revision    int       `json:"revision"`
+	Content     string    `json:"content,omitempty"`
+	Compiled    string    `json:"compiled,omitempty"`
+	Author      string    `json:"author"`
+	CreatedDate util.Time `json:"created_date"`
+}
+
+type CrontabRun struct {
+	Id        int64      `json:"id"`
+	Name      string     `json:"name"`
+	Trigger   string     `json:"trigger"` // 触发方式：schedule、manual
+	StartDate util.Time  `json:"start_date"`
+	EndDate   *util.Time `json:"end_date"`
+	Duration  int64      `json:"duration"` // 执行时长，单位毫秒
+	Outcome   string     `json:"outcome"`  // 执行结果：running、success、failed、skipped
+	Error     string     `json:"error"`
 }
@@ -5,8 +5,14 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
+
+	"cube/internal/builtin"
+	"cube/internal/util"
 
 	"github.com/dop251/goja"
+	"github.com/shopspring/decimal"
 )
 
 func init() {
@@ -15,10 +21,34 @@ func init() {
 	})
 }
 
+var dbDuration = util.MustMetric(util.MyMetrics.Histogram("cube_db_query_duration_seconds", "Duration of statements executed by the db module in seconds.", nil, "operation"))
+
+// 统计语句的执行时长并记录 span，包括查询结果的读取，如 defer traceStatement(worker, "query", stmt)(&err)
+func traceStatement(worker Worker, operation string, stmt string) func(err *error) {
+	start, span := time.Now(), worker.StartSpan("db "+operation, "client")
+	span.SetAttribute("db.system", "sqlite")
+	span.SetAttribute("db.statement", stmt)
+	return func(err *error) {
+		dbDuration.Observe(time.Since(start).Seconds(), operation)
+		span.SetError(*err)
+		span.Finish()
+	}
+}
+
 func ExportDatabaseRows(rows *sql.Rows) ([]interface{}, error) {
 	defer rows.Close()
 
 	columns, _ := rows.Columns()
+
+	// 声明类型为 DECIMAL、NUMERIC 或 MONEY 的列转换为 Decimal，在 sqlite 中可声明为 "DECIMAL TEXT"（TEXT 亲和性）以保存完整的精度
+	decimals := make([]bool, len(columns))
+	if types, err := rows.ColumnTypes(); err == nil {
+		for index, t := range types {
+			name := strings.ToUpper(t.DatabaseTypeName())
+			decimals[index] = strings.Contains(name, "DECIMAL") || strings.Contains(name, "NUMERIC") || strings.Contains(name, "MONEY")
+		}
+	}
+
 	buf := make([]interface{}, len(columns))
 	for index := range columns {
 		var a interface{}
@@ -33,6 +63,9 @@ func ExportDatabaseRows(rows *sql.Rows) ([]interface{}, error) {
 		record := make(map[string]interface{})
 		for index, data := range buf {
 			record[columns[index]] = *data.(*interface{})
+			if decimals[index] {
+				record[columns[index]] = exportDecimal(record[columns[index]])
+			}
 		}
 		records = append(records, record)
 	}
@@ -40,11 +73,34 @@ func ExportDatabaseRows(rows *sql.Rows) ([]interface{}, error) {
 	return records, rows.Err()
 }
 
+func exportDecimal(value interface{}) interface{} {
+	var d decimal.Decimal
+	var err error
+	switch v := value.(type) {
+	case int64:
+		d = decimal.NewFromInt(v)
+	case float64: // sqlite 中 NUMERIC 亲和性的列以 REAL 保存小数，这里按最短的十进制表示还原
+		d = decimal.NewFromFloat(v)
+	case string:
+		d, err = decimal.NewFromString(v)
+	case []byte:
+		d, err = decimal.NewFromString(string(v))
+	default:
+		return value
+	}
+	if err != nil {
+		return value
+	}
+	return builtin.NewDecimal(d)
+}
+
 type DatabaseTransaction struct {
-	t *sql.Tx
+	worker Worker
+	t      *sql.Tx
 }
 
-func (d *DatabaseTransaction) Query(stmt string, params ...interface{}) ([]interface{}, error) {
+func (d *DatabaseTransaction) Query(stmt string, params ...interface{}) (records []interface{}, err error) {
+	defer traceStatement(d.worker, "query", stmt)(&err)
 	rows, err := d.t.Query(stmt, params...)
 	if err != nil {
 		return nil, err
@@ -52,7 +108,8 @@ func (d *DatabaseTransaction) Query(stmt string, params ...interface{}) ([]inter
 	return ExportDatabaseRows(rows)
 }
 
-func (d *DatabaseTransaction) Exec(stmt string, params ...interface{}) (int64, error) {
+func (d *DatabaseTransaction) Exec(stmt string, params ...interface{}) (affected int64, err error) {
+	defer traceStatement(d.worker, "exec", stmt)(&err)
 	res, err := d.t.Exec(stmt, params...)
 	if err != nil {
 		return 0, err
@@ -73,7 +130,8 @@ type DatabaseClient struct {
 	db     Db
 }
 
-func (d *DatabaseClient) Query(stmt string, params ...interface{}) ([]interface{}, error) {
+func (d *DatabaseClient) Query(stmt string, params ...interface{}) (records []interface{}, err error) {
+	defer traceStatement(d.worker, "query", stmt)(&err)
 	rows, err := d.db.Query(stmt, params...)
 	if err != nil {
 		return nil, err
@@ -81,7 +139,8 @@ func (d *DatabaseClient) Query(stmt string, params ...interface{}) ([]interface{
 	return ExportDatabaseRows(rows)
 }
 
-func (d *DatabaseClient) Exec(stmt string, params ...interface{}) (int64, error) {
+func (d *DatabaseClient) Exec(stmt string, params ...interface{}) (affected int64, err error) {
+	defer traceStatement(d.worker, "exec", stmt)(&err)
 	res, err := d.db.Exec(stmt, params...)
 	if err != nil {
 		return 0, err
@@ -113,7 +172,7 @@ func (d *DatabaseClient) Transaction(fn goja.Callable, isolation sql.IsolationLe
 		tx.Commit()
 	}()
 
-	_, err = fn(nil, d.worker.Runtime().ToValue(&DatabaseTransaction{tx}))
+	_, err = fn(nil, d.worker.Runtime().ToValue(&DatabaseTransaction{d.worker, tx}))
 
 	return
 }
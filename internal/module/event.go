@@ -122,7 +122,14 @@ func (c *EventClient) On(call goja.FunctionCall) goja.Value { // 见 goja.Runtim
 
 	runtime := c.worker.Runtime()
 
-	s := c.CreateSubscriber(topic)
+	return runtime.ToValue(Listen(c.worker, topic, func(data interface{}) {
+		fn(nil, runtime.ToValue(data))
+	}))
+}
+
+// 订阅主题，收到数据时在事件循环中调用 fn，用于在 go 中转发事件，如转发到 SSE 事件流
+func Listen(worker Worker, topic string, fn func(data interface{})) *EventSubscriber {
+	s := (&EventClient{worker}).CreateSubscriber(topic)
 
 	go func() {
 	L:
@@ -136,11 +143,11 @@ func (c *EventClient) On(call goja.FunctionCall) goja.Value { // 见 goja.Runtim
 					break L
 				}
 				s.trigger.AddTask(func() {
-					fn(nil, runtime.ToValue(data))
+					fn(data)
 				})
 			}
 		}
 	}()
 
-	return runtime.ToValue(s)
+	return s
 }
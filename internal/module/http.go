@@ -20,7 +20,7 @@ import (
 func init() {
 	register("http", func(worker Worker, db Db) interface{} {
 		return func(options *HttpOptions) (*HttpClient, error) {
-			httpc := &HttpClient{c: &http.Client{}}
+			httpc := &HttpClient{c: &http.Client{}, worker: worker}
 
 			if options == nil {
 				return httpc, nil
@@ -108,7 +108,8 @@ type FormData struct {
 }
 
 type HttpClient struct {
-	c *http.Client
+	c      *http.Client
+	worker Worker
 }
 
 func (h *HttpClient) Request(method string, url string, header map[string]string, input interface{}) (response interface{}, err error) {
@@ -142,11 +143,23 @@ func (h *HttpClient) Request(method string, url string, header map[string]string
 		req.Header.Set("Content-Type", contentType)
 	}
 
+	span := h.worker.StartSpan("http "+req.Method, "client")
+	defer func() {
+		span.SetError(err)
+		span.Finish()
+	}()
+	span.SetAttribute("http.method", req.Method)
+	span.SetAttribute("http.url", url)
+	if span != nil && req.Header.Get("traceparent") == "" { // 向下游传递调用链路
+		req.Header.Set("traceparent", span.Traceparent())
+	}
+
 	resp, err := h.c.Do(req)
 	if err != nil {
 		return
 	}
 	defer resp.Body.Close()
+	span.SetAttribute("http.status_code", resp.StatusCode)
 
 	output, err := io.ReadAll(resp.Body)
 	if err != nil {
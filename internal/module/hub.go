@@ -0,0 +1,405 @@
+package module
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"cube/internal/builtin"
+	"cube/internal/util"
+
+	"github.com/dop251/goja"
+	"github.com/gorilla/websocket"
+)
+
+func init() {
+	register("hub", func(worker Worker, db Db) interface{} {
+		return &HubClient{worker}
+	})
+}
+
+//#region 连接
+
+const hubQueueSize = 256 // 每个连接待发送消息队列的长度，队列已满的慢速连接将被断开
+
+type hubMessage struct {
+	messageType int
+	data        []byte
+}
+
+type hubConnection struct {
+	id       string
+	socket   *builtin.WebSocket
+	metadata interface{}
+	handler  string // 处理消息的模块，如 ./chat，为空时忽略收到的消息
+	rooms    map[string]struct{}
+	joinedAt time.Time
+	queue    chan hubMessage // 待发送的消息，由单独的协程写入连接，避免广播方被慢速连接阻塞
+	stop     chan struct{}
+}
+
+func (c *hubConnection) write() {
+	for {
+		select {
+		case <-c.stop:
+			return
+		case m := <-c.queue:
+			if err := c.socket.Write(m.messageType, m.data); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// 读取消息并交由处理模块执行，同一连接的消息按顺序处理，连接断开后移除连接并触发 close 事件
+func (c *hubConnection) read(h *Hub) {
+	for {
+		messageType, data, err := c.socket.ReadMessage()
+		if err != nil {
+			c.socket.Release()
+			code, reason, _ := builtin.CloseStatus(err)
+			h.dispatch(c, map[string]interface{}{"type": "close", "code": code, "reason": reason})
+			return
+		}
+		event := map[string]interface{}{"type": "message", "binary": messageType == websocket.BinaryMessage}
+		if messageType == websocket.TextMessage {
+			event["data"] = string(data)
+		} else {
+			event["data"] = builtin.Buffer(data)
+		}
+		h.dispatch(c, event)
+	}
+}
+
+func (c *hubConnection) push(m hubMessage) bool {
+	select {
+	case <-c.stop:
+		return false
+	default:
+	}
+	select {
+	case c.queue <- m:
+		return true
+	default:
+		c.socket.Terminate(websocket.ClosePolicyViolation, "slow consumer")
+		return false
+	}
+}
+
+//#endregion
+
+//#region 连接中心
+
+var MyHub = Hub{
+	rooms:       make(map[string]map[string]*hubConnection),
+	connections: make(map[string]*hubConnection),
+	sockets:     make(map[*builtin.WebSocket]*hubConnection),
+}
+
+// 所有 vm 实例共享的连接中心，连接加入后由连接中心持有并在协程中读写，加入连接的 vm 实例随即可被归还，
+// 收到的消息在按需获取的 vm 实例中交由处理模块执行，可在任意 vm 实例（如守护进程、定时任务）中向连接发送消息
+type Hub struct {
+	sync.RWMutex
+	rooms       map[string]map[string]*hubConnection // 房间名称 -> 连接 id -> 连接
+	connections map[string]*hubConnection
+	sockets     map[*builtin.WebSocket]*hubConnection
+	Dispatch    func(handler string, event map[string]interface{}) // 在 vm 实例中执行 require(handler).default(event)，由实例池所在的包设置
+}
+
+func (h *Hub) join(socket *builtin.WebSocket, room string, metadata interface{}, handler string) string {
+	h.Lock()
+
+	c, found := h.sockets[socket]
+	if !found {
+		c = &hubConnection{
+			id:       CreateULID(),
+			socket:   socket,
+			metadata: metadata,
+			handler:  handler,
+			rooms:    make(map[string]struct{}),
+			joinedAt: time.Now(),
+			queue:    make(chan hubMessage, hubQueueSize),
+			stop:     make(chan struct{}),
+		}
+		h.connections[c.id], h.sockets[socket] = c, c
+		go c.write()
+		go c.read(h)
+	} else {
+		if metadata != nil {
+			c.metadata = metadata
+		}
+		if handler != "" {
+			c.handler = handler
+		}
+	}
+
+	if _, found := h.rooms[room]; !found {
+		h.rooms[room] = make(map[string]*hubConnection)
+	}
+	h.rooms[room][c.id] = c
+	c.rooms[room] = struct{}{}
+
+	h.Unlock()
+
+	if !found {
+		socket.OnRelease(func() { // 连接断开后自动移除，如果连接已断开则立即移除，因此需要在释放锁后注册
+			h.remove(c)
+		})
+	}
+	return c.id
+}
+
+// 将连接的事件交由处理模块执行，事件包含连接的 id 和 metadata
+func (h *Hub) dispatch(c *hubConnection, event map[string]interface{}) {
+	h.RLock()
+	handler, metadata := c.handler, c.metadata
+	h.RUnlock()
+	if handler == "" || h.Dispatch == nil {
+		return
+	}
+	event["id"], event["metadata"] = c.id, metadata
+	h.Dispatch(handler, event)
+}
+
+func (h *Hub) leave(c *hubConnection, room string) {
+	delete(c.rooms, room)
+	if members, found := h.rooms[room]; found {
+		delete(members, c.id)
+		if len(members) == 0 {
+			delete(h.rooms, room)
+		}
+	}
+}
+
+func (h *Hub) remove(c *hubConnection) {
+	h.Lock()
+	defer h.Unlock()
+
+	if _, found := h.connections[c.id]; !found {
+		return
+	}
+	for room := range c.rooms {
+		h.leave(c, room)
+	}
+	delete(h.connections, c.id)
+	delete(h.sockets, c.socket)
+	close(c.stop)
+}
+
+func (h *Hub) get(id string) *hubConnection {
+	h.RLock()
+	defer h.RUnlock()
+	return h.connections[id]
+}
+
+// 房间内的连接，按加入的先后排序
+func (h *Hub) members(room string) []*hubConnection {
+	h.RLock()
+	defer h.RUnlock()
+
+	members := make([]*hubConnection, 0, len(h.rooms[room]))
+	for _, c := range h.rooms[room] {
+		members = append(members, c)
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].id < members[j].id
+	})
+	return members
+}
+
+//#endregion
+
+type HubClient struct {
+	worker Worker
+}
+
+type HubPresence struct {
+	Id       string
+	Metadata interface{}
+	Rooms    []string
+	JoinedAt string
+}
+
+type HubRoom struct {
+	Name string
+	Size int
+}
+
+type HubBroadcastOptions struct {
+	Except []string // 排除的连接 id，如消息的发送者
+}
+
+func (c *HubClient) presence(conn *hubConnection) *HubPresence {
+	MyHub.RLock()
+	defer MyHub.RUnlock()
+
+	rooms := make([]string, 0, len(conn.rooms))
+	for room := range conn.rooms {
+		rooms = append(rooms, room)
+	}
+	sort.Strings(rooms)
+	return &HubPresence{conn.id, conn.metadata, rooms, util.Time(conn.joinedAt).String()}
+}
+
+// 将消息转换为数据帧：字符串为文本帧，字节数组为二进制帧，其他值序列化为 JSON 后作为文本帧
+func (c *HubClient) message(data goja.Value) (hubMessage, error) {
+	if b, ok := builtin.ExportBytes(data); ok {
+		return hubMessage{websocket.BinaryMessage, b}, nil
+	}
+	if s, ok := data.Export().(string); ok {
+		return hubMessage{websocket.TextMessage, []byte(s)}, nil
+	}
+	runtime := c.worker.Runtime()
+	stringify, _ := goja.AssertFunction(runtime.Get("JSON").ToObject(runtime).Get("stringify"))
+	v, err := stringify(nil, data)
+	if err != nil {
+		return hubMessage{}, err
+	}
+	if goja.IsUndefined(v) {
+		return hubMessage{}, errors.New("invalid argument data, cannot be serialized")
+	}
+	return hubMessage{websocket.TextMessage, []byte(v.String())}, nil
+}
+
+// 将连接加入房间，返回连接的 id，同一连接多次加入时 id 不变；加入后连接由连接中心持有，
+// 收到的消息和连接的关闭将在其他 vm 实例中交由 handler 模块执行，即 require(handler).default(event)
+func (c *HubClient) Join(socket goja.Value, room string, metadata goja.Value, handler goja.Value) (string, error) {
+	s := builtin.ExportWebSocket(socket)
+	if s == nil {
+		return "", errors.New("invalid argument socket, not a websocket")
+	}
+	if room == "" {
+		return "", errors.New("invalid argument room, must not be empty")
+	}
+	var m interface{}
+	if metadata != nil && !goja.IsUndefined(metadata) && !goja.IsNull(metadata) {
+		m = metadata.Export()
+	}
+	h := ""
+	if handler != nil && !goja.IsUndefined(handler) && !goja.IsNull(handler) {
+		h = handler.String()
+	}
+	if err := s.Detach(); err != nil {
+		return "", err
+	}
+	return MyHub.join(s, room, m, h), nil
+}
+
+// 离开房间，未指定房间时离开所有房间
+func (c *HubClient) Leave(id string, room goja.Value) {
+	MyHub.Lock()
+	defer MyHub.Unlock()
+
+	conn, found := MyHub.connections[id]
+	if !found {
+		return
+	}
+	if room != nil && !goja.IsUndefined(room) {
+		MyHub.leave(conn, room.String())
+		return
+	}
+	for r := range conn.rooms {
+		MyHub.leave(conn, r)
+	}
+}
+
+// 向房间内的所有连接广播消息，返回消息加入发送队列的连接数
+func (c *HubClient) Broadcast(room string, data goja.Value, options *HubBroadcastOptions) (int, error) {
+	m, err := c.message(data)
+	if err != nil {
+		return 0, err
+	}
+
+	except := make(map[string]struct{})
+	if options != nil {
+		for _, id := range options.Except {
+			except[id] = struct{}{}
+		}
+	}
+
+	count := 0
+	for _, conn := range MyHub.members(room) {
+		if _, found := except[conn.id]; found {
+			continue
+		}
+		if conn.push(m) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// 向指定的连接发送消息，连接不存在时返回 false
+func (c *HubClient) Send(id string, data goja.Value) (bool, error) {
+	conn := MyHub.get(id)
+	if conn == nil {
+		return false, nil
+	}
+	m, err := c.message(data)
+	if err != nil {
+		return false, err
+	}
+	return conn.push(m), nil
+}
+
+// 断开指定的连接，断开后将自动离开所有房间
+func (c *HubClient) Disconnect(id string, code goja.Value, reason goja.Value) bool {
+	conn := MyHub.get(id)
+	if conn == nil {
+		return false
+	}
+	cd, r := websocket.CloseNormalClosure, ""
+	if code != nil && !goja.IsUndefined(code) {
+		cd = int(code.ToInteger())
+	}
+	if reason != nil && !goja.IsUndefined(reason) {
+		r = reason.String()
+	}
+	conn.socket.Terminate(cd, r)
+	return true
+}
+
+func (c *HubClient) Get(id string) *HubPresence {
+	conn := MyHub.get(id)
+	if conn == nil {
+		return nil
+	}
+	return c.presence(conn)
+}
+
+func (c *HubClient) SetMetadata(id string, metadata goja.Value) bool {
+	MyHub.Lock()
+	defer MyHub.Unlock()
+
+	conn, found := MyHub.connections[id]
+	if !found {
+		return false
+	}
+	conn.metadata = metadata.Export()
+	return true
+}
+
+// 房间内的在线列表
+func (c *HubClient) Presence(room string) []*HubPresence {
+	members := MyHub.members(room)
+	presences := make([]*HubPresence, 0, len(members))
+	for _, conn := range members {
+		presences = append(presences, c.presence(conn))
+	}
+	return presences
+}
+
+func (c *HubClient) Rooms() []*HubRoom {
+	MyHub.RLock()
+	defer MyHub.RUnlock()
+
+	rooms := make([]*HubRoom, 0, len(MyHub.rooms))
+	for name, members := range MyHub.rooms {
+		rooms = append(rooms, &HubRoom{name, len(members)})
+	}
+	sort.Slice(rooms, func(i, j int) bool {
+		return rooms[i].Name < rooms[j].Name
+	})
+	return rooms
+}
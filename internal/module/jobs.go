@@ -0,0 +1,76 @@
+package module
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+func init() {
+	register("jobs", func(worker Worker, db Db) interface{} {
+		return &JobClient{db}
+	})
+}
+
+type JobOptions struct {
+	Delay   int // 延迟执行的时间，单位毫秒
+	Retries int // 失败后的最大重试次数，超出后进入 dead 状态
+	Backoff int // 重试的初始间隔，单位毫秒，之后每次重试的间隔翻倍，默认为 1000
+}
+
+type JobClient struct {
+	db Db
+}
+
+// 添加任务，执行时调用 require(module).default(payload)，返回任务 id
+func (j *JobClient) Enqueue(module string, payload interface{}, options *JobOptions) (int64, error) {
+	if module == "" {
+		return 0, errors.New("module is required")
+	}
+	if options == nil {
+		options = &JobOptions{}
+	}
+	if options.Delay < 0 || options.Retries < 0 || options.Backoff < 0 {
+		return 0, errors.New("delay, retries and backoff must not be negative")
+	}
+	if options.Backoff == 0 {
+		options.Backoff = 1000
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := j.db.Exec(
+		"insert into job (module, payload, max_attempts, backoff, run_at) values(?, ?, ?, ?, strftime('%Y-%m-%d %H:%M:%f', 'now', 'localtime', ?))",
+		module, string(data), options.Retries+1, options.Backoff, fmt.Sprintf("+%.3f seconds", float64(options.Delay)/1000), // sqlite 时间函数的修饰符
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// 查询任务
+func (j *JobClient) Get(id int64) (interface{}, error) {
+	rows, err := j.db.Query("select id, module, payload, status, attempts, max_attempts, run_at, last_error, created_date from job where id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	records, err := ExportDatabaseRows(rows)
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+	return records[0], nil
+}
+
+// 取消未开始执行的任务，返回是否取消成功
+func (j *JobClient) Cancel(id int64) (bool, error) {
+	res, err := j.db.Exec("delete from job where id = ? and status = 'pending'", id)
+	if err != nil {
+		return false, err
+	}
+	count, err := res.RowsAffected()
+	return count > 0, err
+}
@@ -0,0 +1,101 @@
+package module
+
+import (
+	"errors"
+	"strings"
+
+	"cube/internal/util"
+)
+
+func init() {
+	register("metrics", func(worker Worker, db Db) interface{} {
+		return &MetricsClient{}
+	})
+}
+
+// 内置指标的前缀，不允许被自定义的指标使用
+var reservedMetricPrefixes = []string{"cube_", "go_", "process_"}
+
+// 注册自定义的指标，与内置的指标一起在 /metrics 中输出，指标在所有 vm 实例间共享，重复注册时返回已注册的指标
+type MetricsClient struct{}
+
+func (m *MetricsClient) Counter(name string, help string, labels []string) (*Metric, error) {
+	if err := checkMetricName(name); err != nil {
+		return nil, err
+	}
+	return newMetric(util.MyMetrics.Counter(name, help, labels...))
+}
+
+func (m *MetricsClient) Gauge(name string, help string, labels []string) (*Metric, error) {
+	if err := checkMetricName(name); err != nil {
+		return nil, err
+	}
+	return newMetric(util.MyMetrics.Gauge(name, help, labels...))
+}
+
+// 直方图，buckets 为空时使用默认的区间（单位秒）
+func (m *MetricsClient) Histogram(name string, help string, buckets []float64, labels []string) (*Metric, error) {
+	if err := checkMetricName(name); err != nil {
+		return nil, err
+	}
+	return newMetric(util.MyMetrics.Histogram(name, help, buckets, labels...))
+}
+
+func checkMetricName(name string) error {
+	for _, prefix := range reservedMetricPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return errors.New("metric name with prefix " + prefix + " is reserved: " + name)
+		}
+	}
+	return nil
+}
+
+func newMetric(f *util.MetricFamily, err error) (*Metric, error) {
+	if err != nil {
+		return nil, err
+	}
+	return &Metric{f}, nil
+}
+
+// 标签以键值对的方式传入，如 metric.inc({ status: "paid" })
+type Metric struct {
+	family *util.MetricFamily
+}
+
+func (m *Metric) Inc(labels map[string]string) error {
+	return m.Add(1, labels)
+}
+
+func (m *Metric) Dec(labels map[string]string) error {
+	if m.family.Kind() != "gauge" {
+		return errors.New("invalid operation dec on " + m.family.Kind())
+	}
+	return m.Add(-1, labels)
+}
+
+func (m *Metric) Add(v float64, labels map[string]string) error {
+	values, err := m.family.LabelValues(labels)
+	if err != nil {
+		return err
+	}
+	return m.family.Add(v, values...)
+}
+
+func (m *Metric) Set(v float64, labels map[string]string) error {
+	if m.family.Kind() != "gauge" { // 计数器的 Set 仅用于内置的指标
+		return errors.New("invalid operation set on " + m.family.Kind())
+	}
+	values, err := m.family.LabelValues(labels)
+	if err != nil {
+		return err
+	}
+	return m.family.Set(v, values...)
+}
+
+func (m *Metric) Observe(v float64, labels map[string]string) error {
+	values, err := m.family.LabelValues(labels)
+	if err != nil {
+		return err
+	}
+	return m.family.Observe(v, values...)
+}
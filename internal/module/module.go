@@ -5,6 +5,7 @@ import (
 	"database/sql"
 
 	"cube/internal/builtin"
+	"cube/internal/util"
 
 	"github.com/dop251/goja"
 )
@@ -20,6 +21,10 @@ type Worker interface {
 	Runtime() *goja.Runtime
 	EventLoop() *builtin.EventLoop
 	Interrupt(reason string)
+	Span() *util.Span                              // 当前的 span，不在调用链路中时返回 nil
+	StartSpan(name string, kind string) *util.Span // 创建当前 span 的子 span，不在调用链路中时返回 nil
+	PushSpan(s *util.Span)
+	PopSpan(s *util.Span)
 }
 
 type Db interface {
@@ -7,7 +7,14 @@ import (
 
 func init() {
 	register("template", func(worker Worker, db Db) interface{} {
-		return func(name string, input map[string]interface{}) (string, error) {
+		return func(name string, input map[string]interface{}) (output string, err error) {
+			span := worker.StartSpan("template "+name, "internal")
+			defer func() {
+				span.SetError(err)
+				span.Finish()
+			}()
+			span.SetAttribute("template.name", name)
+
 			var content string
 			if err := db.QueryRow("select content from source where name = ? and type = 'template' and active = true", name).Scan(&content); err != nil {
 				return "", err
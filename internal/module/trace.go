@@ -0,0 +1,58 @@
+package module
+
+import (
+	"errors"
+
+	"cube/internal/util"
+)
+
+func init() {
+	register("trace", func(worker Worker, db Db) interface{} {
+		return &TraceClient{worker}
+	})
+}
+
+// 自定义的 span，如 const span = $native("trace").start("checkout"); try { ... } finally { span.end() }
+type TraceClient struct {
+	worker Worker
+}
+
+// 创建当前 span 的子 span，不在调用链路中时开始一个新的调用链路，结束之前其为当前的 span
+func (t *TraceClient) Start(name string, attributes map[string]interface{}) *TraceSpan {
+	span := util.MyTracer.StartSpan(t.worker.Span(), name, "internal")
+	for key, value := range attributes {
+		span.SetAttribute(key, value)
+	}
+	t.worker.PushSpan(span)
+	return &TraceSpan{span.TraceId, span.SpanId, span, t.worker}
+}
+
+// 当前的 span，不在调用链路中时返回 nil
+func (t *TraceClient) Current() map[string]string {
+	span := t.worker.Span()
+	if span == nil {
+		return nil
+	}
+	return map[string]string{"traceId": span.TraceId, "spanId": span.SpanId, "traceparent": span.Traceparent()}
+}
+
+type TraceSpan struct {
+	TraceId string
+	SpanId  string
+	span    *util.Span
+	worker  Worker
+}
+
+func (s *TraceSpan) SetAttribute(key string, value interface{}) {
+	s.span.SetAttribute(key, value)
+}
+
+func (s *TraceSpan) SetError(message string) {
+	s.span.SetError(errors.New(message))
+}
+
+// 结束并恢复上一个 span 为当前的 span
+func (s *TraceSpan) End() {
+	s.span.Finish()
+	s.worker.PopSpan(s.span)
+}
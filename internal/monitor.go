@@ -11,13 +11,21 @@ import (
 func RunMonitor() {
 	p, _ := process.NewProcess(int32(os.Getppid()))
 	ticker := time.NewTicker(time.Millisecond * 1000)
+	var last WorkerPoolStats
 	for range ticker.C {
 		c, _ := p.CPUPercent()
 		m, _ := p.MemoryInfo()
-		fmt.Printf("\rcpu: %.2f%%, memory: %.2fmb, vm: %d/%d"+" ", // 结尾预留一个空格防止刷新过程中因字符串变短导致上一次打印的文本在结尾出溢出
+		s := WorkerPool.Stats()
+		var wait time.Duration // 最近一秒内的平均等待时长
+		if n := s.Waited - last.Waited; n > 0 {
+			wait = (s.WaitTime - last.WaitTime) / time.Duration(n)
+		}
+		last = s
+		fmt.Printf("\rcpu: %.2f%%, memory: %.2fmb, vm: %d/%d, queue: %d, wait: %dms"+" ", // 结尾预留一个空格防止刷新过程中因字符串变短导致上一次打印的文本在结尾出溢出
 			c,
 			float32(m.RSS)/1024/1024,
-			len(WorkerPool.Workers)-len(WorkerPool.Channels), len(WorkerPool.Workers),
+			s.Size-s.Idle, s.Size,
+			s.Queue, wait.Milliseconds(),
 		)
 	}
 }
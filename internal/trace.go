@@ -0,0 +1,21 @@
+package internal
+
+import (
+	"cube/internal/config"
+	"cube/internal/util"
+)
+
+func InitTrace() {
+	exporters := make([]util.SpanExporter, 0)
+	if config.TraceEndpoint != "" {
+		exporters = append(exporters, util.NewOtlpSpanExporter(config.TraceEndpoint, config.TraceService))
+	}
+	if config.TraceFile != "" {
+		f, err := util.OpenRotatingFile(config.TraceFile, int64(config.LogSize)<<20, config.LogRotate, config.LogKeep)
+		if err != nil {
+			panic(err)
+		}
+		exporters = append(exporters, util.NewFileSpanExporter(f))
+	}
+	util.MyTracer.SetExporters(exporters...)
+}
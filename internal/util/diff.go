@@ -0,0 +1,146 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+)
+
+type diffOp struct {
+	kind byte // ' ' 表示相同，'-' 表示删除，'+' 表示新增
+	text string
+	a, b int // 该行在旧文本、新文本中的行号（从 0 开始）
+}
+
+// Diff 按行比较两段文本，返回 unified 格式的差异
+func Diff(a string, b string, nameA string, nameB string) string {
+	ops := diffLines(splitLines(a), splitLines(b))
+
+	var sb strings.Builder
+	sb.WriteString("--- " + nameA + "\n")
+	sb.WriteString("+++ " + nameB + "\n")
+
+	const context = 3 // 每个差异块前后保留的上下文行数
+
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		// 确定差异块的起止位置：相邻差异之间的相同行不超过 2 * context 行时合并为一个块
+		start, end := max(i-context, 0), i
+		for j := i; j < len(ops); j++ {
+			if ops[j].kind != ' ' {
+				end = j
+				continue
+			}
+			if j-end > 2*context {
+				break
+			}
+		}
+		end = min(end+context, len(ops)-1)
+
+		// 统计块内旧文本、新文本的行数
+		countA, countB := 0, 0
+		for _, op := range ops[start : end+1] {
+			if op.kind != '+' {
+				countA++
+			}
+			if op.kind != '-' {
+				countB++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", hunkStart(ops[start].a, countA), countA, hunkStart(ops[start].b, countB), countB)
+		for _, op := range ops[start : end+1] {
+			sb.WriteByte(op.kind)
+			sb.WriteString(op.text + "\n")
+		}
+
+		i = end + 1
+	}
+
+	return sb.String()
+}
+
+// 差异块的起始行号（从 1 开始），与 GNU diff 一致，块内没有行时为其前一行的行号，如空文本为 0
+func hunkStart(line int, count int) int {
+	if count == 0 {
+		return line
+	}
+	return line + 1
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// 使用 Myers 差分算法计算最短编辑脚本，见 http://www.xmailserver.org/diff2.pdf
+func diffLines(a []string, b []string) []diffOp {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+
+	// 记录每一轮编辑距离 d 开始前各对角线 k 上能到达的最远 x 坐标
+	v, trace := make([]int, 2*offset+1), make([][]int, 0)
+L:
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, append([]int{}, v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1] // 向下移动，即新增一行
+			} else {
+				x = v[offset+k-1] + 1 // 向右移动，即删除一行
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] { // 沿对角线跳过相同的行
+				x, y = x+1, y+1
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break L
+			}
+		}
+	}
+
+	// 回溯编辑路径
+	ops := make([]diffOp, 0, n+m)
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		var pk int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			pk = k + 1
+		} else {
+			pk = k - 1
+		}
+		px := v[offset+pk]
+		py := px - pk
+
+		for x > px && y > py {
+			x, y = x-1, y-1
+			ops = append(ops, diffOp{' ', a[x], x, y})
+		}
+		if d > 0 {
+			if x == px {
+				y--
+				ops = append(ops, diffOp{'+', b[y], x, y})
+			} else {
+				x--
+				ops = append(ops, diffOp{'-', a[x], x, y})
+			}
+		}
+		x, y = px, py
+	}
+
+	// 反转为正序
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+
+	return ops
+}
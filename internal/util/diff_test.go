@@ -0,0 +1,27 @@
+package util
+
+import "testing"
+
+func TestDiff(t *testing.T) {
+	a := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	b := "a\nb\nc\nd\nE\nf\ng\nh\ni\nj\nk\n"
+
+	expected := "--- v1\n+++ v2\n" +
+		"@@ -2,9 +2,10 @@\n" +
+		" b\n c\n d\n-e\n+E\n f\n g\n h\n i\n j\n+k\n"
+	if s := Diff(a, b, "v1", "v2"); s != expected {
+		t.Fatalf("unexpected diff:\n%s", s)
+	}
+
+	if s := Diff(a, a, "v1", "v2"); s != "--- v1\n+++ v2\n" {
+		t.Fatalf("unexpected diff:\n%s", s)
+	}
+
+	if s := Diff("", "x\n", "v1", "v2"); s != "--- v1\n+++ v2\n@@ -0,0 +1,1 @@\n+x\n" {
+		t.Fatalf("unexpected diff:\n%s", s)
+	}
+
+	if s := Diff("x\ny\n", "", "v1", "v2"); s != "--- v1\n+++ v2\n@@ -1,2 +0,0 @@\n-x\n-y\n" {
+		t.Fatalf("unexpected diff:\n%s", s)
+	}
+}
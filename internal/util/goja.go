@@ -10,13 +10,13 @@ func ExportGojaValue(value goja.Value) (interface{}, error) {
 		if b, ok := o.Export().(goja.ArrayBuffer); ok { // 如果返回值为 ArrayBuffer 类型，则转换为 []byte
 			return b.Bytes(), nil
 		}
-		if "Uint8Array" == o.Get("constructor").(*goja.Object).Get("name").String() { // 如果返回值为 Uint8Array 类型，则转换为 []byte
-			return o.Get("buffer").Export().(goja.ArrayBuffer).Bytes(), nil
+		if b, ok := o.Export().([]byte); ok { // 如果返回值为 Uint8Array 类型，则转换为 []byte，这里直接导出视图对应的切片，而非整个底层的 ArrayBuffer，以支持 subarray 等偏移的视图
+			return b, nil
 		}
 		if p, ok := o.Export().(*goja.Promise); ok {
 			switch p.State() {
 			case goja.PromiseStateRejected:
-				return nil, errors.New(p.Result().String())
+				return nil, NewPromiseRejection(p.Result()) // 保留异常对象，用于获取调用栈
 			case goja.PromiseStateFulfilled:
 				return ExportGojaValue(p.Result())
 			default:
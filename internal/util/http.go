@@ -71,6 +71,10 @@ func (a *DigestAuth) VerifyWithMd5(input string, method string, userpass string)
 	return p["response"] == re
 }
 
+func (a *DigestAuth) GetUsername(input string) string {
+	return a.parse(input)["username"]
+}
+
 func (a *DigestAuth) Random(size int) string {
 	b := make([]byte, size/2+1)
 	rand.Read(b)
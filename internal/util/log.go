@@ -0,0 +1,182 @@
+package util
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 日志等级，按严重程度递增
+var LogLevels = map[string]int{"debug": 0, "info": 1, "warn": 2, "error": 3}
+
+// 一行日志，以 JSON 格式写入
+type LogEntry struct {
+	Time      time.Time       `json:"time"`
+	Level     string          `json:"level"`
+	Worker    int             `json:"worker"`         // vm 实例的 id，-1 表示不在 vm 实例中
+	Kind      string          `json:"kind,omitempty"` // 来源的类型：controller、daemon、crontab、job、eval
+	Source    string          `json:"source,omitempty"`
+	RequestId string          `json:"requestId,omitempty"`
+	TraceId   string          `json:"traceId,omitempty"` // 调用链路的 id，用于关联请求期间的 span
+	Message   string          `json:"message"`
+	Fields    json.RawMessage `json:"fields,omitempty"` // 结构化的字段，如 console.info("msg", { k: v }) 中的 { k: v }
+}
+
+var MyLogger = &Logger{output: os.Stdout, levels: map[string]string{"": "debug"}, subscribers: make(map[*logSubscriber]struct{})}
+
+type Logger struct {
+	sync.RWMutex
+	output      io.Writer
+	levels      map[string]string // 来源名称 -> 最低等级，空名称为默认的最低等级
+	subscribers map[*logSubscriber]struct{}
+}
+
+// 实时日志的订阅者，如 IDE 中的日志面板
+type logSubscriber struct {
+	query *LogQuery
+	ch    chan *LogEntry
+}
+
+func (l *Logger) SetOutput(w io.Writer) {
+	l.Lock()
+	defer l.Unlock()
+	l.output = w
+}
+
+// 设置来源的最低等级，source 为空时设置默认的最低等级，level 为空时移除来源的设置
+func (l *Logger) SetLevel(source string, level string) error {
+	l.Lock()
+	defer l.Unlock()
+	if level == "" && source != "" {
+		delete(l.levels, source)
+		return nil
+	}
+	if _, found := LogLevels[level]; !found {
+		return errors.New("invalid log level: " + level)
+	}
+	l.levels[source] = level
+	return nil
+}
+
+func (l *Logger) Levels() map[string]string {
+	l.RLock()
+	defer l.RUnlock()
+	levels := make(map[string]string, len(l.levels))
+	for source, level := range l.levels {
+		levels[source] = level
+	}
+	return levels
+}
+
+// 判断来源的日志在该等级下是否输出
+func (l *Logger) Enabled(source string, level string) bool {
+	l.RLock()
+	defer l.RUnlock()
+	lowest, found := l.levels[source]
+	if !found {
+		lowest = l.levels[""]
+	}
+	return LogLevels[level] >= LogLevels[lowest]
+}
+
+// 写入一行日志，低于来源最低等级的日志将被忽略
+func (l *Logger) Write(e *LogEntry) {
+	if !l.Enabled(e.Source, e.Level) {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	l.Lock()
+	defer l.Unlock()
+	l.output.Write(append(b, '\n'))
+	for s := range l.subscribers {
+		if !s.query.Match(e) {
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default: // 订阅者消费过慢时丢弃，不阻塞日志的写入
+		}
+	}
+}
+
+// 订阅新写入的日志，返回的 cancel 方法用于取消订阅
+func (l *Logger) Subscribe(q *LogQuery) (<-chan *LogEntry, func()) {
+	s := &logSubscriber{q, make(chan *LogEntry, 256)}
+	l.Lock()
+	l.subscribers[s] = struct{}{}
+	l.Unlock()
+	return s.ch, func() {
+		l.Lock()
+		delete(l.subscribers, s)
+		l.Unlock()
+	}
+}
+
+// 用于标准库 log 包的输出，将每行文本转换为日志，如 net/http 的连接异常
+type LogWriter string
+
+func (level LogWriter) Write(p []byte) (int, error) {
+	MyLogger.Write(&LogEntry{Level: string(level), Worker: -1, Message: strings.TrimRight(string(p), "\n")})
+	return len(p), nil
+}
+
+//#region 查询
+
+type LogQuery struct {
+	Kind      string
+	Source    string
+	RequestId string
+	TraceId   string
+	Level     string // 最低等级
+	From      time.Time
+	To        time.Time
+	Size      int // 最多返回最近的条数
+}
+
+func (q *LogQuery) Match(e *LogEntry) bool {
+	switch {
+	case q.Kind != "" && e.Kind != q.Kind,
+		q.Source != "" && e.Source != q.Source,
+		q.RequestId != "" && e.RequestId != q.RequestId,
+		q.TraceId != "" && e.TraceId != q.TraceId,
+		q.Level != "" && LogLevels[e.Level] < LogLevels[q.Level],
+		!q.From.IsZero() && e.Time.Before(q.From),
+		!q.To.IsZero() && !e.Time.Before(q.To):
+		return false
+	}
+	return true
+}
+
+// 从 JSON lines 中查询日志，返回匹配的最近 q.Size 条，无法解析的行（如旧版本的文本日志）将被忽略
+func QueryLogs(r io.Reader, q *LogQuery) ([]*LogEntry, error) {
+	entries := make([]*LogEntry, 0)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		e := &LogEntry{}
+		if json.Unmarshal(scanner.Bytes(), e) != nil || !q.Match(e) {
+			continue
+		}
+		entries = append(entries, e)
+		if q.Size > 0 && len(entries) >= 2*q.Size { // 只保留最近的记录
+			entries = append(entries[:0], entries[len(entries)-q.Size:]...)
+		}
+	}
+	if q.Size > 0 && len(entries) > q.Size {
+		entries = entries[len(entries)-q.Size:]
+	}
+	return entries, scanner.Err()
+}
+
+//#endregion
@@ -0,0 +1,92 @@
+package util
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogger(t *testing.T) {
+	var b bytes.Buffer
+	l := &Logger{output: &b, levels: map[string]string{"": "info"}, subscribers: make(map[*logSubscriber]struct{})}
+	if err := l.SetLevel("foo", "warn"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.SetLevel("foo", "trace"); err == nil {
+		t.Error("want an error for an invalid level")
+	}
+
+	start := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	for i, e := range []*LogEntry{
+		{Level: "debug", Source: "bar", Message: "dropped by the default level"},
+		{Level: "info", Source: "bar", Kind: "daemon", Message: "a"},
+		{Level: "info", Source: "foo", Message: "dropped by the source level"},
+		{Level: "error", Source: "foo", Kind: "controller", RequestId: "r1", Message: "b"},
+		{Level: "warn", Source: "bar", Kind: "daemon", Message: "c"},
+	} {
+		e.Time = start.Add(time.Duration(i) * time.Minute)
+		l.Write(e)
+	}
+	b.WriteString("2024-01-02 00:10:00.000 0 Log legacy text line\n")
+
+	for _, c := range []struct {
+		query LogQuery
+		want  string
+	}{
+		{LogQuery{}, "a b c"},
+		{LogQuery{Source: "bar"}, "a c"},
+		{LogQuery{Kind: "controller", RequestId: "r1"}, "b"},
+		{LogQuery{Level: "warn"}, "b c"},
+		{LogQuery{From: start.Add(2 * time.Minute), To: start.Add(4 * time.Minute)}, "b"},
+		{LogQuery{Size: 2}, "b c"},
+	} {
+		entries, err := QueryLogs(bytes.NewReader(b.Bytes()), &c.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		messages := make([]string, 0)
+		for _, e := range entries {
+			messages = append(messages, e.Message)
+		}
+		if got := strings.Join(messages, " "); got != c.want {
+			t.Errorf("%+v: got %q, want %q", c.query, got, c.want)
+		}
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cube.log")
+	f, err := OpenRotatingFile(path, 64, "", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		if _, err := f.Write([]byte(strings.Repeat(strconv.Itoa(i), 40) + "\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.clean() // 等待轮转时在后台进行的压缩和清理完成
+
+	rotated := f.Rotated()
+	if len(rotated) != 2 {
+		t.Fatalf("got %d rotated files, want 2: %v", len(rotated), rotated)
+	}
+	for i, name := range rotated {
+		if !strings.HasSuffix(name, ".gz") {
+			t.Errorf("%s is not compressed", name)
+		}
+		r, err := OpenLogFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(r)
+		r.Close()
+		if want := strings.Repeat(strconv.Itoa(i+1), 40) + "\n"; string(b) != want { // 第 0 个文件已被清理
+			t.Errorf("%s: got %q, want %q", name, b, want)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 默认的直方图区间，单位秒，与 Prometheus 客户端的默认值一致
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+var metricNamePattern = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+var MyMetrics = &MetricRegistry{families: make(map[string]*MetricFamily)}
+
+// 指标的注册表，以 Prometheus 的文本格式输出
+type MetricRegistry struct {
+	sync.RWMutex
+	families map[string]*MetricFamily
+}
+
+// 同一名称的指标，按标签的取值分为多个序列
+type MetricFamily struct {
+	sync.Mutex
+	name    string
+	help    string
+	kind    string // counter、gauge、histogram
+	labels  []string
+	buckets []float64
+	series  map[string]*metricSeries // 标签取值 -> 序列
+}
+
+type metricSeries struct {
+	values []string
+	value  float64
+	counts []uint64 // 直方图各区间的计数，不累加
+	sum    float64
+	count  uint64
+}
+
+func (r *MetricRegistry) Counter(name string, help string, labels ...string) (*MetricFamily, error) {
+	return r.register(name, help, "counter", nil, labels)
+}
+
+func (r *MetricRegistry) Gauge(name string, help string, labels ...string) (*MetricFamily, error) {
+	return r.register(name, help, "gauge", nil, labels)
+}
+
+// 直方图，buckets 为空时使用 DefaultBuckets
+func (r *MetricRegistry) Histogram(name string, help string, buckets []float64, labels ...string) (*MetricFamily, error) {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+	return r.register(name, help, "histogram", buckets, labels)
+}
+
+// 注册指标，已存在同名的指标时，类型和标签一致则返回已存在的指标，否则返回异常
+func (r *MetricRegistry) register(name string, help string, kind string, buckets []float64, labels []string) (*MetricFamily, error) {
+	if !metricNamePattern.MatchString(name) {
+		return nil, errors.New("invalid metric name: " + name)
+	}
+	for _, label := range labels {
+		if !labelNamePattern.MatchString(label) || strings.HasPrefix(label, "__") || kind == "histogram" && label == "le" {
+			return nil, errors.New("invalid label name: " + label)
+		}
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	if f, found := r.families[name]; found {
+		if f.kind != kind || strings.Join(f.labels, ",") != strings.Join(labels, ",") || fmt.Sprint(f.buckets) != fmt.Sprint(buckets) {
+			return nil, errors.New("metric " + name + " is already registered as a different " + f.kind)
+		}
+		return f, nil
+	}
+	f := &MetricFamily{name: name, help: help, kind: kind, labels: labels, buckets: buckets, series: make(map[string]*metricSeries)}
+	r.families[name] = f
+	return f, nil
+}
+
+// 用于内置的指标，注册失败时 panic
+func MustMetric(f *MetricFamily, err error) *MetricFamily {
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+func (f *MetricFamily) Kind() string {
+	return f.kind
+}
+
+// 将标签的键值对转换为按声明顺序排列的取值，未指定的标签取值为空
+func (f *MetricFamily) LabelValues(labels map[string]string) ([]string, error) {
+	values := make([]string, len(f.labels))
+	for name, value := range labels {
+		i := indexOf(f.labels, name)
+		if i < 0 {
+			return nil, errors.New("unknown label " + name + " of metric " + f.name)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+func (f *MetricFamily) get(values []string) (*metricSeries, error) {
+	if len(values) != len(f.labels) {
+		return nil, fmt.Errorf("metric %s requires %d label values, got %d", f.name, len(f.labels), len(values))
+	}
+	key := strings.Join(values, "\xff")
+	s, found := f.series[key]
+	if !found {
+		s = &metricSeries{values: append([]string{}, values...)}
+		if f.kind == "histogram" {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s, nil
+}
+
+// 增加计数器或仪表盘的值，计数器只能增加
+func (f *MetricFamily) Add(v float64, values ...string) error {
+	if f.kind == "histogram" || f.kind == "counter" && v < 0 {
+		return errors.New("invalid operation add on " + f.kind + " " + f.name)
+	}
+	f.Lock()
+	defer f.Unlock()
+	s, err := f.get(values)
+	if err != nil {
+		return err
+	}
+	s.value += v
+	return nil
+}
+
+// 设置仪表盘的值，计数器仅用于同步外部已累计的值，如工作池的累计等待次数
+func (f *MetricFamily) Set(v float64, values ...string) error {
+	if f.kind == "histogram" {
+		return errors.New("invalid operation set on histogram " + f.name)
+	}
+	f.Lock()
+	defer f.Unlock()
+	s, err := f.get(values)
+	if err != nil {
+		return err
+	}
+	s.value = v
+	return nil
+}
+
+func (f *MetricFamily) Observe(v float64, values ...string) error {
+	if f.kind != "histogram" {
+		return errors.New("invalid operation observe on " + f.kind + " " + f.name)
+	}
+	f.Lock()
+	defer f.Unlock()
+	s, err := f.get(values)
+	if err != nil {
+		return err
+	}
+	if i := sort.SearchFloat64s(f.buckets, v); i < len(f.buckets) { // 第一个不小于 v 的区间上限
+		s.counts[i]++
+	}
+	s.sum += v
+	s.count++
+	return nil
+}
+
+// 移除所有序列，用于按当前状态重新生成的指标，如已删除的守护任务
+func (f *MetricFamily) Reset() {
+	f.Lock()
+	defer f.Unlock()
+	f.series = make(map[string]*metricSeries)
+}
+
+// 以 Prometheus 的文本格式（text/plain; version=0.0.4）输出所有指标
+func (r *MetricRegistry) WriteText(w io.Writer) error {
+	r.RLock()
+	families := make([]*MetricFamily, 0, len(r.families))
+	for _, f := range r.families {
+		families = append(families, f)
+	}
+	r.RUnlock()
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].name < families[j].name
+	})
+
+	var b strings.Builder
+	for _, f := range families {
+		f.write(&b)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (f *MetricFamily) write(b *strings.Builder) {
+	f.Lock()
+	defer f.Unlock()
+
+	if len(f.series) == 0 {
+		return
+	}
+	b.WriteString("# HELP " + f.name + " " + strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(f.help) + "\n")
+	b.WriteString("# TYPE " + f.name + " " + f.kind + "\n")
+
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := f.series[key]
+		if f.kind != "histogram" {
+			b.WriteString(f.name + f.formatLabels(s.values, "") + " " + formatFloat(s.value) + "\n")
+			continue
+		}
+		var cumulative uint64
+		for i, upper := range f.buckets {
+			cumulative += s.counts[i]
+			b.WriteString(f.name + "_bucket" + f.formatLabels(s.values, formatFloat(upper)) + " " + strconv.FormatUint(cumulative, 10) + "\n")
+		}
+		b.WriteString(f.name + "_bucket" + f.formatLabels(s.values, "+Inf") + " " + strconv.FormatUint(s.count, 10) + "\n")
+		b.WriteString(f.name + "_sum" + f.formatLabels(s.values, "") + " " + formatFloat(s.sum) + "\n")
+		b.WriteString(f.name + "_count" + f.formatLabels(s.values, "") + " " + strconv.FormatUint(s.count, 10) + "\n")
+	}
+}
+
+// 如 {method="GET",status="200"}，le 为直方图区间的上限
+func (f *MetricFamily) formatLabels(values []string, le string) string {
+	pairs := make([]string, 0, len(values)+1)
+	for i, value := range values {
+		pairs = append(pairs, f.labels[i]+`="`+strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)+`"`)
+	}
+	if le != "" {
+		pairs = append(pairs, `le="`+le+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,60 @@
+package util
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	r := &MetricRegistry{families: make(map[string]*MetricFamily)}
+	requests := MustMetric(r.Counter("requests_total", "Count of requests.", "method", "status"))
+	duration := MustMetric(r.Histogram("duration_seconds", "Latency.", []float64{1, 0.1}))
+	MustMetric(r.Gauge("empty", "Not written without series."))
+
+	if f, err := r.Counter("requests_total", "", "method", "status"); err != nil || f != requests {
+		t.Errorf("want the registered metric, got %v, %v", f, err)
+	}
+	if _, err := r.Gauge("requests_total", "", "method", "status"); err == nil {
+		t.Error("want an error for a different kind")
+	}
+	if _, err := r.Counter("bad-name", ""); err == nil {
+		t.Error("want an error for an invalid name")
+	}
+	if err := requests.Add(-1, "GET", "200"); err == nil {
+		t.Error("want an error for decreasing a counter")
+	}
+	if err := requests.Add(1, "GET"); err == nil {
+		t.Error("want an error for missing label values")
+	}
+	if _, err := requests.LabelValues(map[string]string{"path": "/"}); err == nil {
+		t.Error("want an error for an unknown label")
+	}
+
+	values, _ := requests.LabelValues(map[string]string{"status": "200", "method": "GET"})
+	requests.Add(1, values...)
+	requests.Add(2, values...)
+	requests.Add(1, "POST", `5"0"0`)
+	for _, v := range []float64{0.05, 0.1, 0.5, 3} {
+		duration.Observe(v)
+	}
+
+	var b strings.Builder
+	if err := r.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP duration_seconds Latency.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{le="0.1"} 2
+duration_seconds_bucket{le="1"} 3
+duration_seconds_bucket{le="+Inf"} 4
+duration_seconds_sum 3.65
+duration_seconds_count 4
+# HELP requests_total Count of requests.
+# TYPE requests_total counter
+requests_total{method="GET",status="200"} 3
+requests_total{method="POST",status="5\"0\"0"} 1
+`
+	if got := b.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
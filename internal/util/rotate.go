@@ -0,0 +1,206 @@
+package util
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const rotateTimeFormat = "20060102-150405"
+
+// 按大小和时间轮转的文件，轮转后的文件命名为 <path>.<轮转时间>.gz，并仅保留最近的若干个
+type RotatingFile struct {
+	sync.Mutex
+	path     string
+	maxSize  int64  // 超出后轮转，0 表示不按大小轮转
+	interval string // 按时间轮转的周期：daily、hourly，其他值表示不按时间轮转
+	keep     int    // 保留的已轮转文件的个数，0 表示全部保留
+	file     *os.File
+	size     int64
+	last     time.Time  // 最近一次写入的时间
+	cleaning sync.Mutex // 压缩和清理在单独的协程中进行
+}
+
+func OpenRotatingFile(path string, maxSize int64, interval string, keep int) (*RotatingFile, error) {
+	f := &RotatingFile{path: filepath.Clean(path), maxSize: maxSize, interval: interval, keep: keep}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	go f.clean()
+	return f, nil
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size, f.last = file, info.Size(), info.ModTime()
+	return nil
+}
+
+func (f *RotatingFile) period(t time.Time) string {
+	switch f.interval {
+	case "daily":
+		return t.Format("20060102")
+	case "hourly":
+		return t.Format("2006010215")
+	}
+	return ""
+}
+
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	now := time.Now()
+	if f.size > 0 && (f.maxSize > 0 && f.size+int64(len(p)) > f.maxSize || f.period(now) != f.period(f.last)) {
+		if err := f.rotate(now); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	f.last = now
+	return n, err
+}
+
+func (f *RotatingFile) rotate(now time.Time) error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	name := f.path + "." + now.Format(rotateTimeFormat)
+	for i := 1; exists(name) || exists(name+".gz"); i++ { // 同一秒内多次轮转
+		name = f.path + "." + now.Format(rotateTimeFormat) + "-" + strconv.Itoa(i)
+	}
+	if err := os.Rename(f.path, name); err != nil {
+		return err
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	go f.clean()
+	return nil
+}
+
+// 压缩未压缩的已轮转文件，并删除超出保留个数的文件
+func (f *RotatingFile) clean() {
+	f.cleaning.Lock()
+	defer f.cleaning.Unlock()
+
+	files := f.Rotated()
+	for i, name := range files {
+		if strings.HasSuffix(name, ".gz") {
+			continue
+		}
+		if err := compress(name); err == nil {
+			files[i] = name + ".gz"
+		}
+	}
+	if f.keep > 0 && len(files) > f.keep {
+		for _, name := range files[:len(files)-f.keep] {
+			os.Remove(name)
+		}
+	}
+}
+
+// 已轮转的文件，按轮转时间排序
+func (f *RotatingFile) Rotated() []string {
+	matches, _ := filepath.Glob(f.path + ".*")
+	files := make([]string, 0, len(matches))
+	for _, name := range matches {
+		if _, err := RotatedTime(f.path, name); err == nil && !strings.HasSuffix(name, ".gz.tmp") {
+			files = append(files, name)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { // 同一秒内轮转的文件按序号排序，如 .20240102-150405、.20240102-150405-1
+		a, b := rotatedKey(f.path, files[i]), rotatedKey(f.path, files[j])
+		return a.time < b.time || a.time == b.time && a.index < b.index
+	})
+	return files
+}
+
+// 已轮转文件的轮转时间，即文件中最后一行日志的时间上限
+func RotatedTime(path string, name string) (time.Time, error) {
+	suffix := strings.TrimPrefix(filepath.Clean(name), filepath.Clean(path)+".")
+	if len(suffix) < len(rotateTimeFormat) || suffix == name {
+		return time.Time{}, errors.New("not a rotated file: " + name)
+	}
+	return time.ParseInLocation(rotateTimeFormat, suffix[:len(rotateTimeFormat)], time.Local)
+}
+
+type rotatedFileKey struct {
+	time  string
+	index int
+}
+
+func rotatedKey(path string, name string) rotatedFileKey {
+	suffix := strings.TrimSuffix(strings.TrimPrefix(name, path+"."), ".gz")
+	index, _ := strconv.Atoi(strings.TrimPrefix(suffix[len(rotateTimeFormat):], "-"))
+	return rotatedFileKey{suffix[:len(rotateTimeFormat)], index}
+}
+
+func compress(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(name+".gz.tmp", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	w := gzip.NewWriter(dst)
+	if _, err = io.Copy(w, src); err == nil {
+		err = w.Close()
+	}
+	if e := dst.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		os.Remove(name + ".gz.tmp")
+		return err
+	}
+	if err := os.Rename(name+".gz.tmp", name+".gz"); err != nil {
+		return err
+	}
+	return os.Remove(name)
+}
+
+// 打开日志文件，已压缩的文件将被解压读取
+func OpenLogFile(name string) (io.ReadCloser, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(name, ".gz") {
+		return file, nil
+	}
+	r, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{r, file}, nil
+}
+
+func exists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
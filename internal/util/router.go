@@ -0,0 +1,246 @@
+package util
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// 路由片段的类型，按匹配的优先级从高到低排列
+const (
+	segmentStatic   = iota // 静态片段，如 user
+	segmentMixed           // 包含参数的混合片段，如 {id}.json
+	segmentTyped           // 限定类型的参数，如 {id:int}、{id:[a-z]+}
+	segmentParam           // 参数，如 {id}
+	segmentCatchAll        // 通配参数，匹配剩余的所有片段，如 {*path}
+)
+
+var (
+	segmentParamRegexp = regexp.MustCompile(`{(\*?)(\w+)(?::([^{}]+))?}`)
+	segmentTypes       = map[string]string{
+		"int":  `\d+`,
+		"uuid": `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`,
+	}
+)
+
+type routeNode struct {
+	kind     int
+	segment  string         // 原始片段
+	param    string         // 参数名称
+	regexp   *regexp.Regexp // 限定类型的参数或混合片段的正则表达式
+	children []*routeNode
+	names    map[string]string // 请求方法与路由名称的映射，空字符串表示匹配任意方法，非空时表示该节点为一个完整的路由
+}
+
+// 基于前缀树的路由，匹配顺序与路由的添加顺序无关：静态片段优先于参数片段，同类片段按字典序匹配
+type Router struct {
+	root *routeNode
+}
+
+func NewRouter() *Router {
+	return &Router{root: &routeNode{}}
+}
+
+func parseSegment(segment string) (*routeNode, error) {
+	matches := segmentParamRegexp.FindAllStringSubmatchIndex(segment, -1)
+	if len(matches) == 0 {
+		if strings.ContainsAny(segment, "{}") {
+			return nil, errors.New("invalid route segment: " + segment)
+		}
+		return &routeNode{kind: segmentStatic, segment: segment}, nil
+	}
+
+	// 整个片段为一个参数
+	if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(segment) {
+		m := matches[0]
+		param := segment[m[4]:m[5]]
+		if m[3] > m[2] { // 通配参数
+			return &routeNode{kind: segmentCatchAll, segment: segment, param: param}, nil
+		}
+		if m[6] == -1 {
+			return &routeNode{kind: segmentParam, segment: segment, param: param}, nil
+		}
+		expr := segment[m[6]:m[7]]
+		if t, ok := segmentTypes[expr]; ok {
+			expr = t
+		}
+		r, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return nil, err
+		}
+		return &routeNode{kind: segmentTyped, segment: segment, param: param, regexp: r}, nil
+	}
+
+	// 混合片段，转换为正则表达式
+	expr, last := "^", 0
+	for _, m := range matches {
+		if m[3] > m[2] {
+			return nil, errors.New("catch-all parameter must be the whole segment: " + segment)
+		}
+		t := ".+?"
+		if m[6] != -1 {
+			t = segment[m[6]:m[7]]
+			if v, ok := segmentTypes[t]; ok {
+				t = v
+			}
+		}
+		expr += regexp.QuoteMeta(segment[last:m[0]]) + "(?P<" + segment[m[4]:m[5]] + ">" + t + ")"
+		last = m[1]
+	}
+	expr += regexp.QuoteMeta(segment[last:]) + "$"
+	r, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &routeNode{kind: segmentMixed, segment: segment, regexp: r}, nil
+}
+
+// 添加路由，如 user/{id:int}、user/me、static/{*path}，method 为空时表示匹配任意请求方法
+func (r *Router) Add(name string, method string, pattern string) error {
+	segments := strings.Split(pattern, "/")
+
+	node := r.root
+	for i, segment := range segments {
+		child, err := parseSegment(segment)
+		if err != nil {
+			return err
+		}
+		if child.kind == segmentCatchAll && i != len(segments)-1 {
+			return errors.New("catch-all parameter must be the last segment: " + pattern)
+		}
+
+		// 查找相同的子节点，不存在则按优先级插入
+		var found *routeNode
+		for _, c := range node.children {
+			if c.segment == segment {
+				found = c
+				break
+			}
+		}
+		if found == nil {
+			node.children = append(node.children, child)
+			sort.SliceStable(node.children, func(a, b int) bool {
+				x, y := node.children[a], node.children[b]
+				if x.kind != y.kind {
+					return x.kind < y.kind
+				}
+				return x.segment < y.segment
+			})
+			found = child
+		}
+		node = found
+	}
+
+	if node.names == nil {
+		node.names = make(map[string]string)
+	}
+	// 相同的请求方法冲突，任意请求方法与所有请求方法冲突
+	for m, v := range node.names {
+		if v != name && (m == method || m == "" || method == "") {
+			return errors.New("route " + strings.TrimSpace(method+" "+pattern) + " conflicts with " + v)
+		}
+	}
+	node.names[method] = name
+
+	return nil
+}
+
+// 匹配路由，返回路由名称和路径参数
+// 如果路径匹配但请求方法不匹配，则返回的路由名称为空，并返回该路径所允许的请求方法
+func (r *Router) Match(method string, path string) (string, map[string]string, []string) {
+	segments := strings.Split(path, "/")
+
+	// 优先匹配指定了请求方法的路由，其次匹配任意请求方法的路由
+	vars := make(map[string]string)
+	node := r.root.match(segments, vars, func(n *routeNode) bool {
+		_, a := n.names[method]
+		_, b := n.names[""]
+		return a || b
+	})
+	if node != nil {
+		if name, ok := node.names[method]; ok {
+			return name, vars, nil
+		}
+		return node.names[""], vars, nil
+	}
+
+	// 路径匹配但请求方法不匹配
+	node = r.root.match(segments, make(map[string]string), func(n *routeNode) bool {
+		return true
+	})
+	if node == nil {
+		return "", nil, nil
+	}
+	methods := make([]string, 0, len(node.names))
+	for m := range node.names {
+		methods = append(methods, m)
+	}
+	sort.Strings(methods)
+	return "", nil, methods
+}
+
+func (n *routeNode) match(segments []string, vars map[string]string, accept func(n *routeNode) bool) *routeNode {
+	if len(segments) == 0 {
+		if len(n.names) > 0 && accept(n) {
+			return n
+		}
+		// 通配参数可以匹配空路径
+		for _, c := range n.children {
+			if c.kind == segmentCatchAll && len(c.names) > 0 && accept(c) {
+				vars[c.param] = ""
+				return c
+			}
+		}
+		return nil
+	}
+
+	segment := segments[0]
+	for _, c := range n.children {
+		switch c.kind {
+		case segmentStatic:
+			if c.segment != segment {
+				continue
+			}
+		case segmentMixed:
+			m := c.regexp.FindStringSubmatch(segment)
+			if m == nil {
+				continue
+			}
+			for i, name := range c.regexp.SubexpNames() {
+				if i > 0 {
+					vars[name] = m[i]
+				}
+			}
+		case segmentTyped:
+			if !c.regexp.MatchString(segment) {
+				continue
+			}
+			vars[c.param] = segment
+		case segmentParam:
+			vars[c.param] = segment
+		case segmentCatchAll:
+			if len(c.names) == 0 || !accept(c) {
+				continue
+			}
+			vars[c.param] = strings.Join(segments, "/")
+			return c
+		}
+
+		if node := c.match(segments[1:], vars, accept); node != nil {
+			return node
+		}
+
+		// 回溯，清理当前片段写入的参数
+		if c.param != "" {
+			delete(vars, c.param)
+		}
+		if c.kind == segmentMixed {
+			for _, name := range c.regexp.SubexpNames()[1:] {
+				delete(vars, name)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package util
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	r := NewRouter()
+	for name, pattern := range map[string]string{
+		"userById":  "user/{id:int}",
+		"userMe":    "user/me",
+		"userByKey": "user/{key}",
+		"userJson":  "user/{id}.json",
+		"profile":   "user/{id}/profile",
+		"static":    "static/{*path}",
+		"greeting":  "{name}/greeting/{words}",
+	} {
+		if err := r.Add(name, "", pattern); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, c := range []struct {
+		path string
+		name string
+		vars map[string]string
+	}{
+		{"user/me", "userMe", map[string]string{}},
+		{"user/1", "userById", map[string]string{"id": "1"}},
+		{"user/zhangsan", "userByKey", map[string]string{"key": "zhangsan"}},
+		{"user/1.json", "userJson", map[string]string{"id": "1"}},
+		{"user/me/profile", "profile", map[string]string{"id": "me"}},
+		{"static/js/app.js", "static", map[string]string{"path": "js/app.js"}},
+		{"static/", "static", map[string]string{"path": ""}},
+		{"zhangsan/greeting/hello", "greeting", map[string]string{"name": "zhangsan", "words": "hello"}},
+		{"user/1/unknown", "", nil},
+	} {
+		name, vars, _ := r.Match("GET", c.path)
+		if name != c.name {
+			t.Fatalf("%s: expected route %q, got %q", c.path, c.name, name)
+		}
+		if len(vars) != len(c.vars) {
+			t.Fatalf("%s: unexpected vars %v", c.path, vars)
+		}
+		for k, v := range c.vars {
+			if vars[k] != v {
+				t.Fatalf("%s: unexpected vars %v", c.path, vars)
+			}
+		}
+	}
+
+	if err := r.Add("another", "", "user/{id:int}"); err == nil {
+		t.Fatal("expected conflict error")
+	}
+	if err := r.Add("invalid", "", "static/{*path}/foo"); err == nil {
+		t.Fatal("expected catch-all error")
+	}
+}
+
+func TestRouterWithMethods(t *testing.T) {
+	r := NewRouter()
+	for _, route := range [][3]string{
+		{"getOrder", "GET", "orders/{id}"},
+		{"deleteOrder", "DELETE", "orders/{id}"},
+		{"anyItem", "", "orders/{id}/items"},
+	} {
+		if err := r.Add(route[0], route[1], route[2]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, route := range [][3]string{
+		{"another", "GET", "orders/{id}"},
+		{"anyOrder", "", "orders/{id}"},
+		{"getItem", "GET", "orders/{id}/items"},
+	} {
+		if err := r.Add(route[0], route[1], route[2]); err == nil {
+			t.Fatalf("%s %s: expected conflict error", route[1], route[2])
+		}
+	}
+
+	for _, c := range []struct {
+		method  string
+		path    string
+		name    string
+		allowed string
+	}{
+		{"GET", "orders/1", "getOrder", ""},
+		{"DELETE", "orders/1", "deleteOrder", ""},
+		{"PUT", "orders/1", "", "DELETE,GET"},
+		{"GET", "orders/1/items", "anyItem", ""},
+		{"POST", "orders/1/items", "anyItem", ""},
+		{"GET", "orders", "", ""},
+	} {
+		name, _, allowed := r.Match(c.method, c.path)
+		if name != c.name || strings.Join(allowed, ",") != c.allowed {
+			t.Fatalf("%s %s: unexpected route %q, allowed %v", c.method, c.path, name, allowed)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package util
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/dop251/goja/parser"
+)
+
+const inlineSourceMapPrefix = "//# sourceMappingURL=data:application/json"
+
+// 异常的调用栈中的一帧，位置已按源映射还原为源码（如 typescript）的位置，用于 IDE 定位
+type StackFrame struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`   // 行号，从 1 开始，0 表示原生方法
+	Column   int    `json:"column"` // 列号，从 1 开始
+}
+
+// 分离代码末尾内联的源映射，如旧版本中保存的编译结果、IDE 中执行（EVAL）的由浏览器编译的代码
+func SplitInlineSourceMap(code string) (string, string) {
+	i := strings.LastIndex(code, inlineSourceMapPrefix)
+	if i < 0 {
+		return code, ""
+	}
+	line := strings.TrimSpace(code[i:])
+	if strings.ContainsRune(line, '\n') { // 不在最后一行
+		return code, ""
+	}
+	b, err := base64.StdEncoding.DecodeString(line[strings.IndexByte(line, ',')+1:])
+	if err != nil {
+		return code, ""
+	}
+	return code[:i], string(b)
+}
+
+// 将源映射对应的代码整体下移若干行，即在 mappings 前补充空行，
+// 同时将源码的列号改为从 1 开始（go-sourcemap 返回的列号从 0 开始），源码的列号为相对值，只需修改第一段
+func ShiftSourceMap(sourceMap string, lines int) (string, error) {
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(sourceMap), &m); err != nil {
+		return "", err
+	}
+	mappings, ok := m["mappings"].(string)
+	if !ok {
+		return "", errors.New("invalid source map: mappings not found")
+	}
+	i := strings.IndexFunc(mappings, func(r rune) bool { return r != ';' })
+	if i >= 0 {
+		end := strings.IndexAny(mappings[i:], ",;")
+		if end < 0 {
+			end = len(mappings) - i
+		}
+		if fields := decodeVLQ(mappings[i : i+end]); len(fields) >= 4 {
+			fields[3]++
+			mappings = mappings[:i] + encodeVLQ(fields) + mappings[i+end:]
+		}
+	}
+	m["mappings"] = strings.Repeat(";", lines) + mappings
+	b, err := json.Marshal(m)
+	return string(b), err
+}
+
+const base64Chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// 解码 mappings 中的一段，见 https://sourcemaps.info/spec.html
+func decodeVLQ(segment string) []int {
+	fields := make([]int, 0, 5)
+	value, shift := 0, 0
+	for _, c := range segment {
+		digit := strings.IndexRune(base64Chars, c)
+		if digit < 0 {
+			return nil
+		}
+		value += (digit & 31) << shift
+		if digit&32 != 0 {
+			shift += 5
+			continue
+		}
+		if value&1 == 1 {
+			fields = append(fields, -(value >> 1))
+		} else {
+			fields = append(fields, value>>1)
+		}
+		value, shift = 0, 0
+	}
+	return fields
+}
+
+func encodeVLQ(fields []int) string {
+	var b strings.Builder
+	for _, field := range fields {
+		value := field << 1
+		if field < 0 {
+			value = (-field)<<1 | 1
+		}
+		for {
+			digit := value & 31
+			if value >>= 5; value > 0 {
+				digit |= 32
+			}
+			b.WriteByte(base64Chars[digit])
+			if value == 0 {
+				break
+			}
+		}
+	}
+	return b.String()
+}
+
+// 编译包装后的代码 prefix + code + suffix，异常的位置按源映射还原，prefix 中的换行将被计入源映射的偏移
+func CompileWithSourceMap(name string, prefix string, code string, suffix string, sourceMap string) (*goja.Program, error) {
+	src := prefix + code + suffix
+	options := []parser.Option{parser.WithDisableSourceMaps}
+	if sourceMap != "" {
+		if m, err := ShiftSourceMap(sourceMap, strings.Count(prefix, "\n")); err == nil {
+			src += "\n//# sourceMappingURL=" + name + ".map" // 须位于最后一行，由 loader 返回源映射
+			options = []parser.Option{parser.WithSourceMapLoader(func(string) ([]byte, error) {
+				return []byte(m), nil
+			})}
+		}
+	}
+	parsed, err := goja.Parse(name, src, options...)
+	if err != nil {
+		return nil, err
+	}
+	return goja.CompileAST(parsed, false)
+}
+
+// 如 "\tat foo (controller/foo.ts:3:9(12))"、"\tat <native>"、"\tat push (native)"
+var stackFramePattern = regexp.MustCompile(`^\s*at (?:(.*) \()?(.+?)(?::(\d+):(\d+)(?:\(\d+\))?)?\)?$`)
+
+// 获取异常的调用栈，支持 goja.Exception 和被拒绝的 Promise 的异常（见 PromiseRejection）
+func StackFrames(err error) []StackFrame {
+	var e *goja.Exception
+	if errors.As(err, &e) {
+		frames := make([]StackFrame, 0, len(e.Stack()))
+		for _, f := range e.Stack() {
+			p := f.Position()
+			frames = append(frames, StackFrame{Function: f.FuncName(), File: p.Filename, Line: p.Line, Column: p.Column})
+		}
+		return frames
+	}
+	var r *PromiseRejection
+	if errors.As(err, &r) {
+		return r.frames
+	}
+	return nil
+}
+
+// 解析 Error 对象的 stack 属性
+func ParseStack(stack string) []StackFrame {
+	frames := make([]StackFrame, 0)
+	for _, line := range strings.Split(stack, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "at ") {
+			continue
+		}
+		m := stackFramePattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		f := StackFrame{Function: m[1], File: m[2]}
+		f.Line, _ = strconv.Atoi(m[3])
+		f.Column, _ = strconv.Atoi(m[4])
+		if f.Function == "" && f.Line == 0 { // 原生方法，如 "<native>"
+			f.Function, f.File = f.File, ""
+		}
+		frames = append(frames, f)
+	}
+	return frames
+}
+
+// 被拒绝的 Promise 的异常，如异步 controller 中抛出的异常，异常信息和调用栈在创建时获取，因为 goja 运行时不能在其他协程中访问
+type PromiseRejection struct {
+	Value   goja.Value
+	message string
+	frames  []StackFrame
+}
+
+func NewPromiseRejection(value goja.Value) *PromiseRejection {
+	r := &PromiseRejection{Value: value, message: value.String()}
+	if o, ok := value.(*goja.Object); ok {
+		if stack := o.Get("stack"); stack != nil && !goja.IsUndefined(stack) {
+			r.frames = ParseStack(stack.String())
+		}
+	}
+	if len(r.frames) > 0 && r.frames[0].Line > 0 { // 与 goja.Exception 一致，包含抛出的位置
+		f := r.frames[0]
+		location := f.File + ":" + strconv.Itoa(f.Line) + ":" + strconv.Itoa(f.Column)
+		if f.Function != "" {
+			location = f.Function + " (" + location + ")"
+		}
+		r.message += " at " + location
+	}
+	return r
+}
+
+func (r *PromiseRejection) Error() string {
+	return r.message
+}
@@ -0,0 +1,80 @@
+package util
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+func TestSourceMap(t *testing.T) {
+	src := "type User = { name: string }\n\nexport function greet(user: User): string {\n    throw new Error(\"no \" + user.name)\n}\n"
+	result := api.Transform(src, api.TransformOptions{Loader: api.LoaderTS, Format: api.FormatCommonJS, Sourcefile: "foo.ts", Sourcemap: api.SourceMapExternal})
+	if len(result.Errors) > 0 {
+		t.Fatal(result.Errors[0].Text)
+	}
+	inline := string(result.Code) + inlineSourceMapPrefix + ";base64," + base64.StdEncoding.EncodeToString(result.Map) + "\n"
+
+	code, sourceMap := SplitInlineSourceMap(inline)
+	if code != string(result.Code) || sourceMap != string(result.Map) {
+		t.Fatalf("got %q, %q", code, sourceMap)
+	}
+	if code, sourceMap := SplitInlineSourceMap("f()\n//# sourceMappingURL=data:application/json;base64,e30=\ng()"); sourceMap != "" || code == "" {
+		t.Error("want a source map not on the last line ignored")
+	}
+
+	for _, c := range []struct {
+		name   string
+		prefix string
+		code   string
+	}{
+		{"external", "(function(exports, require, module) {\n", string(result.Code)},
+		{"inline", "(function(exports, require, module) {\n\n\n", code}, // 偏移多行
+	} {
+		program, err := CompileWithSourceMap("foo", c.prefix, c.code, "\n})", sourceMap)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vm := goja.New()
+		entry, err := vm.RunProgram(program)
+		if err != nil {
+			t.Fatal(err)
+		}
+		function, _ := goja.AssertFunction(entry)
+		exports, module := vm.NewObject(), vm.NewObject()
+		module.Set("exports", exports)
+		if _, err := function(exports, exports, goja.Undefined(), module); err != nil {
+			t.Fatal(err)
+		}
+		greet, _ := goja.AssertFunction(module.Get("exports").ToObject(vm).Get("greet"))
+		_, err = greet(nil, vm.ToValue(map[string]string{"name": "bar"}))
+
+		frames := StackFrames(err)
+		if len(frames) == 0 {
+			t.Fatalf("%s: got no stack frames from %v", c.name, err)
+		}
+		if f := frames[0]; f.File != "foo.ts" || f.Line != 4 || f.Column != 11 || f.Function != "greet" {
+			t.Errorf("%s: got %+v, want greet at foo.ts:4:11", c.name, f)
+		}
+	}
+
+	vm := goja.New()
+	value, err := vm.RunString("(async function foo() { await null; throw new Error('x') })()")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ExportGojaValue(value)
+	var r *PromiseRejection
+	if !errors.As(err, &r) || r.Error() != "Error: x at foo (<eval>:1:43)" {
+		t.Errorf("got %v", err)
+	}
+	if frames := StackFrames(err); len(frames) == 0 || frames[0] != (StackFrame{"foo", "<eval>", 1, 43}) {
+		t.Errorf("got %+v", frames)
+	}
+
+	if frames := ParseStack("Error: x\n\tat <native>\n\tat push (native)\n\tat foo.ts:2:3(4)\n"); len(frames) != 3 || frames[0].Function != "<native>" || frames[1] != (StackFrame{"push", "native", 0, 0}) || frames[2] != (StackFrame{"", "foo.ts", 2, 3}) {
+		t.Errorf("got %+v", frames)
+	}
+}
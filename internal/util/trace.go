@@ -0,0 +1,322 @@
+package util
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 链路追踪，调用链路通过 W3C Trace Context 的 traceparent 请求头传递，导出格式兼容 OpenTelemetry
+
+var traceparentPattern = regexp.MustCompile(`^([0-9a-f]{2})-([0-9a-f]{32})-([0-9a-f]{16})-([0-9a-f]{2})$`)
+
+const (
+	traceQueueSize = 2048 // 待导出的 span 队列的长度，队列已满时丢弃
+	traceBatchSize = 512
+	traceInterval  = 5 * time.Second
+)
+
+var MyTracer = &Tracer{}
+
+type Tracer struct {
+	sync.RWMutex
+	exporters []SpanExporter
+	queue     chan *Span
+}
+
+// span 的导出器，在单独的协程中批量导出
+type SpanExporter interface {
+	Export(spans []*Span) error
+}
+
+// 调用链路中的一个操作，如一次请求、一条 sql 语句的执行
+type Span struct {
+	sync.Mutex
+	TraceId       string                 `json:"traceId"`
+	SpanId        string                 `json:"spanId"`
+	ParentSpanId  string                 `json:"parentSpanId,omitempty"`
+	Name          string                 `json:"name"`
+	Kind          string                 `json:"kind"` // server、client、internal
+	Start         time.Time              `json:"start"`
+	End           time.Time              `json:"end"`
+	Attributes    map[string]interface{} `json:"attributes,omitempty"`
+	Status        string                 `json:"status"` // unset、ok、error
+	StatusMessage string                 `json:"statusMessage,omitempty"`
+	sampled       bool                   // 未采样的 span 仅用于传递调用链路，不导出
+	tracer        *Tracer
+}
+
+// 设置导出器并启动导出的协程，未设置导出器时不导出 span
+func (t *Tracer) SetExporters(exporters ...SpanExporter) {
+	t.Lock()
+	defer t.Unlock()
+	t.exporters = exporters
+	if len(exporters) > 0 && t.queue == nil {
+		t.queue = make(chan *Span, traceQueueSize)
+		go t.run(t.queue)
+	}
+}
+
+// 创建 span，parent 为空时开始一个新的调用链路
+func (t *Tracer) StartSpan(parent *Span, name string, kind string) *Span {
+	s := &Span{SpanId: randomHex(8), Name: name, Kind: kind, Start: time.Now(), Status: "unset", sampled: true, tracer: t}
+	if parent != nil {
+		s.TraceId, s.ParentSpanId, s.sampled = parent.TraceId, parent.SpanId, parent.sampled
+	} else {
+		s.TraceId = randomHex(16)
+	}
+	return s
+}
+
+// 延续上游传递的调用链路，traceparent 无效时开始一个新的调用链路
+func (t *Tracer) ContinueSpan(traceparent string, name string, kind string) *Span {
+	parent, err := ParseTraceparent(traceparent)
+	if err != nil {
+		return t.StartSpan(nil, name, kind)
+	}
+	return t.StartSpan(parent, name, kind)
+}
+
+// 解析 traceparent，如 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01，返回的 span 仅包含调用链路的信息
+func ParseTraceparent(traceparent string) (*Span, error) {
+	matches := traceparentPattern.FindStringSubmatch(traceparent)
+	if matches == nil || matches[1] == "ff" || matches[2] == "00000000000000000000000000000000" || matches[3] == "0000000000000000" {
+		return nil, errors.New("invalid traceparent: " + traceparent)
+	}
+	flags, _ := strconv.ParseUint(matches[4], 16, 8)
+	return &Span{TraceId: matches[2], SpanId: matches[3], sampled: flags&1 == 1}, nil
+}
+
+func (t *Tracer) run(queue chan *Span) {
+	ticker := time.NewTicker(traceInterval)
+	defer ticker.Stop()
+	spans := make([]*Span, 0, traceBatchSize)
+	for {
+		select {
+		case s := <-queue:
+			if spans = append(spans, s); len(spans) < traceBatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(spans) == 0 {
+				continue
+			}
+		}
+		t.export(spans)
+		spans = make([]*Span, 0, traceBatchSize)
+	}
+}
+
+func (t *Tracer) export(spans []*Span) {
+	t.RLock()
+	exporters := t.exporters
+	t.RUnlock()
+	for _, e := range exporters {
+		if err := e.Export(spans); err != nil {
+			MyLogger.Write(&LogEntry{Level: "warn", Worker: -1, Message: "failed to export spans: " + err.Error()})
+		}
+	}
+}
+
+// 以下方法在 span 为 nil 时不执行任何操作，用于不在调用链路中（如守护任务中）的自动埋点
+
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	if s.Attributes == nil {
+		s.Attributes = make(map[string]interface{})
+	}
+	s.Attributes[key] = value
+}
+
+// 标记为失败，err 为 nil 时忽略
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.Status, s.StatusMessage = "error", err.Error()
+}
+
+// 结束并导出，重复调用时忽略
+func (s *Span) Finish() {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	if !s.End.IsZero() {
+		s.Unlock()
+		return
+	}
+	s.End = time.Now()
+	s.Unlock()
+	if !s.sampled || s.tracer == nil {
+		return
+	}
+	s.tracer.RLock()
+	queue := s.tracer.queue
+	s.tracer.RUnlock()
+	if queue == nil {
+		return
+	}
+	select {
+	case queue <- s:
+	default:
+	}
+}
+
+// 用于向下游传递调用链路的请求头
+func (s *Span) Traceparent() string {
+	if s == nil {
+		return ""
+	}
+	flags := "00"
+	if s.sampled {
+		flags = "01"
+	}
+	return "00-" + s.TraceId + "-" + s.SpanId + "-" + flags
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+//#region 导出器
+
+// 以 JSON lines 的格式导出到文件，每行一个 span
+type FileSpanExporter struct {
+	w io.Writer
+}
+
+func NewFileSpanExporter(w io.Writer) *FileSpanExporter {
+	return &FileSpanExporter{w}
+}
+
+func (e *FileSpanExporter) Export(spans []*Span) error {
+	var b bytes.Buffer
+	for _, s := range spans {
+		s.Lock()
+		data, err := json.Marshal(s)
+		s.Unlock()
+		if err != nil {
+			return err
+		}
+		b.Write(append(data, '\n'))
+	}
+	_, err := e.w.Write(b.Bytes())
+	return err
+}
+
+// 以 OTLP/HTTP 的 JSON 格式导出到采集器，如 http://127.0.0.1:4318/v1/traces
+type OtlpSpanExporter struct {
+	endpoint string
+	service  string
+	client   *http.Client
+}
+
+func NewOtlpSpanExporter(endpoint string, service string) *OtlpSpanExporter {
+	return &OtlpSpanExporter{endpoint, service, &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (e *OtlpSpanExporter) Export(spans []*Span) error {
+	b, err := json.Marshal(OtlpTraces(e.service, spans))
+	if err != nil {
+		return err
+	}
+	resp, err := e.client.Post(e.endpoint, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return errors.New("otlp endpoint responded " + resp.Status)
+	}
+	return nil
+}
+
+var (
+	otlpKinds    = map[string]int{"internal": 1, "server": 2, "client": 3}
+	otlpStatuses = map[string]int{"unset": 0, "ok": 1, "error": 2}
+)
+
+// 转换为 OTLP 的 JSON 格式，见 https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+func OtlpTraces(service string, spans []*Span) map[string]interface{} {
+	items := make([]map[string]interface{}, 0, len(spans))
+	for _, s := range spans {
+		s.Lock()
+		item := map[string]interface{}{
+			"traceId":           s.TraceId,
+			"spanId":            s.SpanId,
+			"name":              s.Name,
+			"kind":              otlpKinds[s.Kind],
+			"startTimeUnixNano": strconv.FormatInt(s.Start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.End.UnixNano(), 10),
+			"attributes":        otlpAttributes(s.Attributes),
+			"status":            map[string]interface{}{"code": otlpStatuses[s.Status], "message": s.StatusMessage},
+		}
+		if s.ParentSpanId != "" {
+			item["parentSpanId"] = s.ParentSpanId
+		}
+		s.Unlock()
+		items = append(items, item)
+	}
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": otlpAttributes(map[string]interface{}{"service.name": service}),
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]interface{}{"name": "cube"},
+				"spans": items,
+			}},
+		}},
+	}
+}
+
+func otlpAttributes(attributes map[string]interface{}) []interface{} {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	items := make([]interface{}, 0, len(attributes))
+	for _, key := range keys {
+		value := attributes[key]
+		var v map[string]interface{}
+		switch a := value.(type) {
+		case string:
+			v = map[string]interface{}{"stringValue": a}
+		case bool:
+			v = map[string]interface{}{"boolValue": a}
+		case int:
+			v = map[string]interface{}{"intValue": strconv.Itoa(a)}
+		case int64:
+			v = map[string]interface{}{"intValue": strconv.FormatInt(a, 10)}
+		case float64:
+			v = map[string]interface{}{"doubleValue": a}
+		default:
+			v = map[string]interface{}{"stringValue": fmt.Sprint(a)}
+		}
+		items = append(items, map[string]interface{}{"key": key, "value": v})
+	}
+	return items
+}
+
+//#endregion
@@ -0,0 +1,87 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+type testSpanExporter struct {
+	spans []*Span
+}
+
+func (e *testSpanExporter) Export(spans []*Span) error {
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func TestTracer(t *testing.T) {
+	for _, c := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+	} {
+		if _, err := ParseTraceparent(c); err == nil {
+			t.Errorf("%q: want an error for an invalid traceparent", c)
+		}
+	}
+
+	tracer := &Tracer{}
+	root := tracer.ContinueSpan("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "GET /service/foo", "server")
+	if root.TraceId != "4bf92f3577b34da6a3ce929d0e0e4736" || root.ParentSpanId != "00f067aa0ba902b7" || len(root.SpanId) != 16 {
+		t.Errorf("want the incoming trace continued, got %+v", root)
+	}
+	child := tracer.StartSpan(root, "db query", "client")
+	if child.TraceId != root.TraceId || child.ParentSpanId != root.SpanId {
+		t.Errorf("want a child of the root span, got %+v", child)
+	}
+	if got, want := child.Traceparent(), "00-"+root.TraceId+"-"+child.SpanId+"-01"; got != want {
+		t.Errorf("got traceparent %q, want %q", got, want)
+	}
+	unsampled := tracer.ContinueSpan("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", "GET /service/foo", "server")
+	if !strings.HasSuffix(tracer.StartSpan(unsampled, "db query", "client").Traceparent(), "-00") {
+		t.Error("want the sampled flag inherited")
+	}
+	if fresh := tracer.ContinueSpan("invalid", "GET /service/foo", "server"); fresh.ParentSpanId != "" || len(fresh.TraceId) != 32 {
+		t.Errorf("want a new trace, got %+v", fresh)
+	}
+
+	var none *Span
+	none.SetAttribute("k", "v")
+	none.SetError(nil)
+	none.Finish()
+
+	child.SetAttribute("db.statement", "select 1")
+	child.SetAttribute("rows", int64(1))
+	child.SetError(bytes.ErrTooLarge)
+	child.Finish()
+	child.Finish()
+
+	e := &testSpanExporter{}
+	tracer.exporters, tracer.queue = []SpanExporter{e}, make(chan *Span, 2) // 不启动导出的协程，由测试导出
+	root.Finish()
+	unsampled.Finish()
+	if len(tracer.queue) != 1 { // 设置导出器之前结束的 span 和未采样的 span 不导出
+		t.Fatalf("got %d spans queued, want 1", len(tracer.queue))
+	}
+	tracer.export([]*Span{<-tracer.queue})
+	if len(e.spans) != 1 || e.spans[0] != root {
+		t.Errorf("want the root span exported, got %v", e.spans)
+	}
+
+	b, _ := json.Marshal(OtlpTraces("cube", []*Span{child}))
+	for _, want := range []string{
+		`"service.name","value":{"stringValue":"cube"}`,
+		`"parentSpanId":"` + root.SpanId + `"`,
+		`"kind":3`,
+		`"status":{"code":2,"message":"bytes.Buffer: too large"}`,
+		`{"key":"rows","value":{"intValue":"1"}}`,
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("want %s in %s", want, b)
+		}
+	}
+}
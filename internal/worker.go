@@ -2,35 +2,204 @@ package internal
 
 import (
 	"errors"
+	"fmt"
 	"path"
+	"runtime/metrics"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"cube/internal/builtin"
+	"cube/internal/config"
 	m "cube/internal/module"
+	"cube/internal/util"
 
 	"github.com/dop251/goja"
-	"github.com/dop251/goja/parser"
 )
 
 type Worker struct {
-	id       int
-	runtime  *goja.Runtime
-	function goja.Callable
-	defers   []func()
-	loop     *builtin.EventLoop // 事件循环
-	err      error              // 中断异常
+	id         int
+	runtime    *goja.Runtime
+	function   goja.Callable
+	defers     []func()
+	loop       *builtin.EventLoop // 事件循环
+	err        error              // 中断异常
+	executions int                // 累计执行的次数
+	allocs     uint64             // 累计执行期间分摊的堆内存分配量
+	task       atomic.Pointer[WorkerTask]
+	stopWatch  func()       // 停止监控执行预算
+	spans      []*util.Span // 调用链路中正在执行的 span，最后一个为当前的 span
+	lock       sync.Mutex   // 用于防止外部中断与重置同时发生，导致中断信号残留到下一次执行
 }
 
-func (w *Worker) Run(params ...goja.Value) (goja.Value, error) {
-	return w.loop.Run(func() (goja.Value, error) {
-		val, err := w.function(nil, params...)
-		if w.err != nil { // 优先返回 interrupt 的中断信息
-			return val, w.err
+// 实例正在执行的任务
+type WorkerTask struct {
+	Kind      string    `json:"kind"` // 任务类型：controller、daemon、crontab、job、hub、eval
+	Name      string    `json:"name"`
+	Path      string    `json:"path"`                // 请求路径，仅 controller 和 eval 有效
+	RequestId string    `json:"requestId,omitempty"` // 请求 id，仅 controller 有效
+	Start     util.Time `json:"start"`
+}
+
+func (w *Worker) SetTask(kind string, name string, path string, requestId string) {
+	w.task.Store(&WorkerTask{Kind: kind, Name: name, Path: path, RequestId: requestId, Start: util.Time(time.Now())})
+}
+
+// 获取正在执行的任务，空闲时返回 nil
+func (w *Worker) Task() *WorkerTask {
+	return w.task.Load()
+}
+
+// 创建日志，其来源为正在执行的任务
+func (w *Worker) NewLogEntry(level string) *util.LogEntry {
+	e := &util.LogEntry{Level: level, Worker: w.id}
+	if t := w.task.Load(); t != nil {
+		e.Kind, e.Source, e.RequestId = t.Kind, t.Name, t.RequestId
+	}
+	if s := w.Span(); s != nil {
+		e.TraceId = s.TraceId
+	}
+	return e
+}
+
+// 获取当前的 span，不在调用链路中时返回 nil
+func (w *Worker) Span() *util.Span {
+	if len(w.spans) == 0 {
+		return nil
+	}
+	return w.spans[len(w.spans)-1]
+}
+
+// 创建当前 span 的子 span，不在调用链路中时返回 nil
+func (w *Worker) StartSpan(name string, kind string) *util.Span {
+	parent := w.Span()
+	if parent == nil {
+		return nil
+	}
+	return util.MyTracer.StartSpan(parent, name, kind)
+}
+
+// 将 span 设置为当前的 span，之后创建的 span 均为其子 span
+func (w *Worker) PushSpan(s *util.Span) {
+	w.spans = append(w.spans, s)
+}
+
+// 移除 span 及在其之后设置的 span，如未结束的子 span
+func (w *Worker) PopSpan(s *util.Span) {
+	for i := len(w.spans) - 1; i >= 0; i-- {
+		if w.spans[i] == s {
+			w.spans = w.spans[:i]
+			return
 		}
-		return val, err
+	}
+}
+
+func (w *Worker) Executions() int {
+	return w.executions
+}
+
+func (w *Worker) Run(params ...goja.Value) (goja.Value, error) {
+	return w.run(func() (goja.Value, error) {
+		return w.function(nil, params...)
 	})
 }
 
+// 依次执行过滤器和目标模块，每个过滤器的入参为 (...params, next)，调用 next 方法将执行下一个过滤器或目标模块并返回其结果
+func (w *Worker) RunWithFilters(filters []string, id string, params ...goja.Value) (goja.Value, error) {
+	var next func(i int) func() (goja.Value, error)
+	next = func(i int) func() (goja.Value, error) {
+		return func() (goja.Value, error) {
+			if i == len(filters) {
+				return w.function(nil, append([]goja.Value{w.runtime.ToValue(id)}, params...)...)
+			}
+			return w.function(nil, append(append([]goja.Value{w.runtime.ToValue(filters[i])}, params...), w.runtime.ToValue(next(i+1)))...)
+		}
+	}
+
+	return w.run(next(0))
+}
+
+// 执行任意方法，用于在 vm 实例中执行编译后的代码，如 IDE 中执行（EVAL）的代码
+func (w *Worker) RunFunc(main func() (goja.Value, error)) (goja.Value, error) {
+	return w.run(main)
+}
+
+// 正在执行的实例的数量，用于分摊堆内存分配量
+var executing atomic.Int64
+
+func (w *Worker) run(main func() (goja.Value, error)) (goja.Value, error) {
+	w.executions++
+
+	// 执行预算，超出后中断执行，仅限于 controller 和 eval，守护任务、定时任务等长时间执行的任务不受限制
+	if task := w.task.Load(); config.Budget > 0 && task != nil && slices.Contains([]string{"controller", "eval"}, task.Kind) {
+		done := make(chan struct{})
+		w.stopWatch = sync.OnceFunc(func() {
+			close(done)
+		})
+		defer w.StopWatch()
+		go w.watch(task, time.Duration(config.Budget)*time.Millisecond, done)
+	}
+
+	// 统计执行期间的堆内存分配量，由于是进程级别的统计，按执行期间的最大并发数分摊
+	if config.MaxAllocs > 0 {
+		start, concurrency := heapAllocs(), executing.Add(1)
+		defer func() {
+			concurrency = max(concurrency, executing.Load())
+			executing.Add(-1)
+			w.allocs += (heapAllocs() - start) / uint64(concurrency)
+		}()
+	}
+
+	val, err := w.loop.Run(main)
+	if w.err != nil { // 优先返回 interrupt 的中断信息，中断可能发生在事件循环执行异步任务的过程中
+		return val, w.err
+	}
+	return val, err
+}
+
+// 停止监控执行预算，如升级为 WebSocket 或事件流后，连接保持期间不受执行预算的限制
+func (w *Worker) StopWatch() {
+	if w.stopWatch != nil {
+		w.stopWatch()
+	}
+}
+
+// 监控事件循环执行任务的时长，超出预算时中断执行，其中包含阻塞的 native 调用（如 db、http、文件读写）
+func (w *Worker) watch(task *WorkerTask, budget time.Duration, done chan struct{}) {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if w.loop.Elapsed() > budget {
+				w.interruptTask(task, fmt.Sprintf("execution budget exceeded: execution time over %dms", budget.Milliseconds()))
+				return
+			}
+		}
+	}
+}
+
+// 判断实例是否需要被回收，回收后将基于同一个 index 程序创建新的运行时，以清理脚本遗留在运行时上的全局变量等状态
+func (w *Worker) Expired() bool {
+	if config.MaxExecutions > 0 && w.executions >= config.MaxExecutions {
+		return true
+	}
+	if config.MaxAllocs > 0 && w.allocs >= uint64(config.MaxAllocs)<<20 {
+		return true
+	}
+	return false
+}
+
+func heapAllocs() uint64 {
+	sample := []metrics.Sample{{Name: "/gc/heap/allocs:bytes"}}
+	metrics.Read(sample)
+	return sample[0].Value.Uint64()
+}
+
 func (w *Worker) Id() int {
 	return w.id
 }
@@ -73,7 +242,37 @@ func (w *Worker) Interrupt(reason string) {
 	w.CleanDefers() // 这里清理句柄，用于防止阻塞，例如监听网络连接：在此时关闭监听器，可以使得监听方法出现异常，可以避免 goja 的中断信号无法被触发问题
 }
 
+// 中断正在执行的任务，如果实例空闲则返回 false
+func (w *Worker) InterruptTask(reason string) bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.task.Load() == nil {
+		return false
+	}
+	w.Interrupt(reason)
+	return true
+}
+
+// 仅当实例仍在执行该任务时中断，防止中断信号残留到下一次执行
+func (w *Worker) interruptTask(task *WorkerTask, reason string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.task.Load() == task {
+		w.Interrupt(reason)
+	}
+}
+
 func (w *Worker) Reset() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	// 清理任务
+	w.task.Store(nil)
+	w.spans = nil
+	w.stopWatch = nil
+
 	// 清理句柄
 	w.CleanDefers() // 用于非中断场景下的句柄清理
 
@@ -99,11 +298,16 @@ func CreateWorker(program *goja.Program, id int) *Worker {
 		panic("program is not a function")
 	}
 
-	worker := Worker{id, runtime, function, make([]func(), 0), builtin.NewEventLoop(), nil}
+	worker := Worker{id: id, runtime: runtime, function: function, defers: make([]func(), 0), loop: builtin.NewEventLoop()}
 
 	runtime.Set("require", func(id string) (goja.Value, error) {
 		program := Cache.Modules[id]
-		if program == nil { // 如果缓存不存在，则查询数据库
+		if program != nil {
+			moduleCache.Add(1, "hit")
+		} else { // 如果缓存不存在，则查询数据库
+			moduleCache.Add(1, "miss")
+			span := worker.StartSpan("require "+id, "internal")
+			span.SetAttribute("module.id", id)
 			// 获取名称、类型
 			var name, stype string
 			if strings.HasPrefix(id, "./controller/") {
@@ -112,29 +316,29 @@ func CreateWorker(program *goja.Program, id int) *Worker {
 				name, stype = id[9:], "daemon"
 			} else if strings.HasPrefix(id, "./crontab/") {
 				name, stype = id[10:], "crontab"
+			} else if strings.HasPrefix(id, "./filter/") {
+				name, stype = id[9:], "filter"
 			} else if strings.HasPrefix(id, "./") {
 				name, stype = path.Clean(id), "module"
 			} else { // 如果没有 "./" 前缀，则视为 node_modules
 				name, stype = "node_modules/"+id, "module"
 			}
 
-			// 根据名称查找源码
-			var src string
-			if err := Db.QueryRow("select compiled from source where name = ? and type = ? and active = true", name, stype).Scan(&src); err != nil {
+			// 根据名称查找源码和源映射，旧版本中源映射内联在编译结果中
+			var src, sourceMap string
+			if err := Db.QueryRow("select compiled, source_map from source where name = ? and type = ? and active = true", name, stype).Scan(&src, &sourceMap); err != nil {
+				span.SetError(err)
+				span.Finish()
 				return nil, err
 			}
-			// 编译
-			parsed, err := goja.Parse(
-				name,
-				"(function(exports, require, module) {"+src+"\n})",
-				parser.WithSourceMapLoader(func(p string) ([]byte, error) {
-					return []byte(src), nil
-				}),
-			)
-			if err != nil {
-				return nil, err
+			if sourceMap == "" {
+				src, sourceMap = util.SplitInlineSourceMap(src)
 			}
-			program, err = goja.CompileAST(parsed, false)
+			// 编译，包装代码单独占一行，使源码的列号不受影响
+			var err error
+			program, err = util.CompileWithSourceMap(name, "(function(exports, require, module) {\n", src, "\n})", sourceMap)
+			span.SetError(err)
+			span.Finish()
 			if err != nil {
 				return nil, err
 			}
@@ -1,30 +1,207 @@
 package internal
 
 import (
+	"container/list"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
 	"cube/internal/config"
+
 	"github.com/dop251/goja"
 )
 
-var WorkerPool struct {
-	Channels chan *Worker
-	Workers  []*Worker
+var (
+	ErrWorkerPoolQueueFull   = errors.New("worker pool queue is full")
+	ErrWorkerPoolWaitTimeout = errors.New("worker pool wait timeout")
+)
+
+type workerPool struct {
+	program *goja.Program
+	lock    sync.Mutex
+	workers map[int]*Worker // 已创建的所有实例
+	idles   []*Worker       // 空闲的实例，按归还的先后顺序排列
+	idleAt  []time.Time     // 空闲实例的归还时间
+	waiters *list.List      // 等待实例的请求队列，元素类型为 chan *Worker
+	size    int             // 已创建的实例个数
+	serial  int             // 实例的序号
+	waited  int64           // 累计等待的请求个数
+	wait    time.Duration
 }
 
-func InitWorkerPool() {
-	WorkerPool.Workers = make([]*Worker, config.Count) // 创建 goja 实例池
-	WorkerPool.Channels = make(chan *Worker, config.Count)
+type WorkerPoolStats struct {
+	Size     int           `json:"size"`      // 已创建的实例个数
+	Idle     int           `json:"idle"`      // 空闲的实例个数
+	Queue    int           `json:"queue"`     // 等待中的请求个数
+	Waited   int64         `json:"waited"`    // 累计等待的请求个数
+	WaitTime time.Duration `json:"wait_time"` // 累计等待的时长，单位纳秒
+}
+
+var WorkerPool = &workerPool{workers: make(map[int]*Worker), waiters: list.New()}
 
+func InitWorkerPool() {
 	// 编译源码
-	program, _ := goja.Compile(
+	WorkerPool.program, _ = goja.Compile(
 		"index",
 		"(function (id, ...params) { return require(id).default(...params); })", // 使用闭包，防止全局变量污染
 		false, // 关闭严格模式，增加运行时的容错能力
 	)
 
-	for i := 0; i < config.Count; i++ {
-		worker := CreateWorker(program, i) // 创建 goja 运行时
+	// 预先创建最少个数的实例
+	for i := 0; i < config.MinCount; i++ {
+		WorkerPool.size++
+		WorkerPool.Release(WorkerPool.create())
+	}
+
+	// 定时回收空闲超时的实例
+	go func() {
+		for range time.Tick(time.Second) {
+			WorkerPool.shrink()
+		}
+	}()
+}
+
+func (p *workerPool) create() *Worker {
+	p.lock.Lock()
+	p.serial++
+	id := p.serial - 1
+	p.lock.Unlock()
+
+	worker := CreateWorker(p.program, id) // 创建 goja 运行时
+
+	p.lock.Lock()
+	p.workers[id] = worker
+	p.lock.Unlock()
+
+	return worker
+}
+
+// 获取实例：如果无空闲实例且实例个数未达上限，则创建新的实例，否则进入等待队列
+// timeout 为负数时表示不限等待时长且不受队列长度的限制，用于守护任务、定时任务等后台任务
+func (p *workerPool) Acquire(timeout time.Duration) (*Worker, error) {
+	p.lock.Lock()
+
+	if n := len(p.idles); n > 0 { // 优先复用最近归还的实例，使得长时间空闲的实例可以被回收
+		worker := p.idles[n-1]
+		p.idles, p.idleAt = p.idles[:n-1], p.idleAt[:n-1]
+		p.lock.Unlock()
+		return worker, nil
+	}
+
+	if p.size < config.Count {
+		p.size++
+		p.lock.Unlock()
+		return p.create(), nil
+	}
+
+	if timeout >= 0 && p.waiters.Len() >= config.QueueSize {
+		p.lock.Unlock()
+		return nil, ErrWorkerPoolQueueFull
+	}
+
+	c := make(chan *Worker, 1)
+	e := p.waiters.PushBack(c)
+	p.lock.Unlock()
+
+	start := time.Now()
+	defer func() {
+		p.lock.Lock()
+		p.waited++
+		p.wait += time.Since(start)
+		p.lock.Unlock()
+	}()
 
-		WorkerPool.Workers[i] = worker
-		WorkerPool.Channels <- worker
+	if timeout < 0 {
+		return <-c, nil
 	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case worker := <-c:
+		return worker, nil
+	case <-timer.C:
+		p.lock.Lock()
+		defer p.lock.Unlock()
+		select {
+		case worker := <-c: // 超时的同时已被分配了实例
+			return worker, nil
+		default:
+			p.waiters.Remove(e)
+			return nil, ErrWorkerPoolWaitTimeout
+		}
+	}
+}
+
+// 归还实例：优先分配给等待队列中的首个请求
+func (p *workerPool) Release(worker *Worker) {
+	if worker.Expired() { // 执行次数或内存估算超出阈值，替换为新的实例
+		p.lock.Lock()
+		delete(p.workers, worker.id)
+		p.lock.Unlock()
+		worker = p.create()
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if e := p.waiters.Front(); e != nil {
+		p.waiters.Remove(e)
+		e.Value.(chan *Worker) <- worker
+		return
+	}
+
+	p.idles = append(p.idles, worker)
+	p.idleAt = append(p.idleAt, time.Now())
+}
+
+// 回收空闲超过指定时长的实例，保留最少个数的实例
+func (p *workerPool) shrink() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	n := 0
+	for n < len(p.idles) && p.size-n > config.MinCount && time.Since(p.idleAt[n]) > time.Duration(config.IdleTimeout)*time.Millisecond {
+		delete(p.workers, p.idles[n].id)
+		p.idles[n] = nil
+		n++
+	}
+	p.idles, p.idleAt = p.idles[n:], p.idleAt[n:]
+	p.size -= n
+}
+
+func (p *workerPool) Stats() WorkerPoolStats {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return WorkerPoolStats{
+		Size:     p.size,
+		Idle:     len(p.idles),
+		Queue:    p.waiters.Len(),
+		Waited:   p.waited,
+		WaitTime: p.wait,
+	}
+}
+
+// 获取所有的实例，按序号排列
+func (p *workerPool) Workers() []*Worker {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	workers := make([]*Worker, 0, len(p.workers))
+	for _, w := range p.workers {
+		workers = append(workers, w)
+	}
+	sort.Slice(workers, func(i, j int) bool {
+		return workers[i].id < workers[j].id
+	})
+	return workers
+}
+
+func (p *workerPool) Get(id int) *Worker {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.workers[id]
 }
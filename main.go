@@ -25,6 +25,9 @@ func init() {
 	// 初始化日志文件
 	InitLog()
 
+	// 初始化链路追踪
+	InitTrace()
+
 	// 初始化缓存
 	InitCache()
 
@@ -45,6 +48,9 @@ func main() {
 	// 启动定时服务
 	RunCrontabs("")
 
+	// 启动任务调度
+	go RunJobs()
+
 	// 启动服务
 	serve()
 }
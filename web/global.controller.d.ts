@@ -1,8 +1,33 @@
 //#region service
 
+interface EventStream {
+    "Native Event Stream"; /* it is not allowed to create it by yourself */
+    /** the Last-Event-ID header sent by a reconnecting client, or the lastEventId query parameter */
+    lastEventId: string;
+    /**
+     * send an event, strings are sent as is and other values as json
+     *
+     * @param event the event name, null for the default "message" event
+     */
+    send(event: string | null, data: any, id?: string): void;
+    comment(text: string): void;
+    /** tell the client how long to wait in milliseconds before reconnecting */
+    retry(interval: number): void;
+    /** forward the topics of $native("event") as events with the same names */
+    subscribe(...topics: string[]): void;
+    isClosed(): boolean;
+    close(): void;
+    /** called when the client disconnects or close() is called */
+    onclose: (() => void) | null;
+}
+
 interface ServiceContext {
     "Native Service Context"; /* it is not allowed to create it by yourself */
     getHeader(): { [name: string]: string; };
+    setHeader(name: string, value: string): void;
+    setResponseHeader(name: string, value: string): void;
+    getAttribute(name: string): any;
+    setAttribute(name: string, value: any): void;
     getURL(): { path: string; params: { [name: string]: string[]; }; };
     getBody(): Buffer;
     getMethod(): "GET" | "POST" | "PUT" | "DELETE";
@@ -11,7 +36,13 @@ interface ServiceContext {
     getFile(name: string): { name: string; size: number; data: Buffer; };
     getCerts(): any[];
     getCookie(name: string): { value: string; };
-    upgradeToWebSocket(): WebSocket;
+    upgradeToWebSocket(options?: Omit<WebSocketOptions, "headers">): WebSocket;
+    /**
+     * respond with text/event-stream, the controller returns once the client disconnects or the stream is closed
+     *
+     * @param options retry hint in milliseconds sent on connect, and the interval of comment heartbeats in milliseconds (15000 by default, 0 to disable)
+     */
+    upgradeToEventStream(options?: { retry?: number; heartbeat?: number; }): EventStream;
     getReader(): { readByte(): number; read(count: number): Buffer; };
     getPusher(): { push(target: string, options: any): void; };
     write(data: GenericByteArray): number;
@@ -2,16 +2,109 @@ type GenericByteArray = string | Uint8Array | Array<number> | Buffer
 
 //#region builtin
 
+type BufferEncoding = "utf8" | "utf-8" | "hex" | "base64" | "base64url" | "latin1" | "binary" | "ascii" | "ucs2" | "ucs-2" | "utf16le" | "utf-16le"
+/** unlike Node.js, a Buffer is not a subclass of Uint8Array, so test it with Buffer.isBuffer rather than instanceof */
 declare interface Buffer extends Array<number> {
-    toString(encoding?: "utf8" | "hex" | "base64" | "base64url"): string;
+    toString(encoding?: BufferEncoding, start?: number, end?: number): string;
     toJson(): any;
+    /** write a string at the offset, returns the number of bytes written */
+    write(string: string, offset?: number, length?: number, encoding?: BufferEncoding): number;
+    write(string: string, encoding?: BufferEncoding): number;
+    /** returns a buffer which shares the same memory, negative indexes count back from the end */
+    slice(start?: number, end?: number): Buffer;
+    subarray(start?: number, end?: number): Buffer;
+    equals(other: Buffer | Uint8Array): boolean;
+    compare(target: Buffer | Uint8Array): -1 | 0 | 1;
+    indexOf(value: string | number | Buffer | Uint8Array, byteOffset?: number, encoding?: BufferEncoding): number;
+    lastIndexOf(value: string | number | Buffer | Uint8Array, byteOffset?: number, encoding?: BufferEncoding): number;
+    includes(value: string | number | Buffer | Uint8Array, byteOffset?: number, encoding?: BufferEncoding): boolean;
+    fill(value: string | number | Buffer | Uint8Array, offset?: number, end?: number, encoding?: BufferEncoding): Buffer;
+    /** returns the number of bytes copied */
+    copy(target: Buffer | Uint8Array, targetStart?: number, sourceStart?: number, sourceEnd?: number): number;
+    readUInt8(offset?: number): number;
+    readUInt16LE(offset?: number): number;
+    readUInt16BE(offset?: number): number;
+    readUInt32LE(offset?: number): number;
+    readUInt32BE(offset?: number): number;
+    readInt8(offset?: number): number;
+    readInt16LE(offset?: number): number;
+    readInt16BE(offset?: number): number;
+    readInt32LE(offset?: number): number;
+    readInt32BE(offset?: number): number;
+    readBigUInt64LE(offset?: number): bigint;
+    readBigUInt64BE(offset?: number): bigint;
+    readBigInt64LE(offset?: number): bigint;
+    readBigInt64BE(offset?: number): bigint;
+    readFloatLE(offset?: number): number;
+    readFloatBE(offset?: number): number;
+    readDoubleLE(offset?: number): number;
+    readDoubleBE(offset?: number): number;
+    /** read an integer of 1 to 6 bytes */
+    readUIntLE(offset: number, byteLength: number): number;
+    readUIntBE(offset: number, byteLength: number): number;
+    readIntLE(offset: number, byteLength: number): number;
+    readIntBE(offset: number, byteLength: number): number;
+    /** the write methods return the offset plus the number of bytes written */
+    writeUInt8(value: number, offset?: number): number;
+    writeUInt16LE(value: number, offset?: number): number;
+    writeUInt16BE(value: number, offset?: number): number;
+    writeUInt32LE(value: number, offset?: number): number;
+    writeUInt32BE(value: number, offset?: number): number;
+    writeInt8(value: number, offset?: number): number;
+    writeInt16LE(value: number, offset?: number): number;
+    writeInt16BE(value: number, offset?: number): number;
+    writeInt32LE(value: number, offset?: number): number;
+    writeInt32BE(value: number, offset?: number): number;
+    writeBigUInt64LE(value: bigint, offset?: number): number;
+    writeBigUInt64BE(value: bigint, offset?: number): number;
+    writeBigInt64LE(value: bigint, offset?: number): number;
+    writeBigInt64BE(value: bigint, offset?: number): number;
+    writeFloatLE(value: number, offset?: number): number;
+    writeFloatBE(value: number, offset?: number): number;
+    writeDoubleLE(value: number, offset?: number): number;
+    writeDoubleBE(value: number, offset?: number): number;
+    writeUIntLE(value: number, offset: number, byteLength: number): number;
+    writeUIntBE(value: number, offset: number, byteLength: number): number;
+    writeIntLE(value: number, offset: number, byteLength: number): number;
+    writeIntBE(value: number, offset: number, byteLength: number): number;
+    /* the Uint aliases of the UInt methods */
+    readUint8: Buffer["readUInt8"];
+    readUint16LE: Buffer["readUInt16LE"];
+    readUint16BE: Buffer["readUInt16BE"];
+    readUint32LE: Buffer["readUInt32LE"];
+    readUint32BE: Buffer["readUInt32BE"];
+    readUintLE: Buffer["readUIntLE"];
+    readUintBE: Buffer["readUIntBE"];
+    readBigUint64LE: Buffer["readBigUInt64LE"];
+    readBigUint64BE: Buffer["readBigUInt64BE"];
+    writeUint8: Buffer["writeUInt8"];
+    writeUint16LE: Buffer["writeUInt16LE"];
+    writeUint16BE: Buffer["writeUInt16BE"];
+    writeUint32LE: Buffer["writeUInt32LE"];
+    writeUint32BE: Buffer["writeUInt32BE"];
+    writeUintLE: Buffer["writeUIntLE"];
+    writeUintBE: Buffer["writeUIntBE"];
+    writeBigUint64LE: Buffer["writeBigUInt64LE"];
+    writeBigUint64BE: Buffer["writeBigUInt64BE"];
 }
 declare interface BufferConstructor {
-    from(input: GenericByteArray, encoding?: "utf8" | "hex" | "base64" | "base64url"): Buffer;
+    /** strings are decoded with the encoding, an ArrayBuffer shares its memory, and other inputs are copied */
+    from(input: GenericByteArray | ArrayBuffer, encoding?: BufferEncoding): Buffer;
+    from(input: ArrayBuffer, byteOffset?: number, length?: number): Buffer;
+    alloc(size: number, fill?: string | number | Buffer | Uint8Array, encoding?: BufferEncoding): Buffer;
+    allocUnsafe(size: number): Buffer;
+    /** join the buffers, and truncate or zero-fill to the total length if given */
+    concat(list: (Buffer | Uint8Array)[], totalLength?: number): Buffer;
+    compare(a: Buffer | Uint8Array, b: Buffer | Uint8Array): -1 | 0 | 1;
+    isBuffer(value: any): value is Buffer;
+    isEncoding(encoding: string): encoding is BufferEncoding;
+    byteLength(value: string | Buffer | Uint8Array | ArrayBuffer, encoding?: BufferEncoding): number;
 }
 declare var Buffer: BufferConstructor;
 
+/** the logs are written as JSON lines, and when the first argument is a string, a trailing plain object is kept as structured fields, e.g. console.info("order paid", { orderId }) */
 interface Console {
+    /** the same as info */
     log(...data: any[]): void;
     debug(...data: any[]): void;
     info(...data: any[]): void;
@@ -20,23 +113,55 @@ interface Console {
 }
 declare var console: Console;
 
+interface DateOptions {
+    /** IANA time zone such as "Asia/Shanghai", "UTC" or a fixed offset such as "+08:00", the local time zone by default */
+    timeZone?: string;
+    /** locale of the month, weekday, day period and era names, supports en, zh, ja, ko, de, fr and es, "en-US" by default */
+    locale?: string;
+}
 interface Date {
-    toString(layout?: string): string
+    /**
+     * the layout uses the LDML tokens: y, M, d, E, a, H, h, m, s, S, G, Z, X, z, V, and text in single quotes is kept as it is
+     * e.g. "yyyy-MM-dd HH:mm:ss.SSS XXX", "EEEE, MMMM d, y h:mm a"
+     */
+    toString(layout?: string, options?: DateOptions): string
 }
 interface DateConstructor {
-    toDate(value: string, layout: string): Date
+    /** the time is in options.timeZone unless the value contains a zone (Z, X, z or V), throws an error reporting the token that cannot be parsed */
+    toDate(value: string, layout: string, options?: DateOptions): Date
 }
 
+type DecimalValue = Decimal | string | number | bigint
+/** up and down round away from and towards zero, ceil and floor towards positive and negative infinity, and the half_* modes round to the nearest neighbour */
+type DecimalRounding = "up" | "down" | "ceil" | "floor" | "half_up" | "half_down" | "half_even"
 declare class Decimal {
-    constructor(value: string);
-    add(value: Decimal): Decimal;
-    sub(value: Decimal): Decimal;
-    mul(value: Decimal): Decimal;
-    div(value: Decimal): Decimal;
-    pow(value: Decimal): Decimal;
-    mod(value: Decimal): Decimal;
-    compare(value: Decimal): -1 | 0 | 1;
+    /** numbers are converted by their shortest decimal representation, such as 0.1 to "0.1" */
+    constructor(value: DecimalValue);
+    /** the result is rounded to the scale with the rounding mode ("half_up" by default) if the scale is given */
+    add(value: DecimalValue, scale?: number, rounding?: DecimalRounding): Decimal;
+    sub(value: DecimalValue, scale?: number, rounding?: DecimalRounding): Decimal;
+    mul(value: DecimalValue, scale?: number, rounding?: DecimalRounding): Decimal;
+    /** the scale is 16 by default */
+    div(value: DecimalValue, scale?: number, rounding?: DecimalRounding): Decimal;
+    pow(value: DecimalValue): Decimal;
+    mod(value: DecimalValue): Decimal;
+    neg(): Decimal;
     abs(): Decimal;
+    round(scale: number, rounding?: DecimalRounding): Decimal;
+    compare(value: DecimalValue): -1 | 0 | 1;
+    equals(value: DecimalValue): boolean;
+    lt(value: DecimalValue): boolean;
+    lte(value: DecimalValue): boolean;
+    gt(value: DecimalValue): boolean;
+    gte(value: DecimalValue): boolean;
+    sign(): -1 | 0 | 1;
+    isZero(): boolean;
+    /** keep exactly the number of decimal places, padded with zeros */
+    toFixed(places: number, rounding?: DecimalRounding): string;
+    toNumber(): number;
+    toString(): string;
+    toJSON(): string;
+    valueOf(): string;
     string(): string;
     stringFixed(places: number): string;
 }
@@ -48,16 +173,204 @@ interface TimeoutId { "Native Interval Id"; }
 declare function setTimeout(handler: Function, timeout?: number, ...arguments: any[]): TimeoutId;
 declare function clearTimeout(id: TimeoutId): void;
 
-declare function fetch(url: string, options?: { method?: "GET" | "POST" | "PUT" | "DELETE"; headers?: { [name: string]: string }; body?: string; }): Promise<{ status: number; headers: { [name: string]: string }; buffer(): Buffer; json(): any; text(): string; }>;
+declare class AbortSignal {
+    private constructor();
+    static abort(reason?: any): AbortSignal;
+    static timeout(milliseconds: number): AbortSignal;
+    readonly aborted: boolean;
+    readonly reason: any;
+    onabort: ((event: { type: "abort"; target: AbortSignal; }) => void) | null;
+    throwIfAborted(): void;
+    addEventListener(type: "abort", listener: (event: { type: "abort"; target: AbortSignal; }) => void): void;
+    removeEventListener(type: "abort", listener: (event: { type: "abort"; target: AbortSignal; }) => void): void;
+}
+declare class AbortController {
+    readonly signal: AbortSignal;
+    abort(reason?: any): void;
+}
+
+declare class URL {
+    constructor(url: string, base?: string | URL);
+    static canParse(url: string, base?: string | URL): boolean;
+    static parse(url: string, base?: string | URL): URL | null;
+    href: string;
+    readonly origin: string;
+    protocol: string;
+    username: string;
+    password: string;
+    host: string;
+    hostname: string;
+    port: string;
+    pathname: string;
+    search: string;
+    hash: string;
+    readonly searchParams: URLSearchParams;
+    toString(): string;
+    toJSON(): string;
+}
+
+declare class URLSearchParams {
+    constructor(init?: string | URLSearchParams | [string, string][] | { [name: string]: string });
+    readonly size: number;
+    append(name: string, value: string): void;
+    delete(name: string, value?: string): void;
+    get(name: string): string | null;
+    getAll(name: string): string[];
+    has(name: string, value?: string): boolean;
+    set(name: string, value: string): void;
+    sort(): void;
+    keys(): string[];
+    values(): string[];
+    entries(): [string, string][];
+    forEach(callback: (value: string, name: string) => void): void;
+    toString(): string;
+}
+
+type FormDataEntryValue = string | { name: string; size: number; data: Buffer; }
+declare class FormData {
+    append(name: string, value: string | Uint8Array | ArrayBuffer | Buffer, filename?: string): void;
+    set(name: string, value: string | Uint8Array | ArrayBuffer | Buffer, filename?: string): void;
+    delete(name: string): void;
+    get(name: string): FormDataEntryValue | null;
+    getAll(name: string): FormDataEntryValue[];
+    has(name: string): boolean;
+    keys(): string[];
+    entries(): [string, FormDataEntryValue][];
+    forEach(callback: (value: FormDataEntryValue, name: string) => void): void;
+}
+
+interface ReadableStreamDefaultReader {
+    read(): Promise<{ done: false; value: Uint8Array; } | { done: true; value: undefined; }>;
+    cancel(): Promise<void>;
+    releaseLock(): void;
+}
+interface ReadableStream {
+    readonly locked: boolean;
+    getReader(): ReadableStreamDefaultReader;
+    cancel(): Promise<void>;
+}
+
+type HeadersInit = Headers | [string, string][] | { [name: string]: string }
+declare class Headers {
+    constructor(init?: HeadersInit);
+    append(name: string, value: string): void;
+    set(name: string, value: string): void;
+    get(name: string): string | null;
+    getSetCookie(): string[];
+    has(name: string): boolean;
+    delete(name: string): void;
+    keys(): string[];
+    values(): string[];
+    entries(): [string, string][];
+    forEach(callback: (value: string, name: string) => void): void;
+}
+
+type BodyInit = string | Uint8Array | ArrayBuffer | Buffer | URLSearchParams | FormData | ReadableStream
+interface Body {
+    readonly body: ReadableStream | null;
+    readonly bodyUsed: boolean;
+    text(): Promise<string>;
+    json(): Promise<any>;
+    arrayBuffer(): Promise<ArrayBuffer>;
+    bytes(): Promise<Uint8Array>;
+    buffer(): Promise<Buffer>;
+}
+interface RequestInit {
+    method?: string;
+    headers?: HeadersInit;
+    body?: BodyInit | null;
+    redirect?: "follow" | "manual" | "error";
+    signal?: AbortSignal | null;
+    timeout?: number; // 超时时间，单位毫秒
+}
+declare class Request implements Body {
+    constructor(input: string | Request, init?: RequestInit);
+    readonly method: string;
+    readonly url: string;
+    readonly headers: Headers;
+    readonly redirect: "follow" | "manual" | "error";
+    readonly signal: AbortSignal | null;
+    readonly body: ReadableStream | null;
+    readonly bodyUsed: boolean;
+    text(): Promise<string>;
+    json(): Promise<any>;
+    arrayBuffer(): Promise<ArrayBuffer>;
+    bytes(): Promise<Uint8Array>;
+    buffer(): Promise<Buffer>;
+}
+declare class Response implements Body {
+    constructor(body?: BodyInit | null, init?: { status?: number; statusText?: string; headers?: HeadersInit; });
+    readonly status: number;
+    readonly statusText: string;
+    readonly ok: boolean;
+    readonly headers: Headers;
+    readonly url: string;
+    readonly redirected: boolean;
+    readonly type: "basic" | "default";
+    readonly body: ReadableStream | null;
+    readonly bodyUsed: boolean;
+    text(): Promise<string>;
+    json(): Promise<any>;
+    arrayBuffer(): Promise<ArrayBuffer>;
+    bytes(): Promise<Uint8Array>;
+    buffer(): Promise<Buffer>;
+}
+declare function fetch(input: string | Request, init?: RequestInit): Promise<Response>;
+
+declare class TextEncoder {
+    readonly encoding: "utf-8";
+    encode(input?: string): Uint8Array;
+    encodeInto(source: string, destination: Uint8Array): { read: number; written: number; };
+}
+declare class TextDecoder {
+    constructor(label?: "utf-8" | "utf-16le" | "utf-16be" | "gbk" | "gb18030" | "big5" | "shift_jis" | "euc-kr" | string, options?: { fatal?: boolean; ignoreBOM?: boolean; });
+    readonly encoding: string;
+    readonly fatal: boolean;
+    readonly ignoreBOM: boolean;
+    decode(input?: Uint8Array | ArrayBuffer | Buffer, options?: { stream?: boolean; }): string;
+}
+
+declare function atob(data: string): string;
+declare function btoa(data: string): string;
+declare function structuredClone<T = any>(value: T): T;
+declare function queueMicrotask(callback: () => void): void;
 
+declare var crypto: {
+    getRandomValues<T extends Int8Array | Uint8Array | Uint8ClampedArray | Int16Array | Uint16Array | Int32Array | Uint32Array | BigInt64Array | BigUint64Array>(array: T): T;
+    randomUUID(): string;
+};
+
+interface WebSocketOptions {
+    protocols?: string[]; // subprotocols, the server selects the first one that the client also supports
+    compression?: boolean; // permessage-deflate, enabled by default
+    pingInterval?: number; // send a ping every interval milliseconds, the connection is closed if nothing is received within 2 intervals
+    readLimit?: number; // maximum size in bytes of a message
+    headers?: { [name: string]: string }; // handshake headers, client only
+}
 interface WebSocket {
-    read(): { messageType: number; data: Buffer; };
-    send(data: GenericByteArray);
-    close();
+    readonly url?: string;
+    readonly readyState: 0 | 1 | 2 | 3;
+    readonly protocol: string;
+    readonly extensions: string;
+    onopen: ((event: { type: "open"; }) => void) | null;
+    onmessage: ((event: { type: "message"; data: string | Buffer; binary: boolean; }) => void) | null;
+    onclose: ((event: { type: "close"; code: number; reason: string; wasClean: boolean; }) => void) | null;
+    onerror: ((event: { type: "error"; message: string; }) => void) | null;
+    /** @deprecated blocking read, use onmessage instead */
+    read(): { messageType: 1 | 2; data: Buffer; };
+    send(data: string | Uint8Array | ArrayBuffer | Buffer | Array<number>): void;
+    sendText(text: string): void;
+    sendBinary(data: GenericByteArray): void;
+    ping(data?: GenericByteArray): void;
+    close(code?: number, reason?: string): void;
 }
 declare var WebSocket: {
     prototype: WebSocket;
-    new(url: string): WebSocket;
+    new(url: string, protocols?: string | string[], options?: Omit<WebSocketOptions, "protocols">): WebSocket;
+    readonly CONNECTING: 0;
+    readonly OPEN: 1;
+    readonly CLOSING: 2;
+    readonly CLOSED: 3;
 }
 
 //#endregion
@@ -161,6 +474,51 @@ declare function $native(name: "http"): (options?: HttpOptions) => {
     toFormData(data: { [name: string]: string | { filename: string; data: GenericByteArray; }; }): FormData;
 }
 
+type HubPresence = {
+    id: string;
+    metadata: any;
+    rooms: string[];
+    joinedAt: string;
+}
+type HubEvent = {
+    type: "message" | "close";
+    /** the id of the connection */
+    id: string;
+    metadata: any;
+    /** a string for text frames and a Buffer for binary frames, only for message events */
+    data?: string | Buffer;
+    binary?: boolean;
+    /** only for close events */
+    code?: number;
+    reason?: string;
+}
+declare function $native(name: "hub"): {
+    /**
+     * join the websocket into a room, returns the id of the connection which stays the same for further joins
+     * the hub then owns the connection, and the worker is released once the controller returns
+     *
+     * @param metadata replaces the metadata of the connection if given
+     * @param handler a module whose default export is called with a HubEvent in another worker on each message and on close, e.g. "./chat"
+     */
+    join(ws: WebSocket, room: string, metadata?: any, handler?: string): string;
+    /**
+     * leave the room, or all rooms if the room is not given
+     */
+    leave(id: string, room?: string): void;
+    /**
+     * send data to all connections in the room, returns the number of connections the data is queued to
+     *
+     * @param data strings are sent as text frames, byte arrays as binary frames, and other values as json
+     */
+    broadcast(room: string, data: any, options?: { except?: string[]; }): number;
+    send(id: string, data: any): boolean;
+    disconnect(id: string, code?: number, reason?: string): boolean;
+    get(id: string): HubPresence | null;
+    setMetadata(id: string, metadata: any): boolean;
+    presence(room: string): HubPresence[];
+    rooms(): { name: string; size: number; }[];
+}
+
 type Image = {
     width(): number;
     height(): number;
@@ -184,11 +542,56 @@ declare function $native(name: "image"): {
     parse(input: GenericByteArray): Image;
 }
 
+declare function $native(name: "jobs"): {
+    /**
+     * enqueue a job which runs require(module).default(payload) on the worker pool, and returns the id of the job
+     *
+     * @param module the module id, such as "./foo" or "bar" for node_modules/bar
+     * @param options delay in milliseconds, max retries after failures, and the initial backoff in milliseconds between retries (doubled each time, 1000 by default)
+     */
+    enqueue(module: string, payload?: any, options?: { delay?: number; retries?: number; backoff?: number; }): number;
+    get(id: number): { id: number; module: string; payload: string; status: "pending" | "running" | "succeeded" | "dead"; attempts: number; max_attempts: number; run_at: string; last_error: string; created_date: string; };
+    /**
+     * cancel a pending job, returns false if it has been started
+     */
+    cancel(id: number): boolean;
+}
+
 declare function $native(name: "lock"): (name: string) => {
     lock(timeout: number): void;
     unlock(): void;
 }
 
+type Metric = {
+    /**
+     * increase a counter or gauge by 1
+     *
+     * @param labels values of the labels declared on registration, missing labels are empty
+     */
+    inc(labels?: { [name: string]: string; }): void;
+    /** decrease a gauge by 1 */
+    dec(labels?: { [name: string]: string; }): void;
+    /** add a value to a counter (non-negative) or gauge */
+    add(value: number, labels?: { [name: string]: string; }): void;
+    /** set the value of a gauge */
+    set(value: number, labels?: { [name: string]: string; }): void;
+    /** observe a value of a histogram */
+    observe(value: number, labels?: { [name: string]: string; }): void;
+}
+/**
+ * metrics are shared by all workers and exposed at /metrics in the prometheus text format, registering an existing metric returns it if the kind and labels are the same
+ *
+ * names prefixed with cube_, go_ and process_ are reserved for built-in metrics
+ */
+declare function $native(name: "metrics"): {
+    counter(name: string, help: string, labels?: string[]): Metric;
+    gauge(name: string, help: string, labels?: string[]): Metric;
+    /**
+     * @param buckets upper bounds of the buckets, defaults to [0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10]
+     */
+    histogram(name: string, help: string, buckets?: number[], labels?: string[]): Metric;
+}
+
 declare function $native(name: "pipe"): (name: string) => BlockingQueue;
 
 type TCPSocketConnection = {
@@ -223,6 +626,27 @@ declare function $native(name: "process"): {
 
 declare function $native(name: "template"): (name: string, input: { [name: string]: any; }) => string;
 
+type TraceSpan = {
+    traceId: string;
+    spanId: string;
+    setAttribute(key: string, value: string | number | boolean): void;
+    /** mark the span as failed */
+    setError(message: string): void;
+    /** end the span and restore the previous span as the current one */
+    end(): void;
+}
+/**
+ * spans are exported to -trace-endpoint (OTLP/HTTP) and -trace-file, db, http, fetch, require and template calls in a trace get child spans automatically
+ */
+declare function $native(name: "trace"): {
+    /**
+     * start a span as a child of the current span, or a new trace outside of a trace (such as in a daemon), which becomes the current span until ended
+     */
+    start(name: string, attributes?: { [key: string]: string | number | boolean; }): TraceSpan;
+    /** the current span, or null outside of a trace */
+    current(): { traceId: string; spanId: string; traceparent: string; } | null;
+}
+
 declare function $native(name: "ulid"): () => string;
 
 type XmlNode = {